@@ -0,0 +1,92 @@
+// leadlag replays a trade recording through the lead-lag analyzer and prints
+// the reports as JSON lines. Record trades from the service by setting
+// RECORD_PATH, then run for example
+//
+//	go run ./cmd/leadlag -recording data/trades.jsonl -pair BTCUSDT -every 1m
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/leadlag"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/recording"
+)
+
+func main() {
+	path := flag.String("recording", "", "trade recording to replay")
+	pair := flag.String("pair", "", "only report the market this pair prices")
+	clock := flag.String("clock", exchangeconfig.ClockExchange, "order trades by exchange or received time")
+	window := flag.Duration("window", exchangeconfig.DefaultLeadLagWindow, "history each report covers")
+	resolution := flag.Duration("resolution", exchangeconfig.DefaultLeadLagResolution, "price grid and lag step")
+	maxLag := flag.Duration("max-lag", exchangeconfig.DefaultLeadLagMaxLag, "largest lag in either direction")
+	every := flag.Duration("every", 0, "report every interval of recorded time; zero reports once at the end")
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	analyzer := leadlag.NewAnalyzer(nil, nil, exchangeconfig.LeadLagConfig{
+		Clock:      *clock,
+		Window:     exchangeconfig.Duration{Duration: *window},
+		Resolution: exchangeconfig.Duration{Duration: *resolution},
+		MaxLag:     exchangeconfig.Duration{Duration: *maxLag},
+	})
+	if err := replay(*path, analyzer, *pair, *clock, *every, json.NewEncoder(os.Stdout)); err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+}
+
+// replay feeds a recording to the analyzer, encoding the reports of every
+// market, or only the one pair prices, each interval of recorded time and once
+// at the end.
+func replay(path string, analyzer *leadlag.Analyzer, pair, clock string, every time.Duration, out *json.Encoder) error {
+	report := func() {
+		pairs := analyzer.Pairs()
+		if pair != "" {
+			pairs = []string{pair}
+		}
+		for _, p := range pairs {
+			if r, ok := analyzer.Analyze(p); ok {
+				out.Encode(r)
+			}
+		}
+	}
+
+	var next int64
+	err := recording.Replay(path, func(t models.Trade) error {
+		if pair != "" && models.CanonicalPair(t.Pair) != models.CanonicalPair(pair) {
+			return nil
+		}
+		analyzer.OnTrade(t)
+
+		at := t.Timestamp
+		if clock == exchangeconfig.ClockReceived {
+			at = t.ReceivedAt
+		}
+		if every > 0 && at > 0 {
+			if next == 0 {
+				next = at + every.Milliseconds()
+			}
+			if at >= next {
+				report()
+				for at >= next {
+					next += every.Milliseconds()
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report()
+	return nil
+}
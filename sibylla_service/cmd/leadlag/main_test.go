@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/leadlag"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/recording"
+)
+
+func TestReplayRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.jsonl")
+	w, err := recording.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// kraken follows binance's random walk 300ms later, ETH is noise to filter out
+	random := rand.New(rand.NewSource(1))
+	const lag = 3
+	prices := []float64{100}
+	for i := 1; i < 600+lag; i++ {
+		prices = append(prices, prices[i-1]*math.Exp(random.NormFloat64()*0.001))
+	}
+	for i := lag; i < len(prices); i++ {
+		at := int64(1_000_000 + i*100)
+		for _, tr := range []models.Trade{
+			{Exchange: "binance", Pair: "BTCUSDT", Price: prices[i], Timestamp: at},
+			{Exchange: "kraken", Pair: "BTCUSD", Price: prices[i-lag], Timestamp: at},
+			{Exchange: "kraken", Pair: "ETHUSD", Price: 10, Timestamp: at},
+			{Exchange: "binance", Pair: "ETHUSDT", Price: 10, Timestamp: at},
+		} {
+			if err := w.Record(tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := leadlag.NewAnalyzer(nil, nil, exchangeconfig.LeadLagConfig{
+		Window:     exchangeconfig.Duration{Duration: time.Minute},
+		Resolution: exchangeconfig.Duration{Duration: 100 * time.Millisecond},
+		MaxLag:     exchangeconfig.Duration{Duration: time.Second},
+	})
+	var out bytes.Buffer
+	if err := replay(path, analyzer, "BTCUSDT", exchangeconfig.ClockExchange, 20*time.Second, json.NewEncoder(&out)); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []leadlag.Report
+	for dec := json.NewDecoder(&out); dec.More(); {
+		var r leadlag.Report
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, r)
+	}
+	// Every 20s of the minute recorded, and once at the end
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3", len(reports))
+	}
+	for _, r := range reports {
+		if r.Pair != "BTCUSD" {
+			t.Errorf("report for %s, want BTCUSD only", r.Pair)
+		}
+	}
+	last := reports[len(reports)-1]
+	if last.Leader != "binance" || len(last.Pairs) != 1 || last.Pairs[0].LeadMs != 300 {
+		t.Errorf("last report = %+v, want binance leading by 300ms", last)
+	}
+	if _, ok := analyzer.Current("ETHUSD"); ok {
+		t.Error("replayed trades of another market")
+	}
+}
@@ -3,24 +3,46 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"sibylla_service/pkg/alert"
+	"sibylla_service/pkg/arbitrage"
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bars"
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/candles"
 	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/conversion"
 	"sibylla_service/pkg/exchange"
+	"sibylla_service/pkg/expr"
+	"sibylla_service/pkg/filter"
+	"sibylla_service/pkg/flow"
 	handlers "sibylla_service/pkg/handlers"
+	"sibylla_service/pkg/index"
+	"sibylla_service/pkg/leadlag"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/marketdata"
+	"sibylla_service/pkg/marketdata/marketdatapb"
+	"sibylla_service/pkg/metrics"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/peg"
+	"sibylla_service/pkg/recording"
 	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spool"
+	"sibylla_service/pkg/spread"
+	"sibylla_service/pkg/stream"
+	"sibylla_service/pkg/volatility"
+	"sibylla_service/pkg/whale"
 
-	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 func main() {
 
 	// load .env file
@@ -37,32 +59,158 @@ func main() {
 		0,                            // use default Redis database (DB 0)
 	)
 
+	// Trades used to be stored in lists, convert any left before writing to them
+	redisClient.OnAvailable(func() { exchange.MigrateTradeLists(redisClient) })
+
+	// Buffer trades on disk while Redis is unavailable
+	tradeSpool, err := spool.Open(
+		getEnv("SPOOL_PATH", "data/trades.spool"),
+		getEnvInt64("SPOOL_MAX_BYTES", 256<<20), // 256MB
+	)
+	if err != nil {
+		log.Fatalf("Failed to open trade spool: %v", err)
+	}
+	defer tradeSpool.Close()
+	redisClient.EnableSpool(tradeSpool, 5*time.Second)
+
 	// ENVS //
 	port := getEnv("PORT", "8080")
+	grpcPort := getEnv("GRPC_PORT", "9090") // empty disables the gRPC API
+
+	serviceConfig, err := exchangeconfig.LoadServiceConfig(getEnv("CONFIG_PATH", "config.json"))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// ANALYTICS //
+	tradeBus := bus.New()
+
+	// Validates trades between the adapters and storage
+	tradeFilter := filter.NewFilter(redisClient, tradeBus, serviceConfig.Filter)
+
+	candleBuilder := candles.NewBuilder(redisClient, tradeBus, candles.DefaultIntervals, serviceConfig.Candles)
+	go candleBuilder.Run(time.Second)
+
+	barBuilder := bars.NewBuilder(redisClient, tradeBus, serviceConfig.Bars)
+
+	volatilityService, err := volatility.NewService(redisClient, tradeBus, serviceConfig.Volatility)
+	if err != nil {
+		log.Fatalf("Failed to start volatility service: %v", err)
+	}
+	go volatilityService.Run()
+
+	// Latest quote per exchange and pair, shared by the cross-venue engines
+	tracker := market.NewTracker()
+	averages := average.NewCalculator(serviceConfig.Averages)
+	flowTracker := flow.NewTracker(serviceConfig.Flow)
+	whaleDetector := whale.NewDetector(tracker, redisClient, tradeBus, serviceConfig.Whales)
+	leadLagAnalyzer := leadlag.NewAnalyzer(redisClient, tradeBus, serviceConfig.LeadLag)
+	go leadLagAnalyzer.Run()
+	spreadEngine := spread.NewEngine(tracker, averages, redisClient, tradeBus, serviceConfig.Spreads)
+	converter := conversion.NewConverter(tracker, serviceConfig.Conversion)
+	arbitrageDetector := arbitrage.NewDetector(tracker, converter, redisClient, tradeBus, serviceConfig.Arbitrage)
+	go arbitrageDetector.Run(time.Second)
+	indexEngine := index.NewEngine(tracker, averages, redisClient, tradeBus, serviceConfig.Index)
+	go indexEngine.Run()
+	pegMonitor := peg.NewMonitor(tracker, redisClient, tradeBus, serviceConfig.Pegs)
+	go pegMonitor.Run()
+	seriesEngine, err := expr.NewEngine(tracker, averages, redisClient, tradeBus, serviceConfig.Series)
+	if err != nil {
+		log.Fatalf("Failed to start series engine: %v", err)
+	}
+	go seriesEngine.Run()
+	alertEngine, err := alert.NewEngine(tracker, seriesEngine, redisClient, tradeBus, serviceConfig.Alerts)
+	if err != nil {
+		log.Fatalf("Failed to start alert engine: %v", err)
+	}
+	go alertEngine.Run()
+
+	go consumeTrades(tradeBus,
+		tracker.Update,
+		averages.OnTrade,
+		candleBuilder.AddTrade,
+		barBuilder.AddTrade,
+		spreadEngine.OnTrade,
+		arbitrageDetector.OnTrade,
+		indexEngine.OnTrade,
+		converter.OnTrade,
+		flowTracker.OnTrade,
+		whaleDetector.OnTrade,
+		leadLagAnalyzer.OnTrade,
+		alertEngine.OnTrade,
+	)
+
+	// Optionally record every trade for offline replay, see cmd/leadlag
+	if path := getEnv("RECORD_PATH", ""); path != "" {
+		recorder, err := recording.Create(path)
+		if err != nil {
+			log.Fatalf("Failed to open trade recording: %v", err)
+		}
+		defer recorder.Close()
+		go recordTrades(tradeBus, recorder)
+	}
+
+	// Pushes live updates to websocket and server-sent event clients
+	streamHub := stream.NewHub(tradeBus, serviceConfig.Stream)
+	go streamHub.Run()
 
 	// ROUTES //
 	fs := http.FileServer(http.Dir("./static"))
 	http.Handle("/", fs)
-	http.HandleFunc("/api/trades", handlers.TradesHandler(redisClient))
+	http.HandleFunc("/api/trades", handlers.TradesHandler(redisClient, spreadEngine))
+	http.HandleFunc("/api/summary", handlers.SummaryHandler(redisClient, spreadEngine))
+	http.HandleFunc("/api/candles", handlers.CandlesHandler(redisClient, candleBuilder))
+	http.HandleFunc("/api/bars", handlers.BarsHandler(redisClient, barBuilder))
+	http.HandleFunc("/api/spreads", handlers.SpreadsHandler(redisClient, spreadEngine))
+	http.HandleFunc("/api/arbitrage", handlers.ArbitrageHandler(redisClient, arbitrageDetector))
+	http.HandleFunc("/api/index", handlers.IndexHandler(indexEngine))
+	http.HandleFunc("/api/convert", handlers.ConvertHandler(converter))
+	http.HandleFunc("/api/pegs", handlers.PegsHandler(redisClient, pegMonitor))
+	http.HandleFunc("/api/averages", handlers.AveragesHandler(averages))
+	http.HandleFunc("/api/volatility", handlers.VolatilityHandler(volatilityService))
+	http.HandleFunc("/api/flow", handlers.FlowHandler(flowTracker))
+	http.HandleFunc("/api/whales", handlers.WhalesHandler(redisClient))
+	http.HandleFunc("/api/leadlag", handlers.LeadLagHandler(redisClient, leadLagAnalyzer))
+	http.HandleFunc("/api/quarantine", handlers.QuarantineHandler(redisClient, tradeFilter))
+	http.HandleFunc("/api/alerts", handlers.AlertsHandler(redisClient, alertEngine))
+	http.HandleFunc("/api/series", handlers.SeriesHandler(redisClient, seriesEngine))
+	http.Handle("/ws", streamHub)
+	http.HandleFunc("/events/", streamHub.ServeEvents)
+
+	registry := metrics.NewRegistry()
+	registry.Register(flowTracker.Metrics)
+	registry.Register(tradeFilter.Metrics)
+	registry.Register(streamHub.Metrics)
+	registry.Register(tradeSpool.Metrics)
+	http.HandleFunc("/metrics", registry.Handler())
 
 	// Initialize exchange listeners
 	binanceConfig := exchangeconfig.Config{
 		ConnectionString: getEnv("BINANCE_WEBSOCKET_URL", ""),
 		RedisClient:      redisClient,
+		Retention:        serviceConfig.Retention,
+		Bus:              tradeBus,
+		Filter:           tradeFilter,
 	}
 
 	krakenConfig := exchangeconfig.Config{
 		ConnectionString: getEnv("KRAKEN_WEBSOCKET_URL", ""),
 		RedisClient:      redisClient,
+		Retention:        serviceConfig.Retention,
+		Bus:              tradeBus,
+		Filter:           tradeFilter,
 	}
 
 	// coinbaseConfig := exchangeconfig.Config{
 	// 	ConnectionString: getEnv("COINBASE_WEBSOCKET_URL", ""),
 	// 	RedisClient:      redisClient,
+	// 	Retention:        serviceConfig.Retention,
+	// 	Bus:              tradeBus,
+	// 	Filter:           tradeFilter,
 	// }
 
 	// Base pairs that we are watching
-	basePairs := []string{"BTCUSDT", "ETHUSDT", "BTCUSD", "ETHUSD", "WBTCUSDT"}
+	basePairs := []string{"BTCUSDT", "ETHUSDT", "BTCUSD", "ETHUSD", "WBTCUSDT", "USDTUSD"}
 
 	binancePairs, err := exchange.ConvertPairs(basePairs, "binance")
 	krakenPairs, err := exchange.ConvertPairs(basePairs, "kraken")
@@ -78,6 +226,23 @@ func main() {
 	go exchange.ConnectKrakenWebSocket(krakenConfig, krakenPairs)
 	// go exchange.ConnectCoinbaseWebSocket(coinbaseConfig, coinbasePairs)
 
+	// The same market data as a typed API, see proto/marketdata
+	if grpcPort != "" {
+		listener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcServer := grpc.NewServer()
+		marketdatapb.RegisterMarketDataServer(grpcServer, marketdata.NewServer(redisClient, candleBuilder, indexEngine, tradeBus))
+		reflection.Register(grpcServer)
+		go func() {
+			log.Printf("gRPC server starting on port %s", grpcPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	// start server
 	log.Printf("Server starting on port %s", port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
@@ -87,6 +252,37 @@ func main() {
 	}
 }
 
+// consumeTrades feeds every published trade to the analytics consumers, in order
+func consumeTrades(b *bus.Bus, consumers ...func(models.Trade)) {
+	sub := b.Subscribe(bus.TopicTrades, 4096)
+	for msg := range sub.C {
+		t := msg.Payload.(models.Trade)
+		for _, consume := range consumers {
+			consume(t)
+		}
+	}
+}
+
+// recordTrades appends every trade on the bus to a recording, flushing once a second.
+func recordTrades(b *bus.Bus, recorder *recording.Writer) {
+	sub := b.Subscribe(bus.TopicTrades, 4096)
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+
+	for {
+		select {
+		case msg := <-sub.C:
+			if err := recorder.Record(msg.Payload.(models.Trade)); err != nil {
+				log.Printf("Could not record trade: %v", err)
+			}
+		case <-flush.C:
+			if err := recorder.Flush(); err != nil {
+				log.Printf("Could not flush trade recording: %v", err)
+			}
+		}
+	}
+}
+
 // simple handler function
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Sibylla online"))
@@ -99,3 +295,17 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// helper function to load integer env variables with a default
+func getEnvInt64(key string, fallback int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %d", key, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,564 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/expr"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// Store keys
+const (
+	StoreKey = "alerts:events" // stream of notifications
+	RulesKey = "alerts:rules"  // hash of rules added through the API, by ID
+)
+
+// Rule states
+const (
+	StateOK      = "ok"
+	StatePending = "pending" // breached, waiting out the rule's For
+	StateFiring  = "firing"
+)
+
+// Notification states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Rule sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// Errors of rule changes through the API
+var (
+	ErrNoRule     = errors.New("no such alert rule")
+	ErrConfigRule = errors.New("alert rule is defined in config")
+	ErrNotStored  = errors.New("alert rule change could not be stored")
+)
+
+// Alert is a notification that a rule fired or resolved.
+type Alert struct {
+	ID        string  `json:"id"`
+	RuleID    string  `json:"rule_id"`
+	RuleName  string  `json:"rule_name"`
+	Kind      string  `json:"kind"`
+	State     string  `json:"state"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Message   string  `json:"message"`
+	Since     int64   `json:"since"` // when the rule was first breached, Unix ms
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (a Alert) MarshalBinary() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// RuleStatus is a rule with its current evaluation state.
+type RuleStatus struct {
+	Rule      exchangeconfig.AlertRule `json:"rule"`
+	Source    string                   `json:"source"`
+	State     string                   `json:"state"`
+	Value     *float64                 `json:"value"` // nil until the rule's market has traded
+	Since     int64                    `json:"since,omitempty"`
+	LastFired int64                    `json:"last_fired,omitempty"`
+}
+
+type ruleState struct {
+	rule      exchangeconfig.AlertRule
+	program   *expr.Program // expression rules only
+	source    string
+	state     string
+	value     float64
+	known     bool
+	since     time.Time
+	lastFired time.Time
+}
+
+// Engine evaluates alert rules against the live market on every trade and on
+// a tick, and notifies sinks when they fire and resolve.
+type Engine struct {
+	mu          sync.Mutex
+	config      exchangeconfig.AlertConfig
+	tracker     *market.Tracker
+	series      *expr.Engine // nil disables expression rules
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	sinks       []*delivery
+	rules       map[string]*ruleState
+	started     time.Time
+	seq         uint64 // numbers notifications so IDs stay unique within a millisecond
+}
+
+func NewEngine(tracker *market.Tracker, series *expr.Engine, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.AlertConfig) (*Engine, error) {
+	config = config.WithDefaults()
+	e := &Engine{
+		config:      config,
+		tracker:     tracker,
+		series:      series,
+		redisClient: redisClient,
+		bus:         b,
+		rules:       make(map[string]*ruleState),
+		started:     time.Now(),
+	}
+	for _, sc := range config.Sinks {
+		sink, err := NewSink(sc)
+		if err != nil {
+			return nil, err
+		}
+		e.sinks = append(e.sinks, newDelivery(sink, config.QueueSize))
+	}
+
+	for _, rule := range config.Rules {
+		if err := e.add(rule, SourceConfig); err != nil {
+			return nil, err
+		}
+	}
+	// Start delivering and guarding series once the config is known to be
+	// valid, so a failed engine leaves nothing behind
+	for _, d := range e.sinks {
+		go d.run()
+	}
+	if series != nil {
+		series.AddUsers(e.SeriesUsers)
+		series.OnLoad(e.recompile)
+	}
+	if redisClient != nil {
+		// Redis may be down at startup: the config rules run meanwhile, and the
+		// stored ones join once it is back
+		redisClient.OnAvailable(e.loadRules)
+	}
+	return e, nil
+}
+
+// loadRules adds the rules stored through the API.
+func (e *Engine) loadRules() {
+	stored, err := e.redisClient.GetHash(RulesKey)
+	if err != nil {
+		log.Printf("Could not load stored alert rules, using config rules only: %v", err)
+		return
+	}
+	for id, raw := range stored {
+		var rule exchangeconfig.AlertRule
+		if err := json.Unmarshal([]byte(raw), &rule); err != nil {
+			log.Printf("Skipping stored alert rule %s: %v", id, err)
+			continue
+		}
+		if err := e.add(rule, SourceAPI); err != nil {
+			log.Printf("Skipping stored alert rule %s: %v", id, err)
+		}
+	}
+}
+
+// AddRule validates and adds a rule, persisting it so it survives restarts.
+// When it can't be stored the rule isn't added either, and the error wraps
+// ErrNotStored.
+func (e *Engine) AddRule(rule exchangeconfig.AlertRule) error {
+	e.mu.Lock()
+	previous := e.rules[rule.ID]
+	e.mu.Unlock()
+
+	if err := e.add(rule, SourceAPI); err != nil {
+		return err
+	}
+	if e.redisClient == nil {
+		return nil
+	}
+	raw, err := json.Marshal(rule)
+	if err == nil {
+		err = e.redisClient.SetInHash(RulesKey, rule.ID, string(raw))
+	}
+	if err != nil {
+		e.restore(rule.ID, previous)
+		return fmt.Errorf("%w: %v", ErrNotStored, err)
+	}
+	return nil
+}
+
+// RemoveRule removes a rule added through the API. Its errors wrap ErrNoRule,
+// ErrConfigRule or, when the removal can't be stored and the rule is kept,
+// ErrNotStored.
+func (e *Engine) RemoveRule(id string) error {
+	e.mu.Lock()
+	rs, ok := e.rules[id]
+	if !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrNoRule, id)
+	}
+	if rs.source != SourceAPI {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrConfigRule, id)
+	}
+	delete(e.rules, id)
+	e.mu.Unlock()
+
+	if e.redisClient == nil {
+		return nil
+	}
+	if err := e.redisClient.DeleteFromHash(RulesKey, id); err != nil {
+		e.restore(id, rs)
+		return fmt.Errorf("%w: %v", ErrNotStored, err)
+	}
+	return nil
+}
+
+// restore puts a rule back as it was before a change that couldn't be
+// stored, removing it when there was none.
+func (e *Engine) restore(id string, previous *ruleState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if previous == nil {
+		delete(e.rules, id)
+		return
+	}
+	e.rules[id] = previous
+}
+
+// SeriesUsers returns the expression rules referring to a named series, sorted.
+func (e *Engine) SeriesUsers(name string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var users []string
+	for _, rs := range e.rules {
+		if rs.program != nil && contains(rs.program.Deps(), name) {
+			users = append(users, "alert rule "+rs.rule.ID)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (e *Engine) add(rule exchangeconfig.AlertRule, source string) error {
+	if err := e.validate(rule); err != nil {
+		return err
+	}
+	if rule.Kind == exchangeconfig.AlertSpread && rule.PairB == "" {
+		rule.PairB = rule.Pair
+	}
+	if rule.Name == "" {
+		rule.Name = rule.ID
+	}
+	var program *expr.Program
+	if rule.Kind == exchangeconfig.AlertExpr {
+		var err error
+		if program, err = e.series.Compile(rule.Expr); err != nil {
+			// A config rule may refer to a series stored through the API, which
+			// loads once Redis is up: recompile picks the rule up then
+			if source != SourceConfig || e.series.Loaded() {
+				return fmt.Errorf("alert rule %s: invalid expression: %w", rule.ID, err)
+			}
+			log.Printf("Alert rule %s waits for the stored series: %v", rule.ID, err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if existing, ok := e.rules[rule.ID]; ok && existing.source != source {
+		return fmt.Errorf("alert rule %q already exists", rule.ID)
+	}
+	e.rules[rule.ID] = &ruleState{rule: rule, program: program, source: source, state: StateOK}
+	return nil
+}
+
+// recompile compiles the expression rules that couldn't be compiled before the
+// stored series were loaded. Those that still fail stay inactive.
+func (e *Engine) recompile() {
+	e.mu.Lock()
+	var pending []exchangeconfig.AlertRule
+	for _, rs := range e.rules {
+		if rs.rule.Kind == exchangeconfig.AlertExpr && rs.program == nil {
+			pending = append(pending, rs.rule)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, rule := range pending {
+		program, err := e.series.Compile(rule.Expr)
+		if err != nil {
+			log.Printf("Alert rule %s stays inactive: invalid expression: %v", rule.ID, err)
+			continue
+		}
+		e.mu.Lock()
+		if rs, ok := e.rules[rule.ID]; ok && rs.program == nil {
+			rs.program = program
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *Engine) validate(rule exchangeconfig.AlertRule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("alert rule needs an id")
+	}
+	// Both end up in mail headers
+	if strings.ContainsAny(rule.ID+rule.Name, "\r\n") {
+		return fmt.Errorf("alert rule %q: id and name can't contain line breaks", rule.ID)
+	}
+	switch rule.Kind {
+	case exchangeconfig.AlertPrice, exchangeconfig.AlertNoTrades:
+		if rule.Exchange == "" || rule.Pair == "" {
+			return fmt.Errorf("alert rule %s: %s needs exchange and pair", rule.ID, rule.Kind)
+		}
+	case exchangeconfig.AlertSpread:
+		if rule.Exchange == "" || rule.ExchangeB == "" || rule.Pair == "" {
+			return fmt.Errorf("alert rule %s: spread needs exchange, exchange_b and pair", rule.ID)
+		}
+	case exchangeconfig.AlertExpr:
+		if rule.Expr == "" {
+			return fmt.Errorf("alert rule %s: expression needs expr", rule.ID)
+		}
+		if e.series == nil {
+			return fmt.Errorf("alert rule %s: expressions are not available", rule.ID)
+		}
+	default:
+		return fmt.Errorf("alert rule %s: unknown kind %q", rule.ID, rule.Kind)
+	}
+	if rule.Op != exchangeconfig.AlertAbove && rule.Op != exchangeconfig.AlertBelow {
+		return fmt.Errorf("alert rule %s: op must be %q or %q", rule.ID, exchangeconfig.AlertAbove, exchangeconfig.AlertBelow)
+	}
+	if rule.For.Duration < 0 || rule.Cooldown.Duration < 0 || rule.Hysteresis < 0 {
+		return fmt.Errorf("alert rule %s: for, cooldown and hysteresis can't be negative", rule.ID)
+	}
+	for _, name := range rule.Sinks {
+		if e.sink(name) == nil {
+			return fmt.Errorf("alert rule %s: unknown sink %q", rule.ID, name)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) sink(name string) *delivery {
+	for _, d := range e.sinks {
+		if d.sink.Name() == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// OnTrade evaluates the rules watching the trade's pair. The tracker must
+// already hold the trade.
+func (e *Engine) OnTrade(t models.Trade) {
+	e.evaluate(time.Now(), func(rule exchangeconfig.AlertRule) bool {
+		return rule.Kind != exchangeconfig.AlertExpr && (rule.Pair == t.Pair || rule.PairB == t.Pair)
+	})
+}
+
+// Run evaluates every rule on the configured interval, which is what catches
+// markets going quiet. Expression rules are only evaluated here, which keeps
+// the history of functions such as sma evenly sampled. It blocks, call it in
+// a goroutine.
+func (e *Engine) Run() {
+	ticker := time.NewTicker(e.config.Interval.Duration)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		e.evaluate(now, nil)
+	}
+}
+
+func (e *Engine) evaluate(now time.Time, match func(exchangeconfig.AlertRule) bool) {
+	var alerts []Alert
+
+	e.mu.Lock()
+	for _, rs := range e.rules {
+		if match != nil && !match(rs.rule) {
+			continue
+		}
+		if a, ok := e.step(rs, now); ok {
+			alerts = append(alerts, a)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, a := range alerts {
+		e.notify(a)
+	}
+}
+
+// step advances a rule's state machine and returns a notification when it
+// fires or resolves.
+func (e *Engine) step(rs *ruleState, now time.Time) (Alert, bool) {
+	rule := rs.rule
+	value, ok := e.value(rs, now)
+	if !ok {
+		return Alert{}, false
+	}
+	rs.value, rs.known = value, true
+
+	above := rule.Op == exchangeconfig.AlertAbove
+	breached := (above && value > rule.Threshold) || (!above && value < rule.Threshold)
+	cleared := (above && value < rule.Threshold-rule.Hysteresis) || (!above && value > rule.Threshold+rule.Hysteresis)
+
+	switch rs.state {
+	case StateOK, StatePending:
+		if !breached {
+			rs.state = StateOK
+			return Alert{}, false
+		}
+		if rs.state == StateOK {
+			rs.state, rs.since = StatePending, now
+		}
+		// Still breached once the cooldown is over fires then
+		if now.Sub(rs.since) < rule.For.Duration || (!rs.lastFired.IsZero() && now.Sub(rs.lastFired) < rule.Cooldown.Duration) {
+			return Alert{}, false
+		}
+		rs.state, rs.lastFired = StateFiring, now
+		return e.alert(rs, AlertFiring, now), true
+	case StateFiring:
+		if !cleared {
+			return Alert{}, false
+		}
+		rs.state = StateOK
+		return e.alert(rs, AlertResolved, now), true
+	}
+	return Alert{}, false
+}
+
+// value is the rule's current measurement, false when its market hasn't traded
+// or its expression can't be evaluated.
+func (e *Engine) value(rs *ruleState, now time.Time) (float64, bool) {
+	rule := rs.rule
+	switch rule.Kind {
+	case exchangeconfig.AlertPrice:
+		q, ok := e.tracker.Last(rule.Exchange, rule.Pair)
+		return q.Price, ok
+	case exchangeconfig.AlertSpread:
+		a, okA := e.tracker.Last(rule.Exchange, rule.Pair)
+		b, okB := e.tracker.Last(rule.ExchangeB, rule.PairB)
+		if !okA || !okB {
+			return 0, false
+		}
+		return (a.Price - b.Price) / ((a.Price + b.Price) / 2) * 10000, true
+	case exchangeconfig.AlertNoTrades:
+		// A market that never traded has been quiet since startup
+		last := e.started
+		if q, ok := e.tracker.Last(rule.Exchange, rule.Pair); ok {
+			last = q.Received
+		}
+		return now.Sub(last).Seconds(), true
+	case exchangeconfig.AlertExpr:
+		if rs.program == nil {
+			return 0, false
+		}
+		value, err := rs.program.Eval(now)
+		return value, err == nil
+	}
+	return 0, false
+}
+
+func (e *Engine) alert(rs *ruleState, state string, now time.Time) Alert {
+	rule := rs.rule
+	e.seq++
+	return Alert{
+		ID:        fmt.Sprintf("%s:%s:%d:%d", rule.ID, state, now.UnixMilli(), e.seq),
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		Kind:      rule.Kind,
+		State:     state,
+		Value:     rs.value,
+		Threshold: rule.Threshold,
+		Message:   describe(rule, state, rs.value),
+		Since:     rs.since.UnixMilli(),
+		Timestamp: now.UnixMilli(),
+	}
+}
+
+func describe(rule exchangeconfig.AlertRule, state string, value float64) string {
+	op := rule.Op
+	if state == AlertResolved {
+		op = "back " + map[string]string{exchangeconfig.AlertAbove: "below", exchangeconfig.AlertBelow: "above"}[rule.Op]
+	}
+
+	switch rule.Kind {
+	case exchangeconfig.AlertPrice:
+		return fmt.Sprintf("%s: %s %s price %g %s %g", rule.Name, rule.Exchange, rule.Pair, value, op, rule.Threshold)
+	case exchangeconfig.AlertSpread:
+		return fmt.Sprintf("%s: %s %s vs %s %s spread %.1f bps %s %g bps", rule.Name,
+			rule.Exchange, rule.Pair, rule.ExchangeB, rule.PairB, value, op, rule.Threshold)
+	case exchangeconfig.AlertNoTrades:
+		if state == AlertResolved {
+			return fmt.Sprintf("%s: %s %s is trading again", rule.Name, rule.Exchange, rule.Pair)
+		}
+		return fmt.Sprintf("%s: no trades on %s %s for %.0fs", rule.Name, rule.Exchange, rule.Pair, value)
+	case exchangeconfig.AlertExpr:
+		return fmt.Sprintf("%s: %s = %g %s %g", rule.Name, rule.Expr, value, op, rule.Threshold)
+	}
+	return rule.Name
+}
+
+// notify publishes and stores a notification and queues it for the rule's
+// sinks without blocking evaluation on slow deliveries.
+func (e *Engine) notify(a Alert) {
+	if e.bus != nil {
+		e.bus.Publish(bus.TopicAlerts, a)
+	}
+	if e.redisClient != nil {
+		if err := e.redisClient.AppendToStream(StoreKey, a, e.config.MaxStored, 0); err != nil {
+			log.Printf("Could not store alert %s: %v", a.ID, err)
+		}
+	}
+
+	e.mu.Lock()
+	var names []string
+	if rs, ok := e.rules[a.RuleID]; ok {
+		names = rs.rule.Sinks
+	}
+	e.mu.Unlock()
+
+	for _, d := range e.sinks {
+		if len(names) > 0 && !contains(names, d.sink.Name()) {
+			continue
+		}
+		d.enqueue(a)
+	}
+}
+
+// Rules returns every rule with its state, sorted by ID.
+func (e *Engine) Rules() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	statuses := make([]RuleStatus, 0, len(e.rules))
+	for _, rs := range e.rules {
+		status := RuleStatus{Rule: rs.rule, Source: rs.source, State: rs.state}
+		if rs.known {
+			value := rs.value
+			status.Value = &value
+		}
+		if rs.state != StateOK {
+			status.Since = rs.since.UnixMilli()
+		}
+		if !rs.lastFired.IsZero() {
+			status.LastFired = rs.lastFired.UnixMilli()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Rule.ID < statuses[j].Rule.ID })
+	return statuses
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/expr"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spool"
+)
+
+func TestRuleLifecycle(t *testing.T) {
+	tracker := market.NewTracker()
+	b := bus.New()
+	sub := b.Subscribe(bus.TopicAlerts, 16)
+	e, err := NewEngine(tracker, nil, nil, b, exchangeconfig.AlertConfig{
+		Rules: []exchangeconfig.AlertRule{{
+			ID:         "btc",
+			Kind:       exchangeconfig.AlertPrice,
+			Exchange:   "binance",
+			Pair:       "BTCUSDT",
+			Op:         exchangeconfig.AlertAbove,
+			Threshold:  100,
+			For:        exchangeconfig.Duration{Duration: 10 * time.Second},
+			Hysteresis: 5,
+			Cooldown:   exchangeconfig.Duration{Duration: time.Minute},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now()
+	step := func(offset time.Duration, price float64, want string) {
+		t.Helper()
+		tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: price})
+		e.evaluate(t0.Add(offset), nil)
+
+		var got string
+		select {
+		case msg := <-sub.C:
+			got = msg.Payload.(Alert).State
+		default:
+		}
+		if got != want {
+			t.Errorf("at %v with %v: notified %q, want %q", offset, price, got, want)
+		}
+	}
+	state := func() string { return e.Rules()[0].State }
+
+	step(0, 101, "")
+	if state() != StatePending {
+		t.Errorf("state %s, want pending while For runs", state())
+	}
+	step(5*time.Second, 99, "") // a dip restarts For
+	step(6*time.Second, 101, "")
+	step(15*time.Second, 101, "")
+	step(16*time.Second, 101, AlertFiring)
+	step(20*time.Second, 101, "") // fires once
+
+	// Within the hysteresis band the rule keeps firing
+	step(30*time.Second, 96, "")
+	if state() != StateFiring {
+		t.Errorf("state %s, want firing within the hysteresis", state())
+	}
+	step(40*time.Second, 94, AlertResolved)
+
+	// Breached again, For passes but the cooldown holds it back until a minute after firing
+	step(50*time.Second, 101, "")
+	step(70*time.Second, 101, "")
+	step(76*time.Second, 101, AlertFiring)
+}
+
+func TestRuleValidation(t *testing.T) {
+	e, err := NewEngine(market.NewTracker(), nil, nil, nil, exchangeconfig.AlertConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range []exchangeconfig.AlertRule{
+		{Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove},
+		{ID: "a", Kind: exchangeconfig.AlertPrice, Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove},
+		{ID: "b", Kind: exchangeconfig.AlertSpread, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove},
+		{ID: "c", Kind: exchangeconfig.AlertExpr, Expr: "1", Op: exchangeconfig.AlertAbove}, // no series engine
+		{ID: "d", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: "near"},
+		{ID: "e", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove, Sinks: []string{"pager"}},
+		{ID: "f", Kind: "volume"},
+		{ID: "g\r\nBcc: someone@example.com", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove},
+		{ID: "h", Name: "BTC\nhigh", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove},
+	} {
+		if err := e.AddRule(rule); err == nil {
+			t.Errorf("AddRule(%+v) accepted an invalid rule", rule)
+		}
+	}
+
+	rule := exchangeconfig.AlertRule{ID: "ok", Kind: exchangeconfig.AlertNoTrades, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove, Threshold: 60}
+	if err := e.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RemoveRule("ok"); err != nil {
+		t.Error(err)
+	}
+	if err := e.RemoveRule("ok"); err == nil {
+		t.Error("removed a rule twice")
+	}
+}
+
+func TestExpressionRuleHoldsSeries(t *testing.T) {
+	tracker := market.NewTracker()
+	series, err := expr.NewEngine(tracker, nil, nil, nil, exchangeconfig.SeriesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := series.Define("basis", "kraken:BTCUSD.last - binance:BTCUSDT.last"); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(tracker, series, nil, nil, exchangeconfig.AlertConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := exchangeconfig.AlertRule{ID: "wide", Kind: exchangeconfig.AlertExpr, Expr: "abs(sma(basis, 1m))", Op: exchangeconfig.AlertAbove, Threshold: 25}
+	if err := e.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	if users := e.SeriesUsers("basis"); len(users) != 1 || users[0] != "alert rule wide" {
+		t.Errorf("SeriesUsers = %v", users)
+	}
+	if err := series.Remove("basis"); err == nil || !strings.Contains(err.Error(), "alert rule wide") {
+		t.Errorf("Remove = %v, want it held by the alert rule", err)
+	}
+	if err := e.RemoveRule("wide"); err != nil {
+		t.Fatal(err)
+	}
+	if err := series.Remove("basis"); err != nil {
+		t.Errorf("Remove after the rule went = %v", err)
+	}
+}
+
+// downRedis returns a client of a Redis that isn't up yet, and the address to
+// start it at.
+func downRedis(t *testing.T) (*redisclient.RedisClient, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	redisClient := redisclient.NewRedisClient(addr, "", 0)
+	s, err := spool.Open(filepath.Join(t.TempDir(), "trades.spool"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	redisClient.EnableSpool(s, 10*time.Millisecond)
+	return redisClient, addr
+}
+
+// eventually fails the test unless cond holds within a couple of seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStoredRulesLoadOnceRedisIsUp(t *testing.T) {
+	redisClient, addr := downRedis(t)
+	config := exchangeconfig.AlertRule{ID: "config", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove, Threshold: 100}
+	e, err := NewEngine(market.NewTracker(), nil, redisClient, nil, exchangeconfig.AlertConfig{Rules: []exchangeconfig.AlertRule{config}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := e.Rules(); len(rules) != 1 {
+		t.Fatalf("got %d rules while Redis is down, want the config rule", len(rules))
+	}
+
+	m := miniredis.NewMiniRedis()
+	stored := config
+	stored.ID = "stored"
+	raw, _ := json.Marshal(stored)
+	m.HSet(RulesKey, stored.ID, string(raw))
+	if err := m.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	eventually(t, "stored rule not loaded once Redis came up", func() bool { return len(e.Rules()) == 2 })
+}
+
+func TestConfigRuleOnStoredSeriesCompilesOnceRedisIsUp(t *testing.T) {
+	redisClient, addr := downRedis(t)
+	tracker := market.NewTracker()
+	series, err := expr.NewEngine(tracker, nil, redisClient, nil, exchangeconfig.SeriesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := exchangeconfig.AlertRule{ID: "wide", Kind: exchangeconfig.AlertExpr, Expr: "abs(basis)", Op: exchangeconfig.AlertAbove, Threshold: 25}
+	e, err := NewEngine(tracker, series, redisClient, nil, exchangeconfig.AlertConfig{Rules: []exchangeconfig.AlertRule{rule}})
+	if err != nil {
+		t.Fatalf("config rule on a stored series failed while Redis is down: %v", err)
+	}
+	if users := e.SeriesUsers("basis"); len(users) != 0 {
+		t.Errorf("SeriesUsers = %v before the series loaded", users)
+	}
+
+	m := miniredis.NewMiniRedis()
+	m.HSet(expr.DefinitionsKey, "basis", "kraken:BTCUSD.last - binance:BTCUSDT.last")
+	if err := m.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	eventually(t, "rule not compiled once the stored series loaded", func() bool { return len(e.SeriesUsers("basis")) == 1 })
+}
+
+func TestRuleChangesThatCantBeStored(t *testing.T) {
+	m := miniredis.RunT(t)
+	config := exchangeconfig.AlertRule{ID: "config", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertAbove, Threshold: 100}
+	e, err := NewEngine(market.NewTracker(), nil, redisclient.NewRedisClient(m.Addr(), "", 0), nil, exchangeconfig.AlertConfig{Rules: []exchangeconfig.AlertRule{config}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RemoveRule("config"); !errors.Is(err, ErrConfigRule) {
+		t.Errorf("RemoveRule on a config rule = %v, want ErrConfigRule", err)
+	}
+	if err := e.RemoveRule("missing"); !errors.Is(err, ErrNoRule) {
+		t.Errorf("RemoveRule on a missing rule = %v, want ErrNoRule", err)
+	}
+
+	kept := exchangeconfig.AlertRule{ID: "kept", Kind: exchangeconfig.AlertPrice, Exchange: "binance", Pair: "BTCUSDT", Op: exchangeconfig.AlertBelow, Threshold: 50}
+	if err := e.AddRule(kept); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	rule := kept
+	rule.ID = "new"
+	if err := e.AddRule(rule); !errors.Is(err, ErrNotStored) {
+		t.Errorf("AddRule with Redis down = %v, want ErrNotStored", err)
+	}
+	if err := e.RemoveRule("kept"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("RemoveRule with Redis down = %v, want ErrNotStored", err)
+	}
+	ids := make(map[string]bool)
+	for _, rs := range e.Rules() {
+		ids[rs.Rule.ID] = true
+	}
+	if len(ids) != 2 || !ids["config"] || !ids["kept"] {
+		t.Errorf("rules %v, want config and kept as before the failed changes", ids)
+	}
+}
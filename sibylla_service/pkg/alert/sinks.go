@@ -0,0 +1,209 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+)
+
+// Webhook headers. The signature is the hex HMAC-SHA256 of the timestamp, a
+// dot and the body, keyed with the sink's secret, so receivers can check both
+// the sender and the freshness of a delivery.
+const (
+	SignatureHeader = "X-Sibylla-Signature"
+	TimestampHeader = "X-Sibylla-Timestamp"
+)
+
+// webhookTimeout bounds a single webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// Sink delivers alert notifications.
+type Sink interface {
+	Name() string
+	Send(a Alert) error
+}
+
+// delivery queues notifications for one sink and sends them in order from a
+// single worker, so a slow or unreachable sink holds up neither evaluation nor
+// the other sinks. Notifications beyond the queue are dropped.
+type delivery struct {
+	sink    Sink
+	queue   chan Alert
+	dropped int64
+}
+
+func newDelivery(sink Sink, size int) *delivery {
+	return &delivery{sink: sink, queue: make(chan Alert, size)}
+}
+
+// run sends queued notifications. It blocks, call it in a goroutine.
+func (d *delivery) run() {
+	for a := range d.queue {
+		if err := d.sink.Send(a); err != nil {
+			log.Printf("Could not deliver alert %s to %s: %v", a.ID, d.sink.Name(), err)
+		}
+	}
+}
+
+func (d *delivery) enqueue(a Alert) {
+	select {
+	case d.queue <- a:
+	default:
+		dropped := atomic.AddInt64(&d.dropped, 1)
+		// Log on powers of two so a stuck sink doesn't flood the logs
+		if dropped&(dropped-1) == 0 {
+			log.Printf("Alert sink %s is falling behind, %d notifications dropped", d.sink.Name(), dropped)
+		}
+	}
+}
+
+// NewSink builds a sink from its config.
+func NewSink(config exchangeconfig.AlertSink) (Sink, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("alert sink needs a name")
+	}
+
+	switch config.Type {
+	case exchangeconfig.SinkLog:
+		return logSink{name: config.Name}, nil
+	case exchangeconfig.SinkWebhook:
+		if config.URL == "" {
+			return nil, fmt.Errorf("webhook sink %s needs a url", config.Name)
+		}
+		return &webhookSink{
+			name:   config.Name,
+			url:    config.URL,
+			secret: secret(config.Secret, config.SecretEnv),
+			client: &http.Client{Timeout: webhookTimeout},
+		}, nil
+	case exchangeconfig.SinkSMTP:
+		if config.Addr == "" || config.From == "" || len(config.To) == 0 {
+			return nil, fmt.Errorf("smtp sink %s needs addr, from and to", config.Name)
+		}
+		return &smtpSink{
+			name:     config.Name,
+			addr:     config.Addr,
+			username: config.Username,
+			password: secret(config.Password, config.PasswordEnv),
+			from:     config.From,
+			to:       config.To,
+		}, nil
+	default:
+		return nil, fmt.Errorf("alert sink %s has unknown type %q", config.Name, config.Type)
+	}
+}
+
+func secret(value, env string) string {
+	if env != "" {
+		return os.Getenv(env)
+	}
+	return value
+}
+
+type logSink struct {
+	name string
+}
+
+func (s logSink) Name() string { return s.name }
+
+func (s logSink) Send(a Alert) error {
+	log.Printf("ALERT %s: %s", strings.ToUpper(a.State), a.Message)
+	return nil
+}
+
+type webhookSink struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+// Send posts the alert as JSON, signed when the sink has a secret.
+func (s *webhookSink) Send(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 webhook signature of a timestamp and body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type smtpSink struct {
+	name     string
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s *smtpSink) Name() string { return s.name }
+
+// Send mails the alert as plain text. Authentication is skipped without a
+// username; net/smtp only sends credentials over TLS or to localhost.
+func (s *smtpSink) Send(a Alert) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return fmt.Errorf("smtp addr %s: %w", s.addr, err)
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	subject := fmt.Sprintf("[sibylla] %s %s", strings.ToUpper(a.State), a.RuleName)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.UnixMilli(a.Timestamp).UTC().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nRule: %s\r\nValue: %g\r\nThreshold: %g\r\n", a.Message, a.RuleID, a.Value, a.Threshold)
+
+	return smtp.SendMail(s.addr, auth, s.from, s.to, msg.Bytes())
+}
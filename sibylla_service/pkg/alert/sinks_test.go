@@ -0,0 +1,287 @@
+package alert
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+)
+
+var testAlert = Alert{
+	ID:        "btc:firing:1700000000000:1",
+	RuleID:    "btc",
+	RuleName:  "BTC above 100",
+	State:     AlertFiring,
+	Value:     101,
+	Threshold: 100,
+	Message:   "BTC above 100: binance BTCUSDT price 101 above 100",
+	Timestamp: 1700000000000,
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(exchangeconfig.AlertSink{Name: "hook", Type: exchangeconfig.SinkWebhook, URL: server.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Alert
+	if err := json.Unmarshal(body, &got); err != nil || got != testAlert {
+		t.Errorf("posted %s (%v), want the alert", body, err)
+	}
+	timestamp := header.Get(TimestampHeader)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); header.Get(SignatureHeader) != want {
+		t.Errorf("signature %q, want %q", header.Get(SignatureHeader), want)
+	}
+	if header.Get("Content-Type") != "application/json" || timestamp == "" {
+		t.Errorf("headers %v", header)
+	}
+}
+
+func TestWebhookUnsignedAndFailing(t *testing.T) {
+	var signed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed = r.Header.Get(SignatureHeader) != ""
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	sink, _ := NewSink(exchangeconfig.AlertSink{Name: "hook", Type: exchangeconfig.SinkWebhook, URL: server.URL})
+	if err := sink.Send(testAlert); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("Send = %v, want the status as an error", err)
+	}
+	if signed {
+		t.Error("signed without a secret")
+	}
+}
+
+// smtpStub accepts one SMTP session, records the envelope and message, and
+// accepts any PLAIN credentials.
+type smtpStub struct {
+	addr string
+	done chan struct{}
+
+	mu         sync.Mutex
+	auth       string
+	from       string
+	recipients []string
+	data       string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	stub := &smtpStub{addr: listener.Addr().String(), done: make(chan struct{})}
+
+	go func() {
+		defer close(stub.done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		stub.serve(textproto.NewConn(conn))
+	}()
+	return stub
+}
+
+func (s *smtpStub) serve(c *textproto.Conn) {
+	c.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		s.mu.Lock()
+		switch verb {
+		case "EHLO", "HELO":
+			c.PrintfLine("250-stub")
+			c.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+			c.PrintfLine("235 ok")
+		case "MAIL":
+			s.from = line
+			c.PrintfLine("250 ok")
+		case "RCPT":
+			s.recipients = append(s.recipients, line)
+			c.PrintfLine("250 ok")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, _ := c.ReadDotBytes()
+			s.data = string(data)
+			c.PrintfLine("250 ok")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestSMTPMessage(t *testing.T) {
+	stub := newSMTPStub(t)
+	sink, err := NewSink(exchangeconfig.AlertSink{
+		Name:     "mail",
+		Type:     exchangeconfig.SinkSMTP,
+		Addr:     stub.addr,
+		Username: "alerts",
+		Password: "pw",
+		From:     "alerts@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(testAlert); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stub.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session didn't end")
+	}
+
+	if credentials, _ := base64.StdEncoding.DecodeString(stub.auth); string(credentials) != "\x00alerts\x00pw" {
+		t.Errorf("authenticated with %q", credentials)
+	}
+	if stub.from != "MAIL FROM:<alerts@example.com>" || len(stub.recipients) != 2 {
+		t.Errorf("envelope %q to %q", stub.from, stub.recipients)
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(stub.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Get("Subject") != "[sibylla] FIRING BTC above 100" || msg.Get("To") != "ops@example.com, oncall@example.com" {
+		t.Errorf("headers %v", msg)
+	}
+	if msg.Get("Date") != time.UnixMilli(testAlert.Timestamp).UTC().Format(time.RFC1123Z) {
+		t.Errorf("Date %q", msg.Get("Date"))
+	}
+	for _, want := range []string{testAlert.Message, "Rule: btc", "Value: 101", "Threshold: 100"} {
+		if !strings.Contains(stub.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, stub.data)
+		}
+	}
+}
+
+func TestSMTPSubjectCantInjectHeaders(t *testing.T) {
+	stub := newSMTPStub(t)
+	sink, err := NewSink(exchangeconfig.AlertSink{Name: "mail", Type: exchangeconfig.SinkSMTP, Addr: stub.addr, From: "alerts@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testAlert
+	a.RuleName = "BTC\r\nBcc: someone@example.com"
+	if err := sink.Send(a); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stub.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session didn't end")
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(stub.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := msg.Get("Bcc"); bcc != "" {
+		t.Errorf("rule name injected a Bcc header: %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Get("Subject"))
+	if err != nil || subject != "[sibylla] FIRING "+a.RuleName {
+		t.Errorf("Subject decodes to %q (%v)", subject, err)
+	}
+}
+
+func TestNewSinkValidation(t *testing.T) {
+	for _, config := range []exchangeconfig.AlertSink{
+		{Type: exchangeconfig.SinkLog},
+		{Name: "hook", Type: exchangeconfig.SinkWebhook},
+		{Name: "mail", Type: exchangeconfig.SinkSMTP, Addr: "localhost:25"},
+		{Name: "pager", Type: "pager"},
+	} {
+		if _, err := NewSink(config); err == nil {
+			t.Errorf("NewSink(%+v) accepted an invalid sink", config)
+		}
+	}
+}
+
+// blockingSink holds every delivery until released.
+type blockingSink struct {
+	release chan struct{}
+	sent    chan Alert
+}
+
+func (s blockingSink) Name() string { return "slow" }
+
+func (s blockingSink) Send(a Alert) error {
+	<-s.release
+	s.sent <- a
+	return nil
+}
+
+func TestDeliveryQueueIsBounded(t *testing.T) {
+	sink := blockingSink{release: make(chan struct{}), sent: make(chan Alert, 10)}
+	d := newDelivery(sink, 2)
+	go d.run()
+
+	for i := 0; i < 5; i++ {
+		a := testAlert
+		a.ID = string(rune('a' + i))
+		d.enqueue(a)
+		if i == 0 {
+			// Let the worker take the first one, then fill the queue
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	close(sink.release)
+
+	var ids []string
+	for len(ids) < 3 {
+		select {
+		case a := <-sink.sent:
+			ids = append(ids, a.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivered %v, want 3 notifications", ids)
+		}
+	}
+	if strings.Join(ids, "") != "abc" || d.dropped != 2 {
+		t.Errorf("delivered %v and dropped %d, want a, b, c in order and 2 dropped", ids, d.dropped)
+	}
+}
@@ -0,0 +1,302 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/conversion"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// StoreKey is the stream holding closed opportunities.
+const StoreKey = "arbitrage:opportunities"
+
+// Opportunity states
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Opportunity is a window during which buying on one exchange and selling on
+// another clears every cost by at least the configured threshold. Pair is the
+// pair both venues trade or, when they quote the market in different pegged
+// assets, the canonical market (see models.CanonicalPair), with prices
+// converted into its quote asset at the given rates.
+type Opportunity struct {
+	ID           string  `json:"id"`
+	State        string  `json:"state"`
+	Pair         string  `json:"pair"`
+	BuyExchange  string  `json:"buy_exchange"`
+	SellExchange string  `json:"sell_exchange"`
+	BuyPair      string  `json:"buy_pair"`
+	SellPair     string  `json:"sell_pair"`
+	BuyRate      float64 `json:"buy_rate"`  // Pair's quote units per unit of BuyPair's quote
+	SellRate     float64 `json:"sell_rate"` // Pair's quote units per unit of SellPair's quote
+	BuyPrice     float64 `json:"buy_price"`
+	SellPrice    float64 `json:"sell_price"`
+	GrossBps     float64 `json:"gross_bps"`
+	NetBps       float64 `json:"net_bps"` // after fees and transfer cost at MaxSize
+	PeakNetBps   float64 `json:"peak_net_bps"`
+	MaxSize      float64 `json:"max_size"`   // base units both venues traded in the size window
+	NetProfit    float64 `json:"net_profit"` // quote units at MaxSize
+	OpenedAt     int64   `json:"opened_at"`
+	UpdatedAt    int64   `json:"updated_at"`
+	ClosedAt     int64   `json:"closed_at,omitempty"`
+	DurationMs   int64   `json:"duration_ms"`
+	// Actionable is set once the opportunity has outlasted both venues' execution latency
+	Actionable bool `json:"actionable"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (o Opportunity) MarshalBinary() ([]byte, error) {
+	return json.Marshal(o)
+}
+
+// Detector watches the latest quotes across exchanges and tracks arbitrage
+// opportunities net of taker fees, withdrawal costs and available size.
+// Venues quoting a market in different pegged quote assets, such as BTCUSDT
+// and BTCUSD, are compared at the converter's live rate between the two.
+type Detector struct {
+	mu          sync.Mutex
+	config      exchangeconfig.ArbitrageConfig
+	tracker     *market.Tracker
+	converter   *conversion.Converter
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	flow        map[string]*market.RollingSum // traded base volume by exchange:pair
+	open        map[string]*Opportunity       // by pair:buy:sell
+}
+
+// NewDetector creates an arbitrage detector. Without a converter only venues
+// quoting the same pair are compared.
+func NewDetector(tracker *market.Tracker, converter *conversion.Converter, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.ArbitrageConfig) *Detector {
+	return &Detector{
+		config:      config.WithDefaults(),
+		tracker:     tracker,
+		converter:   converter,
+		redisClient: redisClient,
+		bus:         b,
+		flow:        make(map[string]*market.RollingSum),
+		open:        make(map[string]*Opportunity),
+	}
+}
+
+// OnTrade records the trade's size and re-evaluates both directions between
+// its exchange and every other exchange quoting the same market. The tracker
+// must already hold the trade.
+func (d *Detector) OnTrade(t models.Trade) {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	flowKey := t.Exchange + ":" + t.Pair
+	if d.flow[flowKey] == nil {
+		d.flow[flowKey] = market.NewRollingSum(d.config.SizeWindow.Duration)
+	}
+	d.flow[flowKey].Add(now, t.Quantity)
+
+	self, ok := d.tracker.Last(t.Exchange, t.Pair)
+	if !ok {
+		return
+	}
+	canonical := models.CanonicalPair(t.Pair)
+	for _, other := range d.tracker.Market(t.Pair) {
+		if other.Exchange == t.Exchange {
+			continue
+		}
+		d.evaluate(canonical, self, other, now)
+		d.evaluate(canonical, other, self, now)
+	}
+}
+
+// Run closes opportunities whose quotes have gone stale without a new trade.
+// It blocks, call it in a goroutine.
+func (d *Detector) Run(tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		d.mu.Lock()
+		for _, o := range d.open {
+			buy, okBuy := d.tracker.Last(o.BuyExchange, o.BuyPair)
+			sell, okSell := d.tracker.Last(o.SellExchange, o.SellPair)
+			if !okBuy || !okSell || d.stale(buy, now) || d.stale(sell, now) {
+				d.close(o, now)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// evaluate checks buying on buy and selling on sell, both quoting the canonical pair.
+func (d *Detector) evaluate(canonical string, buy, sell market.Quote, now time.Time) {
+	key := openKey(canonical, buy.Exchange, sell.Exchange)
+	o := d.open[key]
+
+	pair, buyRate, sellRate := buy.Pair, 1.0, 1.0
+	okBuy, okSell := true, true
+	if buy.Pair != sell.Pair {
+		pair = canonical
+		buyRate, okBuy = d.rate(buy.Pair, canonical)
+		sellRate, okSell = d.rate(sell.Pair, canonical)
+	}
+	if !okBuy || !okSell || d.stale(buy, now) || d.stale(sell, now) || sell.Price*sellRate <= buy.Price*buyRate {
+		if o != nil {
+			d.close(o, now)
+		}
+		return
+	}
+	buy.Price *= buyRate
+	sell.Price *= sellRate
+
+	size := d.availableSize(buy, sell, now)
+	gross, net, profit := d.edge(buy, sell, size)
+	if net < d.config.ThresholdBps {
+		if o != nil {
+			d.close(o, now)
+		}
+		return
+	}
+
+	opened := o == nil
+	if opened {
+		o = &Opportunity{
+			ID:           fmt.Sprintf("%s:%d", key, now.UnixMilli()),
+			State:        StateOpen,
+			Pair:         pair,
+			BuyExchange:  buy.Exchange,
+			SellExchange: sell.Exchange,
+			BuyPair:      buy.Pair,
+			SellPair:     sell.Pair,
+			OpenedAt:     now.UnixMilli(),
+		}
+		d.open[key] = o
+	}
+
+	o.BuyRate, o.SellRate = buyRate, sellRate
+	o.BuyPrice, o.SellPrice = buy.Price, sell.Price
+	o.GrossBps, o.NetBps = gross, net
+	o.MaxSize, o.NetProfit = size, profit
+	if net > o.PeakNetBps {
+		o.PeakNetBps = net
+	}
+	o.UpdatedAt = now.UnixMilli()
+	o.DurationMs = o.UpdatedAt - o.OpenedAt
+	o.Actionable = time.Duration(o.DurationMs)*time.Millisecond >= d.requiredLatency(buy.Exchange, sell.Exchange)
+
+	if opened {
+		d.publish(*o)
+	}
+}
+
+// edge returns the gross and net edge in bps and the net profit in quote units
+// of buying size on buy and selling it on sell, moving the base asset across.
+// Both prices must be in the same quote asset.
+func (d *Detector) edge(buy, sell market.Quote, size float64) (gross, net, profit float64) {
+	gross = (sell.Price - buy.Price) / buy.Price * 10000
+	net = gross - d.config.Venues[buy.Exchange].TakerFeeBps - d.config.Venues[sell.Exchange].TakerFeeBps
+
+	if size <= 0 {
+		return gross, net, 0
+	}
+	if base, _, ok := models.SplitPair(buy.Pair); ok {
+		withdrawal := d.config.Venues[buy.Exchange].WithdrawalFees[base]
+		net -= withdrawal / size * 10000
+	}
+	profit = net / 10000 * buy.Price * size
+	return gross, net, profit
+}
+
+// rate returns what one unit of pair's quote asset is worth in the canonical
+// pair's quote asset: 1 for the canonical pair itself, otherwise the
+// converter's rate, which never goes through the base asset being compared.
+func (d *Detector) rate(pair, canonical string) (float64, bool) {
+	if pair == canonical {
+		return 1, true
+	}
+	if d.converter == nil {
+		return 0, false
+	}
+	_, to, ok := models.SplitPair(canonical)
+	if !ok {
+		return 0, false
+	}
+	rate, err := d.converter.QuoteRate(pair, to)
+	if err != nil || rate.Rate <= 0 {
+		return 0, false
+	}
+	return rate.Rate, true
+}
+
+// availableSize approximates executable size by the volume both venues traded recently.
+func (d *Detector) availableSize(buy, sell market.Quote, now time.Time) float64 {
+	buyFlow, sellFlow := d.flow[buy.Exchange+":"+buy.Pair], d.flow[sell.Exchange+":"+sell.Pair]
+	if buyFlow == nil || sellFlow == nil {
+		return 0
+	}
+	size := buyFlow.Sum(now)
+	if s := sellFlow.Sum(now); s < size {
+		size = s
+	}
+	return size
+}
+
+func (d *Detector) requiredLatency(buyExchange, sellExchange string) time.Duration {
+	latency := d.config.Venues[buyExchange].Latency.Duration
+	if l := d.config.Venues[sellExchange].Latency.Duration; l > latency {
+		latency = l
+	}
+	return latency
+}
+
+func (d *Detector) stale(q market.Quote, now time.Time) bool {
+	return q.Age(now) > d.config.MaxQuoteAge.Duration
+}
+
+func (d *Detector) close(o *Opportunity, now time.Time) {
+	delete(d.open, openKey(models.CanonicalPair(o.Pair), o.BuyExchange, o.SellExchange))
+	o.State = StateClosed
+	o.ClosedAt = now.UnixMilli()
+	o.DurationMs = o.ClosedAt - o.OpenedAt
+	d.publish(*o)
+
+	if d.redisClient == nil {
+		return
+	}
+	if err := d.redisClient.AppendToStream(StoreKey, *o, d.config.MaxStored, 0); err != nil {
+		log.Printf("Could not store arbitrage opportunity %s: %v", o.ID, err)
+	}
+}
+
+// openKey identifies an open opportunity by market and direction.
+func openKey(canonical, buyExchange, sellExchange string) string {
+	return canonical + ":" + buyExchange + ":" + sellExchange
+}
+
+func (d *Detector) publish(o Opportunity) {
+	if d.bus != nil {
+		d.bus.Publish(bus.TopicArbitrage, o)
+	}
+}
+
+// Open returns the currently open opportunities, best net edge first.
+func (d *Detector) Open() []Opportunity {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	opportunities := make([]Opportunity, 0, len(d.open))
+	for _, o := range d.open {
+		opportunities = append(opportunities, *o)
+	}
+	sort.Slice(opportunities, func(i, j int) bool { return opportunities[i].NetBps > opportunities[j].NetBps })
+	return opportunities
+}
@@ -0,0 +1,124 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/conversion"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+func TestOpportunityNetOfCosts(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	d := NewDetector(tracker, nil, redisClient, nil, exchangeconfig.ArbitrageConfig{
+		ThresholdBps: 50,
+		Venues: map[string]exchangeconfig.VenueCosts{
+			"binance": {TakerFeeBps: 10, WithdrawalFees: map[string]float64{"BTC": 0.001}},
+			"kraken":  {TakerFeeBps: 10},
+		},
+	})
+	trade := func(exchange string, price, quantity float64) {
+		tr := models.Trade{Exchange: exchange, Pair: "BTCUSDT", Price: price, Quantity: quantity}
+		tracker.Update(tr)
+		d.OnTrade(tr)
+	}
+
+	trade("binance", 100, 2)
+	trade("kraken", 101, 1)
+
+	open := d.Open()
+	if len(open) != 1 {
+		t.Fatalf("got %d open opportunities, want 1", len(open))
+	}
+	o := open[0]
+	if o.BuyExchange != "binance" || o.SellExchange != "kraken" || o.MaxSize != 1 {
+		t.Errorf("unexpected opportunity %+v", o)
+	}
+	// 100 bps gross, less 20 bps of fees and a 0.001 BTC withdrawal on 1 BTC
+	if math.Abs(o.GrossBps-100) > 1e-9 || math.Abs(o.NetBps-70) > 1e-9 || math.Abs(o.NetProfit-0.7) > 1e-9 {
+		t.Errorf("edge is %v gross, %v net, %v profit, want 100, 70, 0.7", o.GrossBps, o.NetBps, o.NetProfit)
+	}
+
+	// Prices converge, the opportunity closes and is stored
+	trade("kraken", 100.2, 1)
+	if open := d.Open(); len(open) != 0 {
+		t.Errorf("opportunity still open below the threshold: %+v", open)
+	}
+	stored, err := redisClient.GetStream(StoreKey, 10)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("got %d stored opportunities (%v), want 1", len(stored), err)
+	}
+	var closed Opportunity
+	if err := json.Unmarshal([]byte(stored[0]), &closed); err != nil {
+		t.Fatal(err)
+	}
+	if closed.State != StateClosed || closed.ID != o.ID || closed.PeakNetBps != o.NetBps {
+		t.Errorf("unexpected stored opportunity %+v", closed)
+	}
+}
+
+func TestNoOpportunityBelowThreshold(t *testing.T) {
+	tracker := market.NewTracker()
+	d := NewDetector(tracker, nil, nil, nil, exchangeconfig.ArbitrageConfig{
+		ThresholdBps: 50,
+		Venues: map[string]exchangeconfig.VenueCosts{
+			"binance": {TakerFeeBps: 30},
+			"kraken":  {TakerFeeBps: 30},
+		},
+	})
+	for _, tr := range []models.Trade{
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1},
+		{Exchange: "kraken", Pair: "BTCUSDT", Price: 101, Quantity: 1},
+	} {
+		tracker.Update(tr)
+		d.OnTrade(tr)
+	}
+	// 100 bps gross leaves 40 bps after fees
+	if open := d.Open(); len(open) != 0 {
+		t.Errorf("got opportunities below the threshold: %+v", open)
+	}
+}
+
+func TestOpportunityAcrossPeggedQuotes(t *testing.T) {
+	tracker := market.NewTracker()
+	d := NewDetector(tracker, conversion.NewConverter(tracker, exchangeconfig.ConversionConfig{}), nil, nil, exchangeconfig.ArbitrageConfig{ThresholdBps: 20})
+	trade := func(exchange, pair string, price float64) {
+		tr := models.Trade{Exchange: exchange, Pair: pair, Price: price, Quantity: 1}
+		tracker.Update(tr)
+		d.OnTrade(tr)
+	}
+
+	// USDT trades at a premium: 100 USDT is 100.5 USD, not 100
+	trade("kraken", "USDTUSD", 1.005)
+	trade("binance", "BTCUSDT", 100)
+	trade("kraken", "BTCUSD", 101)
+
+	open := d.Open()
+	if len(open) != 1 {
+		t.Fatalf("got %d open opportunities, want 1", len(open))
+	}
+	o := open[0]
+	if o.Pair != "BTCUSD" || o.BuyExchange != "binance" || o.BuyPair != "BTCUSDT" || o.SellPair != "BTCUSD" {
+		t.Errorf("unexpected opportunity %+v", o)
+	}
+	if math.Abs(o.BuyRate-1.005) > 1e-9 || o.SellRate != 1 || math.Abs(o.BuyPrice-100.5) > 1e-9 {
+		t.Errorf("buy side at %v (rate %v), sell side rate %v, want 100.5 at 1.005 and 1", o.BuyPrice, o.BuyRate, o.SellRate)
+	}
+	if want := 0.5 / 100.5 * 10000; math.Abs(o.GrossBps-want) > 1e-9 {
+		t.Errorf("gross edge %v bps, want %v", o.GrossBps, want)
+	}
+
+	// A USDT premium as wide as the price gap leaves nothing to take
+	trade("kraken", "USDTUSD", 1.01)
+	trade("binance", "BTCUSDT", 100)
+	if open := d.Open(); len(open) != 0 {
+		t.Errorf("opportunity still open once converted at the USDT premium: %+v", open)
+	}
+}
@@ -0,0 +1,219 @@
+package average
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+)
+
+// Average is the rolling VWAP and TWAP of a pair over one window, on one
+// exchange or across every exchange when Exchange is empty. The aggregate VWAP
+// weighs every venue's trades; the aggregate TWAP is the mean of the venues'.
+type Average struct {
+	Exchange  string  `json:"exchange,omitempty"`
+	Pair      string  `json:"pair"`
+	Window    string  `json:"window"`
+	VWAP      float64 `json:"vwap"` // zero when nothing traded in the window
+	TWAP      float64 `json:"twap"`
+	Volume    float64 `json:"volume"`
+	Trades    int     `json:"trades"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+type venue struct {
+	exchange string
+	windows  []*rolling // in the order of Calculator.windows
+}
+
+// Calculator keeps rolling VWAP and TWAP for every exchange and pair over a
+// set of windows, updated incrementally on each trade.
+type Calculator struct {
+	mu      sync.Mutex
+	windows []time.Duration
+	pairs   map[string]map[string]*venue // pair -> exchange -> venue
+}
+
+func NewCalculator(config exchangeconfig.AveragesConfig) *Calculator {
+	var windows []time.Duration
+	for _, w := range config.WithDefaults().Windows {
+		if w.Duration > 0 {
+			windows = append(windows, w.Duration)
+		}
+	}
+	return &Calculator{
+		windows: windows,
+		pairs:   make(map[string]map[string]*venue),
+	}
+}
+
+// OnTrade adds a trade to every window of its exchange and pair.
+func (c *Calculator) OnTrade(t models.Trade) {
+	if t.Price <= 0 || t.Quantity < 0 {
+		return
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	venues, ok := c.pairs[t.Pair]
+	if !ok {
+		venues = make(map[string]*venue)
+		c.pairs[t.Pair] = venues
+	}
+	v, ok := venues[t.Exchange]
+	if !ok {
+		v = &venue{exchange: t.Exchange}
+		for _, w := range c.windows {
+			v.windows = append(v.windows, newRolling(w))
+		}
+		venues[t.Exchange] = v
+	}
+	for _, r := range v.windows {
+		r.add(now, t.Price, t.Quantity)
+	}
+}
+
+// Windows returns the windows averaged over.
+func (c *Calculator) Windows() []time.Duration {
+	return append([]time.Duration(nil), c.windows...)
+}
+
+// Get returns the average of a pair over a window on an exchange, or across
+// exchanges when exchange is empty. It fails when the window isn't one of the
+// calculator's or the pair hasn't traded there.
+func (c *Calculator) Get(exchange, pair string, window time.Duration) (Average, bool) {
+	i := c.windowIndex(window)
+	if i < 0 {
+		return Average{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.average(exchange, pair, i, time.Now())
+}
+
+// All returns the averages of a pair over every window on an exchange, or
+// across exchanges when exchange is empty.
+func (c *Calculator) All(exchange, pair string) []Average {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var averages []Average
+	for i := range c.windows {
+		if a, ok := c.average(exchange, pair, i, now); ok {
+			averages = append(averages, a)
+		}
+	}
+	return averages
+}
+
+// Exchanges returns the exchanges a pair has traded on, sorted.
+func (c *Calculator) Exchanges(pair string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exchanges := make([]string, 0, len(c.pairs[pair]))
+	for exchange := range c.pairs[pair] {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
+
+// Pairs returns every pair that has traded, sorted.
+func (c *Calculator) Pairs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pairs := make([]string, 0, len(c.pairs))
+	for pair := range c.pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Price returns the price an engine should use for a venue's quote: the
+// configured rolling average, or the last trade price when the source asks for
+// it, the window isn't tracked or nothing traded in it. It is safe on a nil
+// Calculator.
+func (c *Calculator) Price(q market.Quote, source exchangeconfig.PriceSource) float64 {
+	if c == nil || source.Kind == "" || source.Kind == exchangeconfig.PriceLast {
+		return q.Price
+	}
+
+	a, ok := c.Get(q.Exchange, q.Pair, source.Window.Duration)
+	if !ok || a.Trades == 0 {
+		return q.Price
+	}
+	switch source.Kind {
+	case exchangeconfig.PriceVWAP:
+		if a.Volume > 0 {
+			return a.VWAP
+		}
+	case exchangeconfig.PriceTWAP:
+		return a.TWAP
+	}
+	return q.Price
+}
+
+func (c *Calculator) windowIndex(window time.Duration) int {
+	for i, w := range c.windows {
+		if w == window {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Calculator) average(exchange, pair string, i int, now time.Time) (Average, bool) {
+	var venues []*venue
+	if exchange != "" {
+		if v, ok := c.pairs[pair][exchange]; ok {
+			venues = append(venues, v)
+		}
+	} else {
+		for _, v := range c.pairs[pair] {
+			venues = append(venues, v)
+		}
+	}
+	if len(venues) == 0 {
+		return Average{}, false
+	}
+
+	a := Average{Exchange: exchange, Pair: pair, Window: WindowName(c.windows[i]), Timestamp: now.UnixMilli()}
+	var pv, twaps float64
+	for _, v := range venues {
+		r := v.windows[i]
+		s := r.sums(now)
+		pv += s.pv
+		a.Volume += s.volume
+		a.Trades += s.trades
+		twaps += s.twap(r.last)
+	}
+	if a.Volume > 0 {
+		a.VWAP = pv / a.Volume
+	}
+	a.TWAP = twaps / float64(len(venues))
+	return a, true
+}
+
+// WindowName formats a window the way it is written in config, "5m" rather than "5m0s".
+func WindowName(window time.Duration) string {
+	name := window.String()
+	if strings.HasSuffix(name, "m0s") {
+		name = strings.TrimSuffix(name, "0s")
+	}
+	if strings.HasSuffix(name, "h0m") {
+		name = strings.TrimSuffix(name, "0m")
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package average
+
+import (
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+)
+
+func TestCalculatorAggregatesVenues(t *testing.T) {
+	c := NewCalculator(exchangeconfig.AveragesConfig{})
+	c.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1})
+	c.OnTrade(models.Trade{Exchange: "kraken", Pair: "BTCUSDT", Price: 110, Quantity: 3})
+
+	if len(c.Windows()) != len(exchangeconfig.DefaultAverageWindows) {
+		t.Errorf("got windows %v, want the defaults", c.Windows())
+	}
+	a, ok := c.Get("", "BTCUSDT", time.Minute)
+	if !ok || a.VWAP != 107.5 || a.Volume != 4 || a.Trades != 2 || a.Window != "1m" {
+		t.Errorf("aggregate = %+v, want VWAP 107.5 over both venues", a)
+	}
+	if a, ok := c.Get("kraken", "BTCUSDT", time.Minute); !ok || a.VWAP != 110 || a.Exchange != "kraken" {
+		t.Errorf("kraken = %+v, want its own VWAP", a)
+	}
+	if _, ok := c.Get("", "BTCUSDT", 2*time.Minute); ok {
+		t.Error("got an average over a window that isn't tracked")
+	}
+	if all := c.All("", "BTCUSDT"); len(all) != len(c.Windows()) {
+		t.Errorf("got %d windows from All, want %d", len(all), len(c.Windows()))
+	}
+	if exchanges := c.Exchanges("BTCUSDT"); len(exchanges) != 2 || exchanges[0] != "binance" {
+		t.Errorf("Exchanges = %v", exchanges)
+	}
+}
+
+func TestPriceSource(t *testing.T) {
+	c := NewCalculator(exchangeconfig.AveragesConfig{Windows: []exchangeconfig.Duration{{Duration: time.Minute}}})
+	c.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1})
+	c.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 110, Quantity: 1})
+	q := market.Quote{Exchange: "binance", Pair: "BTCUSDT", Price: 110}
+
+	tests := []struct {
+		source exchangeconfig.PriceSource
+		want   float64
+	}{
+		{exchangeconfig.PriceSource{}, 110},
+		{exchangeconfig.PriceSource{Kind: exchangeconfig.PriceVWAP, Window: exchangeconfig.Duration{Duration: time.Minute}}, 105},
+		{exchangeconfig.PriceSource{Kind: exchangeconfig.PriceVWAP, Window: exchangeconfig.Duration{Duration: time.Hour}}, 110}, // untracked window
+	}
+	for _, tt := range tests {
+		if got := c.Price(q, tt.source); got != tt.want {
+			t.Errorf("Price(%+v) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+	var none *Calculator
+	if got := none.Price(q, tests[1].source); got != 110 {
+		t.Errorf("nil calculator Price = %v, want the last price", got)
+	}
+}
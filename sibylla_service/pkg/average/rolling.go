@@ -0,0 +1,105 @@
+package average
+
+import (
+	"time"
+
+	"sibylla_service/pkg/market"
+)
+
+type bucket struct {
+	pv     float64 // sum of price * quantity
+	volume float64
+	trades int
+	area   float64 // integral of the last price over time, price * seconds
+}
+
+// rolling holds the VWAP and TWAP state of one venue over one window.
+type rolling struct {
+	window  time.Duration
+	buckets *market.Buckets[bucket]
+	first   time.Time // first trade, TWAP isn't defined before it
+	last    float64
+	lastAt  time.Time
+}
+
+func newRolling(window time.Duration) *rolling {
+	return &rolling{window: window, buckets: market.NewBuckets[bucket](window)}
+}
+
+// add records a trade. Times must not go backwards.
+func (r *rolling) add(at time.Time, price, quantity float64) {
+	if r.first.IsZero() {
+		r.first = at
+	} else {
+		r.integrate(r.lastAt, at)
+	}
+	r.last, r.lastAt = price, at
+
+	b := r.buckets.At(at)
+	b.pv += price * quantity
+	b.volume += quantity
+	b.trades++
+}
+
+// integrate adds the last price held from one time to another to the TWAP
+// buckets it spans. Anything older than the window is skipped.
+func (r *rolling) integrate(from, to time.Time) {
+	if start := to.Add(-r.window); from.Before(start) {
+		from = start
+	}
+	for from.Before(to) {
+		end := r.buckets.End(from)
+		if end.After(to) {
+			end = to
+		}
+		r.buckets.At(from).area += r.last * end.Sub(from).Seconds()
+		from = end
+	}
+}
+
+// sums is the content of a window at a point in time.
+type sums struct {
+	pv, volume float64
+	trades     int
+	area       float64
+	covered    time.Duration // time the TWAP area covers
+}
+
+// sums adds up the buckets inside the window ending at now, plus the time the
+// last price has been held since the last trade.
+func (r *rolling) sums(now time.Time) sums {
+	var s sums
+	if r.first.IsZero() {
+		return s
+	}
+
+	r.buckets.Each(now, func(b *bucket) {
+		s.pv += b.pv
+		s.volume += b.volume
+		s.trades += b.trades
+		s.area += b.area
+	})
+
+	start := r.buckets.Start(now)
+	if r.first.After(start) {
+		start = r.first
+	}
+	held := r.lastAt
+	if held.Before(start) {
+		held = start
+	}
+	if now.After(held) {
+		s.area += r.last * now.Sub(held).Seconds()
+	}
+	s.covered = now.Sub(start)
+	return s
+}
+
+// twap returns the time-weighted average price, or the last price when the
+// window covers no time yet.
+func (s sums) twap(last float64) float64 {
+	if s.covered <= 0 {
+		return last
+	}
+	return s.area / s.covered.Seconds()
+}
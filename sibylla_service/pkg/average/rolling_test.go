@@ -0,0 +1,54 @@
+package average
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRollingVWAPAndTWAP(t *testing.T) {
+	r := newRolling(time.Minute)
+	t0 := time.Unix(1000, 0)
+	r.add(t0, 100, 1)
+	r.add(t0.Add(30*time.Second), 110, 3)
+
+	s := r.sums(t0.Add(40 * time.Second))
+	if s.volume != 4 || s.trades != 2 || s.pv/s.volume != 107.5 {
+		t.Errorf("VWAP over %+v, want 107.5 on 4 units", s)
+	}
+	// 100 held for 30s, then 110 for 10s
+	if twap := s.twap(r.last); math.Abs(twap-102.5) > 1e-9 {
+		t.Errorf("TWAP = %v, want 102.5", twap)
+	}
+
+	// Both trades have left the window, the last price has been held throughout
+	s = r.sums(t0.Add(90 * time.Second))
+	if s.volume != 0 || s.trades != 0 {
+		t.Errorf("expired trades still counted: %+v", s)
+	}
+	if twap := s.twap(r.last); math.Abs(twap-110) > 1e-9 {
+		t.Errorf("TWAP after expiry = %v, want 110", twap)
+	}
+}
+
+func TestRollingBeforeFirstTrade(t *testing.T) {
+	r := newRolling(time.Minute)
+	if s := r.sums(time.Unix(1000, 0)); s.covered != 0 || s.twap(0) != 0 {
+		t.Errorf("empty window sums %+v", s)
+	}
+}
+
+func TestWindowName(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Minute:      "1m",
+		5 * time.Minute:  "5m",
+		time.Hour:        "1h",
+		24 * time.Hour:   "24h",
+		90 * time.Second: "1m30s",
+	}
+	for window, want := range tests {
+		if got := WindowName(window); got != want {
+			t.Errorf("WindowName(%v) = %s, want %s", window, got, want)
+		}
+	}
+}
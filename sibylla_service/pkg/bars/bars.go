@@ -0,0 +1,106 @@
+package bars
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Bar types
+const (
+	TypeTick   = "tick"   // closes every N trades
+	TypeVolume = "volume" // closes every N base units
+	TypeDollar = "dollar" // closes every N quote notional
+)
+
+// Types lists every supported bar type.
+var Types = []string{TypeTick, TypeVolume, TypeDollar}
+
+// ParseType validates a bar type name.
+func ParseType(name string) (string, error) {
+	for _, t := range Types {
+		if t == name {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown bar type %q", name)
+}
+
+// Bar is an OHLCV summary of trades grouped by activity rather than time.
+// Times are Unix milliseconds of the first and last trade in the bar.
+type Bar struct {
+	Exchange   string  `json:"exchange"`
+	Pair       string  `json:"pair"`
+	Type       string  `json:"type"`
+	Threshold  float64 `json:"threshold"`
+	OpenTime   int64   `json:"open_time"`
+	CloseTime  int64   `json:"close_time"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Close      float64 `json:"close"`
+	Volume     float64 `json:"volume"`
+	BuyVolume  float64 `json:"buy_volume"`
+	SellVolume float64 `json:"sell_volume"`
+	Notional   float64 `json:"notional"`
+	Trades     int64   `json:"trades"`
+	Closed     bool    `json:"closed"`
+	// Seq orders bars closing in the same millisecond, as a trade split across bars does
+	Seq int64 `json:"seq,omitempty"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (b Bar) MarshalBinary() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+// seqSlots is the number of store scores each millisecond spans, so bars
+// closing in the same millisecond sort by Seq on whole, exactly held scores.
+const seqSlots = 1000
+
+// score is the bar's position in the store: its close time, then Seq. Storing
+// at an existing score replaces that bar.
+func (b Bar) score() float64 {
+	return float64(b.CloseTime*seqSlots + b.Seq)
+}
+
+// Key is the store key holding closed bars for an exchange, pair and bar type.
+func Key(exchange, pair, barType string) string {
+	return "bars:" + exchange + ":" + pair + ":" + barType
+}
+
+func (b *Bar) add(price, quantity float64, timestamp int64, isBuyerMaker bool) {
+	if b.Trades == 0 {
+		b.Open, b.High, b.Low = price, price, price
+		b.OpenTime = timestamp
+	}
+	if price > b.High {
+		b.High = price
+	}
+	if price < b.Low {
+		b.Low = price
+	}
+	b.Close = price
+	b.CloseTime = timestamp
+
+	b.Volume += quantity
+	b.Notional += price * quantity
+	// The aggressor is the taker: when the buyer is the maker, the taker sold
+	if isBuyerMaker {
+		b.SellVolume += quantity
+	} else {
+		b.BuyVolume += quantity
+	}
+	b.Trades++
+}
+
+// progress returns how much of the bar's threshold has been filled.
+func (b *Bar) progress() float64 {
+	switch b.Type {
+	case TypeTick:
+		return float64(b.Trades)
+	case TypeVolume:
+		return b.Volume
+	default:
+		return b.Notional
+	}
+}
@@ -0,0 +1,148 @@
+package bars
+
+import (
+	"log"
+	"sync"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// fillTolerance absorbs float rounding when a split trade exactly fills a bar.
+const fillTolerance = 1e-9
+
+type seriesKey struct {
+	exchange string
+	pair     string
+	barType  string
+}
+
+// Builder aggregates trades into tick, volume and dollar bars using per
+// instrument thresholds. Closed bars are persisted to Redis and published on the bus.
+type Builder struct {
+	mu          sync.Mutex
+	config      exchangeconfig.BarConfig
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	current     map[seriesKey]*Bar
+	lastClosed  map[seriesKey]Bar     // newest closed bar per series
+	lastScore   map[seriesKey]float64 // store score of the newest closed bar per series
+}
+
+func NewBuilder(redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.BarConfig) *Builder {
+	return &Builder{
+		config:      config.WithDefaults(),
+		redisClient: redisClient,
+		bus:         b,
+		current:     make(map[seriesKey]*Bar),
+		lastClosed:  make(map[seriesKey]Bar),
+		lastScore:   make(map[seriesKey]float64),
+	}
+}
+
+// AddTrade folds a trade into every bar type enabled for its instrument.
+func (b *Builder) AddTrade(t models.Trade) {
+	if t.Price <= 0 || t.Quantity <= 0 {
+		return
+	}
+	thresholds := b.config.For(t.Exchange, t.Pair)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if thresholds.Tick > 0 {
+		b.addTick(t, float64(thresholds.Tick))
+	}
+	if thresholds.Volume > 0 {
+		b.addSplit(t, TypeVolume, thresholds.Volume, func(capacity float64) float64 { return capacity })
+	}
+	if thresholds.Dollar > 0 {
+		b.addSplit(t, TypeDollar, thresholds.Dollar, func(capacity float64) float64 { return capacity / t.Price })
+	}
+}
+
+func (b *Builder) addTick(t models.Trade, threshold float64) {
+	bar := b.bar(t, TypeTick, threshold)
+	bar.add(t.Price, t.Quantity, t.Timestamp, t.IsBuyerMaker)
+	if bar.progress() >= threshold {
+		b.close(bar)
+	}
+}
+
+// addSplit fills bars by quantity, splitting a trade across bars when it is
+// larger than what the current bar can still take. toQuantity converts the
+// remaining capacity of a bar into base units.
+func (b *Builder) addSplit(t models.Trade, barType string, threshold float64, toQuantity func(capacity float64) float64) {
+	remaining := t.Quantity
+	for remaining > 0 {
+		bar := b.bar(t, barType, threshold)
+
+		quantity := toQuantity(threshold - bar.progress())
+		if quantity > remaining {
+			quantity = remaining
+		}
+		bar.add(t.Price, quantity, t.Timestamp, t.IsBuyerMaker)
+		remaining -= quantity
+
+		if bar.progress() >= threshold*(1-fillTolerance) {
+			b.close(bar)
+		}
+		if remaining <= t.Quantity*fillTolerance {
+			break
+		}
+	}
+}
+
+// bar returns the in-progress bar for a series, starting one if needed.
+func (b *Builder) bar(t models.Trade, barType string, threshold float64) *Bar {
+	key := seriesKey{exchange: t.Exchange, pair: t.Pair, barType: barType}
+	bar, ok := b.current[key]
+	if !ok || bar.Threshold != threshold {
+		bar = &Bar{Exchange: t.Exchange, Pair: t.Pair, Type: barType, Threshold: threshold}
+		b.current[key] = bar
+	}
+	return bar
+}
+
+func (b *Builder) close(bar *Bar) {
+	bar.Closed = true
+	key := seriesKey{exchange: bar.Exchange, pair: bar.Pair, barType: bar.Type}
+	delete(b.current, key)
+	if last, ok := b.lastClosed[key]; ok && last.CloseTime == bar.CloseTime {
+		bar.Seq = last.Seq + 1
+	}
+	b.lastClosed[key] = *bar
+
+	// Keep scores increasing so no bar replaces an earlier one: a late trade can
+	// close a bar at an older time, and a millisecond can run out of scores
+	score := bar.score()
+	if last, ok := b.lastScore[key]; ok && score <= last {
+		score = last + 1
+	}
+	b.lastScore[key] = score
+
+	if b.bus != nil {
+		b.bus.Publish(bus.TopicBars, *bar)
+	}
+	if b.redisClient == nil {
+		return
+	}
+	err := b.redisClient.ReplaceInSortedSet(Key(bar.Exchange, bar.Pair, bar.Type), score, *bar, b.config.MaxStored)
+	if err != nil {
+		log.Printf("Could not persist bar %s: %v", Key(bar.Exchange, bar.Pair, bar.Type), err)
+	}
+}
+
+// Current returns the in-progress bar for a series.
+func (b *Builder) Current(exchange, pair, barType string) (Bar, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bar, ok := b.current[seriesKey{exchange: exchange, pair: pair, barType: barType}]
+	if !ok {
+		return Bar{}, false
+	}
+	return *bar, true
+}
@@ -0,0 +1,124 @@
+package bars
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+func newTestBuilder(t *testing.T, thresholds exchangeconfig.BarThresholds) (*Builder, *redisclient.RedisClient) {
+	t.Helper()
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	return NewBuilder(redisClient, nil, exchangeconfig.BarConfig{Default: thresholds}), redisClient
+}
+
+func trade(ts int64, price, quantity float64) models.Trade {
+	return models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: price, Quantity: quantity, Timestamp: ts}
+}
+
+// storedBars returns the stored bars of a type, oldest first.
+func storedBars(t *testing.T, redisClient *redisclient.RedisClient, barType string) []Bar {
+	t.Helper()
+	members, err := redisClient.GetSortedSet(Key("binance", "BTCUSDT", barType), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bars := make([]Bar, len(members))
+	for i, m := range members {
+		if err := json.Unmarshal([]byte(m), &bars[len(members)-1-i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bars
+}
+
+func TestTickBars(t *testing.T) {
+	b, redisClient := newTestBuilder(t, exchangeconfig.BarThresholds{Tick: 2})
+	for i, price := range []float64{100, 102, 101} {
+		b.AddTrade(trade(int64(1000+i), price, 1))
+	}
+
+	stored := storedBars(t, redisClient, TypeTick)
+	if len(stored) != 1 {
+		t.Fatalf("got %d stored bars, want 1", len(stored))
+	}
+	if bar := stored[0]; bar.Open != 100 || bar.Close != 102 || bar.Trades != 2 || bar.OpenTime != 1000 || bar.CloseTime != 1001 || !bar.Closed {
+		t.Errorf("unexpected bar %+v", bar)
+	}
+	if current, ok := b.Current("binance", "BTCUSDT", TypeTick); !ok || current.Trades != 1 || current.Open != 101 {
+		t.Errorf("unexpected current bar %+v", current)
+	}
+}
+
+func TestSplitTradeStoresEveryBar(t *testing.T) {
+	b, redisClient := newTestBuilder(t, exchangeconfig.BarThresholds{Volume: 1, Dollar: 250})
+	b.AddTrade(trade(1000, 100, 0.5))
+	// Fills the open bar and two more in the same millisecond, leaving 0.25 over
+	b.AddTrade(trade(2000, 100, 2.75))
+
+	volume := storedBars(t, redisClient, TypeVolume)
+	if len(volume) != 3 {
+		t.Fatalf("got %d stored volume bars, want 3", len(volume))
+	}
+	for i, bar := range volume {
+		if bar.Volume < 1-1e-9 || bar.Volume > 1+1e-9 {
+			t.Errorf("volume bar %d holds %v, want 1", i, bar.Volume)
+		}
+	}
+	if volume[0].OpenTime != 1000 || volume[2].OpenTime != 2000 || volume[1].Seq != 1 || volume[2].Seq != 2 {
+		t.Errorf("volume bars out of order: %+v", volume)
+	}
+	if current, ok := b.Current("binance", "BTCUSDT", TypeVolume); !ok || current.Volume < 0.25-1e-9 || current.Volume > 0.25+1e-9 {
+		t.Errorf("unexpected current volume bar %+v", current)
+	}
+
+	dollar := storedBars(t, redisClient, TypeDollar)
+	if len(dollar) != 1 || dollar[0].Notional < 250-1e-6 || dollar[0].Notional > 250+1e-6 {
+		t.Errorf("unexpected dollar bars %+v", dollar)
+	}
+}
+
+func TestLateTradeDoesNotReplaceStoredBar(t *testing.T) {
+	b, redisClient := newTestBuilder(t, exchangeconfig.BarThresholds{Tick: 1})
+	// The third bar closes at the same time as the first, out of order
+	b.AddTrade(trade(1000, 100, 1))
+	b.AddTrade(trade(2000, 101, 1))
+	b.AddTrade(trade(1000, 102, 1))
+
+	stored := storedBars(t, redisClient, TypeTick)
+	if len(stored) != 3 {
+		t.Fatalf("got %d stored bars, want 3", len(stored))
+	}
+	for i, want := range []float64{100, 101, 102} {
+		if stored[i].Close != want {
+			t.Errorf("bar %d closed at %v, want %v", i, stored[i].Close, want)
+		}
+	}
+}
+
+func TestMoreBarsThanScoresInAMillisecond(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	b := NewBuilder(redisClient, nil, exchangeconfig.BarConfig{Default: exchangeconfig.BarThresholds{Volume: 1}, MaxStored: 5000})
+	b.AddTrade(trade(1000, 100, seqSlots+200))
+	b.AddTrade(trade(1001, 100, 1))
+
+	members, err := redisClient.GetSortedSet(Key("binance", "BTCUSDT", TypeVolume), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != seqSlots+201 {
+		t.Fatalf("got %d stored bars, want %d", len(members), seqSlots+201)
+	}
+	var newest Bar
+	if err := json.Unmarshal([]byte(members[0]), &newest); err != nil {
+		t.Fatal(err)
+	}
+	if newest.CloseTime != 1001 {
+		t.Errorf("newest stored bar closed at %d, want 1001", newest.CloseTime)
+	}
+}
@@ -0,0 +1,94 @@
+package bus
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Topics published on the bus
+const (
+	TopicTrades     = "trades"
+	TopicCandles    = "candles"
+	TopicBars       = "bars"
+	TopicSpreads    = "spreads"
+	TopicArbitrage  = "arbitrage"
+	TopicIndex      = "index"
+	TopicPegs       = "pegs"
+	TopicWhales     = "whales"
+	TopicLeadLag    = "leadlag"
+	TopicQuarantine = "quarantine"
+	TopicAlerts     = "alerts"
+	TopicSeries     = "series"
+)
+
+// Message is a single update published on a topic.
+type Message struct {
+	Topic   string
+	Payload interface{}
+}
+
+// Subscription receives messages for one topic on C until it is closed.
+type Subscription struct {
+	C <-chan Message
+
+	ch      chan Message
+	topic   string
+	bus     *Bus
+	dropped int64
+}
+
+// Bus is an in-process publish/subscribe hub. Publishing never blocks: a
+// subscriber whose buffer is full misses the message.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+func New() *Bus {
+	return &Bus{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe registers a new subscriber for topic with the given buffer size.
+func (b *Bus) Subscribe(topic string, buffer int) *Subscription {
+	ch := make(chan Message, buffer)
+	sub := &Subscription{C: ch, ch: ch, topic: topic, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*Subscription]struct{})
+	}
+	b.subs[topic][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers payload to every subscriber of topic.
+func (b *Bus) Publish(topic string, payload interface{}) {
+	msg := Message{Topic: topic, Payload: payload}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs[topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			dropped := atomic.AddInt64(&sub.dropped, 1)
+			// Log on powers of two so a stuck subscriber doesn't flood the logs
+			if dropped&(dropped-1) == 0 {
+				log.Printf("Bus subscriber on %s is full, %d messages dropped", topic, dropped)
+			}
+		}
+	}
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s.topic][s]; !ok {
+		return
+	}
+	delete(s.bus.subs[s.topic], s)
+	close(s.ch)
+}
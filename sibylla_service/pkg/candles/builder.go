@@ -0,0 +1,222 @@
+package candles
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+type seriesKey struct {
+	exchange string
+	pair     string
+	interval string
+}
+
+// series tracks the in-progress candle and the recently closed candles that
+// late trades may still amend.
+type series struct {
+	interval Interval
+	current  *Candle
+	closed   []*Candle // ordered by OpenTime
+	latest   int64     // newest trade time seen
+	opened   int64     // OpenTime of the newest candle started, -1 before the first
+}
+
+// Builder aggregates trades into candles for every configured interval.
+// Closed candles are persisted to Redis and published on the bus.
+type Builder struct {
+	mu          sync.Mutex
+	intervals   []Interval
+	grace       time.Duration
+	maxStored   int64
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	series      map[seriesKey]*series
+}
+
+// NewBuilder creates a candle builder. Trades up to the configured grace behind
+// the newest trade of a series still amend their (already closed) candle.
+func NewBuilder(redisClient *redisclient.RedisClient, b *bus.Bus, intervals []Interval, config exchangeconfig.CandleConfig) *Builder {
+	config = config.WithDefaults()
+	return &Builder{
+		intervals:   intervals,
+		grace:       config.Grace.Duration,
+		maxStored:   config.MaxStored,
+		redisClient: redisClient,
+		bus:         b,
+		series:      make(map[seriesKey]*series),
+	}
+}
+
+// AddTrade folds a trade into the candles of every interval.
+func (b *Builder) AddTrade(t models.Trade) {
+	if t.Timestamp <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, interval := range b.intervals {
+		key := seriesKey{exchange: t.Exchange, pair: t.Pair, interval: interval.Name}
+		s, ok := b.series[key]
+		if !ok {
+			s = &series{interval: interval, opened: -1}
+			b.series[key] = s
+		}
+		b.addToSeries(key, s, t)
+	}
+}
+
+func (b *Builder) addToSeries(key seriesKey, s *series, t models.Trade) {
+	width := s.interval.Duration.Milliseconds()
+	openTime := t.Timestamp - t.Timestamp%width
+
+	if t.Timestamp > s.latest {
+		s.latest = t.Timestamp
+	}
+
+	switch {
+	case s.current != nil && openTime == s.current.OpenTime:
+		s.current.add(t.Price, t.Quantity, t.Timestamp, t.IsBuyerMaker)
+		b.publish(*s.current)
+
+	case openTime > s.opened:
+		// Compared against the newest candle started rather than the current
+		// one, which the wall clock may already have closed
+		if s.current != nil {
+			b.closeCurrent(s)
+		}
+		s.current = &Candle{
+			Exchange:  key.exchange,
+			Pair:      key.pair,
+			Interval:  key.interval,
+			OpenTime:  openTime,
+			CloseTime: openTime + width,
+		}
+		s.opened = openTime
+		s.current.add(t.Price, t.Quantity, t.Timestamp, t.IsBuyerMaker)
+		b.publish(*s.current)
+
+	default:
+		// Late trade for an interval that has already closed
+		if s.latest-(openTime+width) > b.grace.Milliseconds() {
+			return
+		}
+		candle := s.findClosed(openTime)
+		if candle == nil {
+			candle = &Candle{
+				Exchange:  key.exchange,
+				Pair:      key.pair,
+				Interval:  key.interval,
+				OpenTime:  openTime,
+				CloseTime: openTime + width,
+				Closed:    true,
+			}
+			s.insertClosed(candle)
+		}
+		candle.add(t.Price, t.Quantity, t.Timestamp, t.IsBuyerMaker)
+		b.persist(*candle)
+	}
+
+	s.pruneClosed(b.grace.Milliseconds())
+}
+
+// closeCurrent marks the in-progress candle closed, persists it and keeps it
+// around for amendments.
+func (b *Builder) closeCurrent(s *series) {
+	s.current.Closed = true
+	s.closed = append(s.closed, s.current)
+	b.persist(*s.current)
+	s.current = nil
+}
+
+// Current returns the in-progress candle for a series.
+func (b *Builder) Current(exchange, pair, interval string) (Candle, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.series[seriesKey{exchange: exchange, pair: pair, interval: interval}]
+	if !ok || s.current == nil {
+		return Candle{}, false
+	}
+	return *s.current, true
+}
+
+// Run closes candles whose interval has ended by the wall clock, so quiet
+// markets still get their candles persisted. It blocks, call it in a goroutine.
+func (b *Builder) Run(tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		b.closeDue(now.UnixMilli())
+	}
+}
+
+// closeDue closes the in-progress candles that end at or before nowMs.
+func (b *Builder) closeDue(nowMs int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.series {
+		if s.current != nil && nowMs >= s.current.CloseTime {
+			b.closeCurrent(s)
+		}
+		if nowMs > s.latest {
+			s.latest = nowMs
+		}
+		s.pruneClosed(b.grace.Milliseconds())
+	}
+}
+
+func (b *Builder) persist(c Candle) {
+	b.publish(c)
+	if b.redisClient == nil {
+		return
+	}
+	err := b.redisClient.ReplaceInSortedSet(Key(c.Exchange, c.Pair, c.Interval), float64(c.OpenTime), c, b.maxStored)
+	if err != nil {
+		log.Printf("Could not persist candle %s: %v", Key(c.Exchange, c.Pair, c.Interval), err)
+	}
+}
+
+func (b *Builder) publish(c Candle) {
+	if b.bus != nil {
+		b.bus.Publish(bus.TopicCandles, c)
+	}
+}
+
+func (s *series) findClosed(openTime int64) *Candle {
+	for _, c := range s.closed {
+		if c.OpenTime == openTime {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *series) insertClosed(c *Candle) {
+	i := sort.Search(len(s.closed), func(i int) bool { return s.closed[i].OpenTime > c.OpenTime })
+	s.closed = append(s.closed, nil)
+	copy(s.closed[i+1:], s.closed[i:])
+	s.closed[i] = c
+}
+
+// pruneClosed drops closed candles that are past the grace window.
+func (s *series) pruneClosed(graceMs int64) {
+	n := 0
+	for _, c := range s.closed {
+		if s.latest-c.CloseTime <= graceMs {
+			break
+		}
+		n++
+	}
+	s.closed = s.closed[n:]
+}
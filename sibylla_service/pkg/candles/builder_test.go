@@ -0,0 +1,115 @@
+package candles
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+var minute = Interval{Name: "1m", Duration: time.Minute}
+
+func newTestBuilder(t *testing.T) (*Builder, *redisclient.RedisClient) {
+	t.Helper()
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	config := exchangeconfig.CandleConfig{Grace: exchangeconfig.Duration{Duration: 10 * time.Second}}
+	return NewBuilder(redisClient, nil, []Interval{minute}, config), redisClient
+}
+
+func trade(ts int64, price, quantity float64) models.Trade {
+	return models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: price, Quantity: quantity, Timestamp: ts}
+}
+
+func storedCandles(t *testing.T, redisClient *redisclient.RedisClient) []Candle {
+	t.Helper()
+	members, err := redisClient.GetSortedSet(Key("binance", "BTCUSDT", "1m"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candles := make([]Candle, len(members))
+	for i, m := range members {
+		if err := json.Unmarshal([]byte(m), &candles[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return candles
+}
+
+func TestAddTradeBuildsCandle(t *testing.T) {
+	b, _ := newTestBuilder(t)
+	b.AddTrade(trade(61_000, 100, 1))
+	b.AddTrade(trade(62_000, 105, 1))
+	b.AddTrade(trade(61_500, 95, 2)) // out of order, does not move open or close
+
+	c, ok := b.Current("binance", "BTCUSDT", "1m")
+	if !ok {
+		t.Fatal("no current candle")
+	}
+	if c.OpenTime != 60_000 || c.CloseTime != 120_000 {
+		t.Errorf("candle spans %d-%d, want 60000-120000", c.OpenTime, c.CloseTime)
+	}
+	if c.Open != 100 || c.High != 105 || c.Low != 95 || c.Close != 105 || c.Volume != 4 || c.Trades != 3 {
+		t.Errorf("unexpected candle %+v", c)
+	}
+}
+
+func TestLateTradeAfterWallClockCloseAmendsCandle(t *testing.T) {
+	b, redisClient := newTestBuilder(t)
+	b.AddTrade(trade(61_000, 100, 1))
+	b.AddTrade(trade(62_000, 101, 1))
+	b.closeDue(120_500)
+
+	if _, ok := b.Current("binance", "BTCUSDT", "1m"); ok {
+		t.Fatal("candle still open after its interval ended")
+	}
+
+	// Within grace of the wall clock close: amends the stored candle
+	b.AddTrade(trade(119_000, 99, 2))
+	if _, ok := b.Current("binance", "BTCUSDT", "1m"); ok {
+		t.Error("late trade opened a new candle")
+	}
+	stored := storedCandles(t, redisClient)
+	if len(stored) != 1 {
+		t.Fatalf("got %d stored candles, want 1", len(stored))
+	}
+	if c := stored[0]; c.Trades != 3 || c.Volume != 4 || c.Close != 99 || c.Low != 99 || !c.Closed {
+		t.Errorf("late trade not folded into stored candle: %+v", c)
+	}
+	if n := len(b.series[seriesKey{exchange: "binance", pair: "BTCUSDT", interval: "1m"}].closed); n != 1 {
+		t.Errorf("got %d closed candles in memory, want 1", n)
+	}
+
+	// Past grace: dropped rather than replacing the stored candle
+	b.closeDue(140_000)
+	b.AddTrade(trade(100_000, 50, 1))
+	if _, ok := b.Current("binance", "BTCUSDT", "1m"); ok {
+		t.Error("trade past grace opened a new candle")
+	}
+	if c := storedCandles(t, redisClient)[0]; c.Trades != 3 {
+		t.Errorf("trade past grace changed stored candle: %+v", c)
+	}
+
+	// The next interval still opens normally
+	b.AddTrade(trade(141_000, 102, 1))
+	if c, ok := b.Current("binance", "BTCUSDT", "1m"); !ok || c.OpenTime != 120_000 {
+		t.Errorf("next interval not opened, got %+v", c)
+	}
+}
+
+func TestDefaultConfigAmendsLateTrades(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	b := NewBuilder(redisClient, nil, []Interval{minute}, exchangeconfig.CandleConfig{})
+	b.AddTrade(trade(61_000, 100, 1))
+	b.closeDue(120_500)
+
+	// Up to the default grace behind the wall clock close
+	b.AddTrade(trade(118_000, 99, 1))
+	if c := storedCandles(t, redisClient); len(c) != 1 || c[0].Trades != 2 {
+		t.Errorf("late trade within the default grace not amended: %+v", c)
+	}
+}
@@ -0,0 +1,97 @@
+package candles
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Interval is a candle period.
+type Interval struct {
+	Name     string
+	Duration time.Duration
+}
+
+// DefaultIntervals are the periods built for every exchange and pair.
+var DefaultIntervals = []Interval{
+	{Name: "1s", Duration: time.Second},
+	{Name: "1m", Duration: time.Minute},
+	{Name: "5m", Duration: 5 * time.Minute},
+	{Name: "1h", Duration: time.Hour},
+	{Name: "1d", Duration: 24 * time.Hour},
+}
+
+// ParseInterval looks up one of the default intervals by name.
+func ParseInterval(name string) (Interval, error) {
+	for _, interval := range DefaultIntervals {
+		if interval.Name == name {
+			return interval, nil
+		}
+	}
+	return Interval{}, fmt.Errorf("unknown interval %q", name)
+}
+
+// Candle is an OHLCV summary of the trades in one interval. Times are Unix
+// milliseconds; CloseTime is exclusive.
+type Candle struct {
+	Exchange   string  `json:"exchange"`
+	Pair       string  `json:"pair"`
+	Interval   string  `json:"interval"`
+	OpenTime   int64   `json:"open_time"`
+	CloseTime  int64   `json:"close_time"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Close      float64 `json:"close"`
+	Volume     float64 `json:"volume"`
+	BuyVolume  float64 `json:"buy_volume"`
+	SellVolume float64 `json:"sell_volume"`
+	Trades     int64   `json:"trades"`
+	Closed     bool    `json:"closed"`
+
+	// Timestamp of the trade that set Open and Close, used to order late trades
+	openTradeTime  int64
+	closeTradeTime int64
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (c Candle) MarshalBinary() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Key is the store key holding closed candles for an exchange, pair and interval.
+func Key(exchange, pair, interval string) string {
+	return "candles:" + exchange + ":" + pair + ":" + interval
+}
+
+// add folds a trade into the candle. Trades may arrive out of order, so open
+// and close follow trade time rather than arrival order.
+func (c *Candle) add(price, quantity float64, timestamp int64, isBuyerMaker bool) {
+	if c.Trades == 0 {
+		c.Open, c.High, c.Low, c.Close = price, price, price, price
+		c.openTradeTime, c.closeTradeTime = timestamp, timestamp
+	}
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	if timestamp < c.openTradeTime {
+		c.Open = price
+		c.openTradeTime = timestamp
+	}
+	if timestamp >= c.closeTradeTime {
+		c.Close = price
+		c.closeTradeTime = timestamp
+	}
+
+	c.Volume += quantity
+	// The aggressor is the taker: when the buyer is the maker, the taker sold
+	if isBuyerMaker {
+		c.SellVolume += quantity
+	} else {
+		c.BuyVolume += quantity
+	}
+	c.Trades++
+}
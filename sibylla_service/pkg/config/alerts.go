@@ -0,0 +1,104 @@
+package exchangeconfig
+
+import "time"
+
+// Alert engine defaults used when the config leaves a field unset
+const (
+	DefaultAlertInterval  = time.Second
+	DefaultAlertQueueSize = 100
+	DefaultAlertMaxStored = 10000
+)
+
+// DefaultAlertSinks are notified when the config lists none.
+var DefaultAlertSinks = []AlertSink{{Name: "log", Type: SinkLog}}
+
+// Alert rule kinds
+const (
+	AlertPrice    = "price"      // last price of Exchange/Pair
+	AlertSpread   = "spread"     // (Exchange - ExchangeB) / mid in bps, on Pair and PairB
+	AlertNoTrades = "no_trades"  // seconds since the last trade on Exchange/Pair
+	AlertExpr     = "expression" // value of Expr, see pkg/expr
+)
+
+// Alert rule operators
+const (
+	AlertAbove = "above"
+	AlertBelow = "below"
+)
+
+// AlertRule fires when its value is beyond Threshold for at least For, and
+// resolves once the value is back past Threshold by more than Hysteresis.
+// Cooldown is the least time between two firings of the rule.
+type AlertRule struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name,omitempty"`
+	Kind       string   `json:"kind"`
+	Exchange   string   `json:"exchange,omitempty"`
+	ExchangeB  string   `json:"exchange_b,omitempty"`
+	Pair       string   `json:"pair,omitempty"`
+	PairB      string   `json:"pair_b,omitempty"` // defaults to Pair
+	Expr       string   `json:"expr,omitempty"`
+	Op         string   `json:"op"`
+	Threshold  float64  `json:"threshold"`
+	For        Duration `json:"for"`
+	Hysteresis float64  `json:"hysteresis,omitempty"`
+	Cooldown   Duration `json:"cooldown"`
+	// Sinks names the sinks to notify; empty notifies every sink
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// Alert sink types
+const (
+	SinkLog     = "log"
+	SinkWebhook = "webhook"
+	SinkSMTP    = "smtp"
+)
+
+// AlertSink is a destination for alert notifications. Secrets can be given
+// directly or read from the environment variable named by the *_env field.
+type AlertSink struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Webhook
+	URL       string `json:"url,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+
+	// SMTP
+	Addr        string   `json:"addr,omitempty"` // host:port
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+}
+
+// AlertConfig controls the alerting engine.
+type AlertConfig struct {
+	Rules []AlertRule `json:"rules"`
+	Sinks []AlertSink `json:"sinks"`
+	// Interval is how often every rule is evaluated, on top of evaluating on trades
+	Interval Duration `json:"interval"`
+	// QueueSize is how many notifications can wait for each sink; more are dropped
+	QueueSize int `json:"queue_size"`
+	// MaxStored is the number of alert notifications kept
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c AlertConfig) WithDefaults() AlertConfig {
+	if len(c.Sinks) == 0 {
+		c.Sinks = DefaultAlertSinks
+	}
+	if c.Interval.Duration <= 0 {
+		c.Interval.Duration = DefaultAlertInterval
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultAlertQueueSize
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultAlertMaxStored
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package exchangeconfig
+
+import "time"
+
+// Arbitrage detector defaults used when the config leaves a field unset
+const (
+	DefaultArbitrageSizeWindow  = 10 * time.Second
+	DefaultArbitrageMaxQuoteAge = 5 * time.Second
+	DefaultArbitrageMaxStored   = 10000
+)
+
+// VenueCosts describes what it costs to trade on and move funds off an exchange.
+type VenueCosts struct {
+	TakerFeeBps float64 `json:"taker_fee_bps"`
+	// WithdrawalFees are flat fees per withdrawal, in units of the withdrawn asset
+	WithdrawalFees map[string]float64 `json:"withdrawal_fees"`
+	// Latency is how long it takes to get an order filled on the exchange
+	Latency Duration `json:"latency"`
+}
+
+// ArbitrageConfig controls the arbitrage opportunity detector.
+type ArbitrageConfig struct {
+	Venues map[string]VenueCosts `json:"venues"`
+	// ThresholdBps is the minimum net edge, after costs, for an opportunity
+	ThresholdBps float64 `json:"threshold_bps"`
+	// SizeWindow is how much recent trade flow counts as available size
+	SizeWindow Duration `json:"size_window"`
+	// MaxQuoteAge ignores venues whose last trade is older than this
+	MaxQuoteAge Duration `json:"max_quote_age"`
+	// MaxStored is the number of closed opportunities kept in the store
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c ArbitrageConfig) WithDefaults() ArbitrageConfig {
+	if c.SizeWindow.Duration <= 0 {
+		c.SizeWindow.Duration = DefaultArbitrageSizeWindow
+	}
+	if c.MaxQuoteAge.Duration <= 0 {
+		c.MaxQuoteAge.Duration = DefaultArbitrageMaxQuoteAge
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultArbitrageMaxStored
+	}
+	return c
+}
@@ -0,0 +1,39 @@
+package exchangeconfig
+
+import "time"
+
+// Price kinds an engine can use for a venue
+const (
+	PriceLast = "last"
+	PriceVWAP = "vwap"
+	PriceTWAP = "twap"
+)
+
+// DefaultAverageWindows are averaged over when the config lists none.
+var DefaultAverageWindows = []Duration{
+	{Duration: time.Minute},
+	{Duration: 5 * time.Minute},
+	{Duration: time.Hour},
+	{Duration: 24 * time.Hour},
+}
+
+// AveragesConfig controls the rolling VWAP and TWAP calculator.
+type AveragesConfig struct {
+	// Windows are the rolling windows averaged over, e.g. ["1m", "5m", "1h", "24h"]
+	Windows []Duration `json:"windows"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c AveragesConfig) WithDefaults() AveragesConfig {
+	if len(c.Windows) == 0 {
+		c.Windows = DefaultAverageWindows
+	}
+	return c
+}
+
+// PriceSource selects the price an engine takes from each venue: the last
+// trade (the default) or a rolling average over one of the averaging windows.
+type PriceSource struct {
+	Kind   string   `json:"kind"`
+	Window Duration `json:"window"`
+}
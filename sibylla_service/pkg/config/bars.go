@@ -0,0 +1,35 @@
+package exchangeconfig
+
+// DefaultBarMaxStored is the number of closed bars kept per series when unset.
+const DefaultBarMaxStored = 1000
+
+// BarThresholds sets when information-driven bars close. A zero value disables that bar type.
+type BarThresholds struct {
+	Tick   int64   `json:"tick,omitempty"`   // trades per bar
+	Volume float64 `json:"volume,omitempty"` // base units per bar
+	Dollar float64 `json:"dollar,omitempty"` // quote notional per bar
+}
+
+// BarConfig holds bar thresholds by instrument ("<exchange>:<pair>"), falling back to Default.
+type BarConfig struct {
+	Default     BarThresholds            `json:"default"`
+	Instruments map[string]BarThresholds `json:"instruments"`
+	// MaxStored is the number of closed bars kept per exchange, pair and bar type
+	MaxStored int64 `json:"max_stored"`
+}
+
+// For resolves the thresholds for an instrument.
+func (c BarConfig) For(exchange, pair string) BarThresholds {
+	if t, ok := c.Instruments[exchange+":"+pair]; ok {
+		return t
+	}
+	return c.Default
+}
+
+// WithDefaults fills the fields left unset.
+func (c BarConfig) WithDefaults() BarConfig {
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultBarMaxStored
+	}
+	return c
+}
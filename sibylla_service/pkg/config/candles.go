@@ -0,0 +1,28 @@
+package exchangeconfig
+
+import "time"
+
+// Candle builder defaults used when the config leaves a field unset
+const (
+	DefaultCandleGrace     = 5 * time.Second
+	DefaultCandleMaxStored = 1000
+)
+
+// CandleConfig controls the candle builder.
+type CandleConfig struct {
+	// Grace is how far behind the newest trade a late trade may be and still amend its candle
+	Grace Duration `json:"grace"`
+	// MaxStored is the number of closed candles kept per exchange, pair and interval
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c CandleConfig) WithDefaults() CandleConfig {
+	if c.Grace.Duration <= 0 {
+		c.Grace.Duration = DefaultCandleGrace
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultCandleMaxStored
+	}
+	return c
+}
@@ -0,0 +1,31 @@
+package exchangeconfig
+
+import "time"
+
+// Converter defaults used when the config leaves a field unset
+const (
+	DefaultConversionMaxStaleness    = 30 * time.Second
+	DefaultConversionLiquidityWindow = 5 * time.Minute
+)
+
+// ConversionConfig controls quote-currency conversion across the asset graph.
+type ConversionConfig struct {
+	// MaxStaleness ignores markets whose last trade is older than this
+	MaxStaleness Duration `json:"max_staleness"`
+	// LiquidityWindow is how much recent trade activity ranks competing markets
+	LiquidityWindow Duration `json:"liquidity_window"`
+	// DisablePar stops pegged equivalents (USDT/USD, WBTC/BTC) being assumed at 1:1
+	// when no market connects them
+	DisablePar bool `json:"disable_par"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c ConversionConfig) WithDefaults() ConversionConfig {
+	if c.MaxStaleness.Duration <= 0 {
+		c.MaxStaleness.Duration = DefaultConversionMaxStaleness
+	}
+	if c.LiquidityWindow.Duration <= 0 {
+		c.LiquidityWindow.Duration = DefaultConversionLiquidityWindow
+	}
+	return c
+}
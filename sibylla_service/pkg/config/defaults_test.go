@@ -0,0 +1,105 @@
+package exchangeconfig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"candles unset", CandleConfig{}.WithDefaults(), CandleConfig{
+			Grace:     Duration{Duration: DefaultCandleGrace},
+			MaxStored: DefaultCandleMaxStored,
+		}},
+		{"bars unset", BarConfig{}.WithDefaults(), BarConfig{MaxStored: DefaultBarMaxStored}},
+		{"spreads unset", SpreadConfig{}.WithDefaults(), SpreadConfig{
+			MaxStaleness:   Duration{Duration: DefaultSpreadMaxStaleness},
+			SampleInterval: Duration{Duration: DefaultSpreadSampleInterval},
+			MaxStored:      DefaultSpreadMaxStored,
+		}},
+		{"arbitrage unset", ArbitrageConfig{}.WithDefaults(), ArbitrageConfig{
+			SizeWindow:  Duration{Duration: DefaultArbitrageSizeWindow},
+			MaxQuoteAge: Duration{Duration: DefaultArbitrageMaxQuoteAge},
+			MaxStored:   DefaultArbitrageMaxStored,
+		}},
+		{"index unset", IndexConfig{}.WithDefaults(), IndexConfig{
+			MaxStaleness:  Duration{Duration: DefaultIndexMaxStaleness},
+			BandBps:       DefaultIndexBandBps,
+			MinVenues:     DefaultIndexMinVenues,
+			VolumeWindow:  Duration{Duration: DefaultIndexVolumeWindow},
+			StoreInterval: Duration{Duration: DefaultIndexStoreInterval},
+			MaxStored:     DefaultIndexMaxStored,
+		}},
+		{"conversion unset", ConversionConfig{DisablePar: true}.WithDefaults(), ConversionConfig{
+			MaxStaleness:    Duration{Duration: DefaultConversionMaxStaleness},
+			LiquidityWindow: Duration{Duration: DefaultConversionLiquidityWindow},
+			DisablePar:      true,
+		}},
+		{"pegs unset", PegMonitorConfig{}.WithDefaults(), PegMonitorConfig{
+			Pegs:         DefaultPegs,
+			MaxStaleness: Duration{Duration: DefaultPegMaxStaleness},
+			Interval:     Duration{Duration: DefaultPegInterval},
+			StatsWindow:  Duration{Duration: DefaultPegStatsWindow},
+			MaxStored:    DefaultPegMaxStored,
+		}},
+		{"averages unset", AveragesConfig{}.WithDefaults(), AveragesConfig{Windows: DefaultAverageWindows}},
+		{"volatility unset", VolatilityConfig{}.WithDefaults(), VolatilityConfig{
+			Interval:    DefaultVolatilityInterval,
+			Windows:     DefaultVolatilityWindows,
+			EWMALambda:  DefaultVolatilityEWMALambda,
+			TradingDays: DefaultVolatilityTradingDays,
+		}},
+		{"flow unset", FlowConfig{}.WithDefaults(), FlowConfig{Windows: DefaultFlowWindows, DivergenceBps: DefaultFlowDivergenceBps}},
+		{"whales unset", WhaleConfig{}.WithDefaults(), WhaleConfig{
+			Samples:          DefaultWhaleSamples,
+			ClusterWindow:    Duration{Duration: DefaultWhaleClusterWindow},
+			ClusterMinTrades: DefaultWhaleClusterMinTrades,
+			ContextWindow:    Duration{Duration: DefaultWhaleContextWindow},
+			MaxStored:        DefaultWhaleMaxStored,
+		}},
+		{"leadlag unset", LeadLagConfig{}.WithDefaults(), LeadLagConfig{
+			Clock:      ClockExchange,
+			Window:     Duration{Duration: DefaultLeadLagWindow},
+			Resolution: Duration{Duration: DefaultLeadLagResolution},
+			MaxLag:     Duration{Duration: DefaultLeadLagMaxLag},
+			Interval:   Duration{Duration: DefaultLeadLagInterval},
+			MaxStored:  DefaultLeadLagMaxStored,
+		}},
+		{"filter unset", FilterConfig{}.WithDefaults(), FilterConfig{
+			MedianTrades:    DefaultFilterMedianTrades,
+			MinTrades:       DefaultFilterMinTrades,
+			MaxJumpBps:      DefaultFilterMaxJumpBps,
+			MaxCompositeBps: DefaultFilterMaxCompositeBps,
+			MaxStaleness:    Duration{Duration: DefaultFilterMaxStaleness},
+			MaxStored:       DefaultFilterMaxStored,
+		}},
+		{"alerts unset", AlertConfig{}.WithDefaults(), AlertConfig{
+			Sinks:     DefaultAlertSinks,
+			Interval:  Duration{Duration: DefaultAlertInterval},
+			QueueSize: DefaultAlertQueueSize,
+			MaxStored: DefaultAlertMaxStored,
+		}},
+		{"series unset", SeriesConfig{}.WithDefaults(), SeriesConfig{
+			Interval:  Duration{Duration: DefaultSeriesInterval},
+			MaxStored: DefaultSeriesMaxStored,
+		}},
+		{"stream unset", StreamConfig{}.WithDefaults(), StreamConfig{
+			QueueSize:         DefaultStreamQueueSize,
+			WriteTimeout:      Duration{Duration: DefaultStreamWriteTimeout},
+			PingInterval:      Duration{Duration: DefaultStreamPingInterval},
+			MaxSubscriptions:  DefaultStreamMaxSubscriptions,
+			ReplayBuffer:      DefaultStreamReplayBuffer,
+			HeartbeatInterval: Duration{Duration: DefaultStreamHeartbeat},
+		}},
+		{"candles set", CandleConfig{Grace: Duration{Duration: time.Second}, MaxStored: 5}.WithDefaults(), CandleConfig{Grace: Duration{Duration: time.Second}, MaxStored: 5}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+}
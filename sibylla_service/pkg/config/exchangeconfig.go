@@ -1,8 +1,20 @@
 package exchangeconfig
 
-import "sibylla_service/pkg/redisclient"
+import (
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// TradeFilter decides whether a normalized trade is sound enough to publish and store.
+type TradeFilter interface {
+	Admit(t models.Trade) bool
+}
 
 type Config struct {
 	ConnectionString string
 	RedisClient      *redisclient.RedisClient
+	Retention        RetentionConfig
+	Bus              *bus.Bus
+	Filter           TradeFilter
 }
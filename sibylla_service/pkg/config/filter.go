@@ -0,0 +1,52 @@
+package exchangeconfig
+
+import "time"
+
+// Bad-tick filter defaults used when the config leaves a field unset
+const (
+	DefaultFilterMedianTrades    = 50
+	DefaultFilterMinTrades       = 10
+	DefaultFilterMaxJumpBps      = 500
+	DefaultFilterMaxCompositeBps = 1000
+	DefaultFilterMaxStaleness    = 30 * time.Second
+	DefaultFilterMaxStored       = 10000
+)
+
+// FilterConfig controls the bad-tick filter between the adapters and storage.
+type FilterConfig struct {
+	// MedianTrades is how many recent trades of an instrument its median covers
+	MedianTrades int `json:"median_trades"`
+	// MinTrades is how many trades an instrument needs before jumps are checked
+	MinTrades int `json:"min_trades"`
+	// MaxJumpBps quarantines trades further than this from the instrument's recent median
+	MaxJumpBps float64 `json:"max_jump_bps"`
+	// MaxCompositeBps quarantines trades further than this from the median of the other venues
+	MaxCompositeBps float64 `json:"max_composite_bps"`
+	// MaxStaleness leaves venues out of the composite when their last trade is older than this
+	MaxStaleness Duration `json:"max_staleness"`
+	// MaxStored is the number of quarantined trades kept, overall and per exchange
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c FilterConfig) WithDefaults() FilterConfig {
+	if c.MedianTrades <= 0 {
+		c.MedianTrades = DefaultFilterMedianTrades
+	}
+	if c.MinTrades <= 0 {
+		c.MinTrades = DefaultFilterMinTrades
+	}
+	if c.MaxJumpBps <= 0 {
+		c.MaxJumpBps = DefaultFilterMaxJumpBps
+	}
+	if c.MaxCompositeBps <= 0 {
+		c.MaxCompositeBps = DefaultFilterMaxCompositeBps
+	}
+	if c.MaxStaleness.Duration <= 0 {
+		c.MaxStaleness.Duration = DefaultFilterMaxStaleness
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultFilterMaxStored
+	}
+	return c
+}
@@ -0,0 +1,32 @@
+package exchangeconfig
+
+import "time"
+
+// DefaultFlowDivergenceBps is used when the config leaves DivergenceBps unset.
+const DefaultFlowDivergenceBps = 5
+
+// DefaultFlowWindows are measured over when the config lists none.
+var DefaultFlowWindows = []Duration{
+	{Duration: time.Minute},
+	{Duration: 5 * time.Minute},
+	{Duration: time.Hour},
+}
+
+// FlowConfig controls the order flow metrics.
+type FlowConfig struct {
+	// Windows are the rolling windows the flow is measured over, e.g. ["1m", "5m", "1h"]
+	Windows []Duration `json:"windows"`
+	// DivergenceBps is the smallest price move that counts against opposing volume delta
+	DivergenceBps float64 `json:"divergence_bps"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c FlowConfig) WithDefaults() FlowConfig {
+	if len(c.Windows) == 0 {
+		c.Windows = DefaultFlowWindows
+	}
+	if c.DivergenceBps <= 0 {
+		c.DivergenceBps = DefaultFlowDivergenceBps
+	}
+	return c
+}
@@ -0,0 +1,56 @@
+package exchangeconfig
+
+import "time"
+
+// Index engine defaults used when the config leaves a field unset
+const (
+	DefaultIndexMaxStaleness  = 10 * time.Second
+	DefaultIndexBandBps       = 50
+	DefaultIndexMinVenues     = 2
+	DefaultIndexVolumeWindow  = 5 * time.Minute
+	DefaultIndexStoreInterval = time.Second
+	DefaultIndexMaxStored     = 86400
+)
+
+// IndexConfig controls the composite index price.
+type IndexConfig struct {
+	// MaxStaleness excludes venues whose last trade is older than this
+	MaxStaleness Duration `json:"max_staleness"`
+	// BandBps rejects venues further than this from the median
+	BandBps float64 `json:"band_bps"`
+	// MinVenues is how many venues must contribute for a valid price
+	MinVenues int `json:"min_venues"`
+	// VolumeWindow is how much recent volume weights each venue
+	VolumeWindow Duration `json:"volume_window"`
+	// Price is the price taken from each venue, the last trade by default
+	Price PriceSource `json:"price"`
+	// Interval recomputes every index on a tick; zero recomputes on every trade
+	Interval Duration `json:"interval"`
+	// StoreInterval is the minimum time between stored points of an index time series
+	StoreInterval Duration `json:"store_interval"`
+	// MaxStored is the number of points kept per index time series
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c IndexConfig) WithDefaults() IndexConfig {
+	if c.MaxStaleness.Duration <= 0 {
+		c.MaxStaleness.Duration = DefaultIndexMaxStaleness
+	}
+	if c.BandBps <= 0 {
+		c.BandBps = DefaultIndexBandBps
+	}
+	if c.MinVenues <= 0 {
+		c.MinVenues = DefaultIndexMinVenues
+	}
+	if c.VolumeWindow.Duration <= 0 {
+		c.VolumeWindow.Duration = DefaultIndexVolumeWindow
+	}
+	if c.StoreInterval.Duration <= 0 {
+		c.StoreInterval.Duration = DefaultIndexStoreInterval
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultIndexMaxStored
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package exchangeconfig
+
+import "time"
+
+// Lead-lag analysis defaults used when the config leaves a field unset
+const (
+	DefaultLeadLagWindow     = 10 * time.Minute
+	DefaultLeadLagResolution = 100 * time.Millisecond
+	DefaultLeadLagMaxLag     = 2 * time.Second
+	DefaultLeadLagInterval   = time.Minute
+	DefaultLeadLagMaxStored  = 10000
+)
+
+// Clocks a lead-lag analysis can order trades by
+const (
+	ClockExchange = "exchange" // the exchange's trade time
+	ClockReceived = "received" // when sibylla received the trade
+)
+
+// LeadLagConfig controls the lead-lag and price discovery analysis.
+type LeadLagConfig struct {
+	// Clock orders trades by exchange or receive time
+	Clock string `json:"clock"`
+	// Window is how much recent history each analysis covers
+	Window Duration `json:"window"`
+	// Resolution is the sampling step of the price grid and the lag step
+	Resolution Duration `json:"resolution"`
+	// MaxLag is the largest lag cross-correlated in either direction
+	MaxLag Duration `json:"max_lag"`
+	// Interval is how often every pair is analyzed
+	Interval Duration `json:"interval"`
+	// MaxStored is the number of reports kept per pair
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset. Any clock but ClockReceived is the exchange's.
+func (c LeadLagConfig) WithDefaults() LeadLagConfig {
+	if c.Clock != ClockReceived {
+		c.Clock = ClockExchange
+	}
+	if c.Window.Duration <= 0 {
+		c.Window.Duration = DefaultLeadLagWindow
+	}
+	if c.Resolution.Duration <= 0 {
+		c.Resolution.Duration = DefaultLeadLagResolution
+	}
+	if c.MaxLag.Duration <= 0 {
+		c.MaxLag.Duration = DefaultLeadLagMaxLag
+	}
+	if c.Interval.Duration <= 0 {
+		c.Interval.Duration = DefaultLeadLagInterval
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultLeadLagMaxStored
+	}
+	return c
+}
@@ -0,0 +1,80 @@
+package exchangeconfig
+
+import "time"
+
+// Peg monitor defaults used when the config leaves a field unset
+const (
+	DefaultPegMaxStaleness = 30 * time.Second
+	DefaultPegInterval     = time.Second
+	DefaultPegStatsWindow  = time.Hour
+	DefaultPegMaxStored    = 86400
+)
+
+// DefaultPegs are monitored when the config lists none.
+var DefaultPegs = []PegConfig{
+	{
+		Asset:     "USDT",
+		Reference: "USD",
+		Thresholds: []PegThreshold{
+			{Name: "warning", Bps: 30, Sustain: Duration{Duration: 2 * time.Minute}},
+			{Name: "critical", Bps: 100, Sustain: Duration{Duration: 30 * time.Second}},
+		},
+	},
+	{
+		Asset:     "WBTC",
+		Reference: "BTC",
+		Thresholds: []PegThreshold{
+			{Name: "discount", Bps: 50, Sustain: Duration{Duration: 5 * time.Minute}, Direction: "discount"},
+			{Name: "premium", Bps: 50, Sustain: Duration{Duration: 5 * time.Minute}, Direction: "premium"},
+		},
+	},
+}
+
+// PegThreshold raises an event when a peg deviates by more than Bps for at least Sustain.
+// Direction limits it to a "premium" or "discount"; empty matches both.
+type PegThreshold struct {
+	Name      string   `json:"name"`
+	Bps       float64  `json:"bps"`
+	Sustain   Duration `json:"sustain"`
+	Direction string   `json:"direction,omitempty"`
+}
+
+// PegConfig is an asset that should trade 1:1 against a reference asset.
+type PegConfig struct {
+	Asset      string         `json:"asset"`
+	Reference  string         `json:"reference"`
+	Thresholds []PegThreshold `json:"thresholds"`
+}
+
+// PegMonitorConfig controls the peg monitor.
+type PegMonitorConfig struct {
+	Pegs []PegConfig `json:"pegs"`
+	// MaxStaleness ignores venues whose last trade is older than this
+	MaxStaleness Duration `json:"max_staleness"`
+	// Interval is how often every peg is evaluated
+	Interval Duration `json:"interval"`
+	// StatsWindow is the period rolling deviation statistics cover
+	StatsWindow Duration `json:"stats_window"`
+	// MaxStored is the number of points kept per peg time series
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c PegMonitorConfig) WithDefaults() PegMonitorConfig {
+	if len(c.Pegs) == 0 {
+		c.Pegs = DefaultPegs
+	}
+	if c.MaxStaleness.Duration <= 0 {
+		c.MaxStaleness.Duration = DefaultPegMaxStaleness
+	}
+	if c.Interval.Duration <= 0 {
+		c.Interval.Duration = DefaultPegInterval
+	}
+	if c.StatsWindow.Duration <= 0 {
+		c.StatsWindow.Duration = DefaultPegStatsWindow
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultPegMaxStored
+	}
+	return c
+}
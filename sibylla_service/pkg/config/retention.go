@@ -0,0 +1,52 @@
+package exchangeconfig
+
+import "time"
+
+// DefaultMaxTrades is the number of trades kept per instrument when nothing else is configured.
+const DefaultMaxTrades = 100
+
+// RetentionPolicy bounds how much trade history is kept for an instrument.
+// MaxAge is measured from the trade time. A zero field inherits from the less
+// specific policy.
+type RetentionPolicy struct {
+	MaxCount int64    `json:"max_count,omitempty"`
+	MaxAge   Duration `json:"max_age,omitempty"`
+}
+
+// RetentionConfig holds retention policies at default, exchange and instrument level.
+// Instrument keys are "<exchange>:<pair>", e.g. "binance:BTCUSDT".
+type RetentionConfig struct {
+	Default     RetentionPolicy            `json:"default"`
+	Exchanges   map[string]RetentionPolicy `json:"exchanges"`
+	Instruments map[string]RetentionPolicy `json:"instruments"`
+}
+
+// For resolves the effective policy for an instrument, most specific first.
+func (c RetentionConfig) For(exchange, pair string) RetentionPolicy {
+	policy := c.Default
+	if p, ok := c.Exchanges[exchange]; ok {
+		policy = policy.merge(p)
+	}
+	if p, ok := c.Instruments[exchange+":"+pair]; ok {
+		policy = policy.merge(p)
+	}
+	if policy.MaxCount == 0 && policy.MaxAge.Duration == 0 {
+		policy.MaxCount = DefaultMaxTrades
+	}
+	return policy
+}
+
+func (p RetentionPolicy) merge(override RetentionPolicy) RetentionPolicy {
+	if override.MaxCount != 0 {
+		p.MaxCount = override.MaxCount
+	}
+	if override.MaxAge.Duration != 0 {
+		p.MaxAge = override.MaxAge
+	}
+	return p
+}
+
+// Age returns the age bound as a plain duration.
+func (p RetentionPolicy) Age() time.Duration {
+	return p.MaxAge.Duration
+}
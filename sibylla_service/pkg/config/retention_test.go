@@ -0,0 +1,34 @@
+package exchangeconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionFor(t *testing.T) {
+	config := RetentionConfig{
+		Default:   RetentionPolicy{MaxCount: 1000},
+		Exchanges: map[string]RetentionPolicy{"binance": {MaxAge: Duration{Duration: time.Hour}}},
+		Instruments: map[string]RetentionPolicy{
+			"binance:BTCUSDT": {MaxCount: 50000},
+		},
+	}
+
+	tests := []struct {
+		exchange, pair string
+		want           RetentionPolicy
+	}{
+		{"kraken", "BTCUSD", RetentionPolicy{MaxCount: 1000}},
+		{"binance", "ETHUSDT", RetentionPolicy{MaxCount: 1000, MaxAge: Duration{Duration: time.Hour}}},
+		{"binance", "BTCUSDT", RetentionPolicy{MaxCount: 50000, MaxAge: Duration{Duration: time.Hour}}},
+	}
+	for _, tt := range tests {
+		if got := config.For(tt.exchange, tt.pair); got != tt.want {
+			t.Errorf("For(%s, %s) = %+v, want %+v", tt.exchange, tt.pair, got, tt.want)
+		}
+	}
+
+	if got := (RetentionConfig{}).For("kraken", "BTCUSD"); got.MaxCount != DefaultMaxTrades {
+		t.Errorf("empty config keeps %d trades, want %d", got.MaxCount, DefaultMaxTrades)
+	}
+}
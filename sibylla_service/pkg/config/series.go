@@ -0,0 +1,36 @@
+package exchangeconfig
+
+import "time"
+
+// Series engine defaults used when the config leaves a field unset
+const (
+	DefaultSeriesInterval  = time.Second
+	DefaultSeriesMaxStored = 86400
+)
+
+// SeriesDefinition names an expression evaluated continuously, e.g.
+// {"name": "basis", "expr": "kraken:BTCUSD.last - binance:BTCUSDT.last"}.
+type SeriesDefinition struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// SeriesConfig controls the derived series engine.
+type SeriesConfig struct {
+	Definitions []SeriesDefinition `json:"definitions"`
+	// Interval is how often every series is evaluated
+	Interval Duration `json:"interval"`
+	// MaxStored is the number of values kept per series
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c SeriesConfig) WithDefaults() SeriesConfig {
+	if c.Interval.Duration <= 0 {
+		c.Interval.Duration = DefaultSeriesInterval
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultSeriesMaxStored
+	}
+	return c
+}
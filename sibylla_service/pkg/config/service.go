@@ -0,0 +1,50 @@
+package exchangeconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// ServiceConfig is the file based configuration for the service.
+type ServiceConfig struct {
+	Retention  RetentionConfig  `json:"retention"`
+	Candles    CandleConfig     `json:"candles"`
+	Bars       BarConfig        `json:"bars"`
+	Spreads    SpreadConfig     `json:"spreads"`
+	Arbitrage  ArbitrageConfig  `json:"arbitrage"`
+	Index      IndexConfig      `json:"index"`
+	Conversion ConversionConfig `json:"conversion"`
+	Pegs       PegMonitorConfig `json:"pegs"`
+	Averages   AveragesConfig   `json:"averages"`
+	Volatility VolatilityConfig `json:"volatility"`
+	Flow       FlowConfig       `json:"flow"`
+	Whales     WhaleConfig      `json:"whales"`
+	LeadLag    LeadLagConfig    `json:"lead_lag"`
+	Filter     FilterConfig     `json:"filter"`
+	Alerts     AlertConfig      `json:"alerts"`
+	Series     SeriesConfig     `json:"series"`
+	Stream     StreamConfig     `json:"stream"`
+}
+
+// LoadServiceConfig reads the JSON config at path. A missing file yields the defaults.
+func LoadServiceConfig(path string) (ServiceConfig, error) {
+	var cfg ServiceConfig
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No config file at %s, using defaults", path)
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
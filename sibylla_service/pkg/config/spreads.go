@@ -0,0 +1,36 @@
+package exchangeconfig
+
+import "time"
+
+// Spread engine defaults used when the config leaves a field unset
+const (
+	DefaultSpreadMaxStaleness   = 30 * time.Second
+	DefaultSpreadSampleInterval = time.Second
+	DefaultSpreadMaxStored      = 86400
+)
+
+// SpreadConfig controls the cross-exchange spread engine.
+type SpreadConfig struct {
+	// MaxStaleness excludes venues whose last trade is older than this
+	MaxStaleness Duration `json:"max_staleness"`
+	// SampleInterval is the minimum time between stored points of a spread's time series
+	SampleInterval Duration `json:"sample_interval"`
+	// Price is the price taken from each venue, the last trade by default
+	Price PriceSource `json:"price"`
+	// MaxStored is the number of points kept per spread time series
+	MaxStored int64 `json:"max_stored"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c SpreadConfig) WithDefaults() SpreadConfig {
+	if c.MaxStaleness.Duration <= 0 {
+		c.MaxStaleness.Duration = DefaultSpreadMaxStaleness
+	}
+	if c.SampleInterval.Duration <= 0 {
+		c.SampleInterval.Duration = DefaultSpreadSampleInterval
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultSpreadMaxStored
+	}
+	return c
+}
@@ -0,0 +1,60 @@
+package exchangeconfig
+
+import "time"
+
+// Stream defaults used when the config leaves a field unset
+const (
+	DefaultStreamQueueSize        = 256
+	DefaultStreamWriteTimeout     = 10 * time.Second
+	DefaultStreamPingInterval     = 30 * time.Second
+	DefaultStreamMaxSubscriptions = 64
+	DefaultStreamReplayBuffer     = 10000
+	DefaultStreamHeartbeat        = 15 * time.Second
+)
+
+// StreamConfig controls the /ws and /events streaming APIs.
+type StreamConfig struct {
+	// AllowedOrigins lists the browser origins allowed to connect, such as
+	// "https://dash.example.com" or "https://*.example.com"; "*" allows any.
+	// Empty allows only the service's own origin. Clients that send no
+	// Origin header, which browsers always do, are not restricted.
+	AllowedOrigins []string `json:"allowed_origins"`
+	// QueueSize is the number of messages queued per client before it is
+	// disconnected as a slow consumer
+	QueueSize int `json:"queue_size"`
+	// WriteTimeout bounds a single write to a client
+	WriteTimeout Duration `json:"write_timeout"`
+	// PingInterval is how often clients are pinged; those that don't answer
+	// within two intervals are disconnected
+	PingInterval     Duration `json:"ping_interval"`
+	MaxSubscriptions int      `json:"max_subscriptions"` // per client
+	// ReplayBuffer is the number of recent updates kept per channel for
+	// clients resuming after a reconnect
+	ReplayBuffer int `json:"replay_buffer"`
+	// HeartbeatInterval is how often idle server-sent event streams get a
+	// comment, so proxies don't time them out
+	HeartbeatInterval Duration `json:"heartbeat_interval"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c StreamConfig) WithDefaults() StreamConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultStreamQueueSize
+	}
+	if c.WriteTimeout.Duration <= 0 {
+		c.WriteTimeout.Duration = DefaultStreamWriteTimeout
+	}
+	if c.PingInterval.Duration <= 0 {
+		c.PingInterval.Duration = DefaultStreamPingInterval
+	}
+	if c.MaxSubscriptions <= 0 {
+		c.MaxSubscriptions = DefaultStreamMaxSubscriptions
+	}
+	if c.ReplayBuffer <= 0 {
+		c.ReplayBuffer = DefaultStreamReplayBuffer
+	}
+	if c.HeartbeatInterval.Duration <= 0 {
+		c.HeartbeatInterval.Duration = DefaultStreamHeartbeat
+	}
+	return c
+}
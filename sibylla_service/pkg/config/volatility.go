@@ -0,0 +1,46 @@
+package exchangeconfig
+
+import "time"
+
+// Volatility service defaults used when the config leaves a field unset
+const (
+	DefaultVolatilityInterval    = "1m"
+	DefaultVolatilityEWMALambda  = 0.94
+	DefaultVolatilityTradingDays = 365
+)
+
+// DefaultVolatilityWindows are estimated over when the config lists none.
+var DefaultVolatilityWindows = []Duration{
+	{Duration: time.Hour},
+	{Duration: 24 * time.Hour},
+	{Duration: 7 * 24 * time.Hour},
+}
+
+// VolatilityConfig controls the realized volatility service.
+type VolatilityConfig struct {
+	// Interval is the candle interval the estimators run on, e.g. "1m"
+	Interval string `json:"interval"`
+	// Windows are the lookback periods estimated over, e.g. ["1h", "24h", "168h"]
+	Windows []Duration `json:"windows"`
+	// EWMALambda is the decay of the EWMA estimator, 0.94 as in RiskMetrics
+	EWMALambda float64 `json:"ewma_lambda"`
+	// TradingDays annualizes the estimates; crypto trades every day of the year
+	TradingDays float64 `json:"trading_days"`
+}
+
+// WithDefaults fills the fields left unset.
+func (c VolatilityConfig) WithDefaults() VolatilityConfig {
+	if c.Interval == "" {
+		c.Interval = DefaultVolatilityInterval
+	}
+	if len(c.Windows) == 0 {
+		c.Windows = DefaultVolatilityWindows
+	}
+	if c.EWMALambda <= 0 || c.EWMALambda >= 1 {
+		c.EWMALambda = DefaultVolatilityEWMALambda
+	}
+	if c.TradingDays <= 0 {
+		c.TradingDays = DefaultVolatilityTradingDays
+	}
+	return c
+}
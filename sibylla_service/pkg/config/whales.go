@@ -0,0 +1,65 @@
+package exchangeconfig
+
+import "time"
+
+// Whale detector defaults used when the config leaves a field unset
+const (
+	DefaultWhaleSamples          = 5000
+	DefaultWhaleClusterWindow    = 2 * time.Second
+	DefaultWhaleClusterMinTrades = 3
+	DefaultWhaleContextWindow    = time.Minute
+	DefaultWhaleMaxStored        = 10000
+)
+
+// WhaleThreshold sets the notional, in quote units, above which a trade or a
+// cluster of same-side trades is flagged. With Percentile set the threshold
+// follows that percentile of recent trade notionals and Notional is its floor.
+type WhaleThreshold struct {
+	Notional   float64 `json:"notional,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"` // e.g. 99.9
+}
+
+// WhaleConfig controls large-trade detection. Instrument keys are
+// "<exchange>:<pair>", falling back to Default.
+type WhaleConfig struct {
+	Default     WhaleThreshold            `json:"default"`
+	Instruments map[string]WhaleThreshold `json:"instruments"`
+	// Samples is how many recent trades a percentile threshold is taken over
+	Samples int `json:"samples"`
+	// ClusterWindow is how close together same-side trades must be to count as one sliced order
+	ClusterWindow Duration `json:"cluster_window"`
+	// ClusterMinTrades is the fewest trades that make a cluster
+	ClusterMinTrades int `json:"cluster_min_trades"`
+	// ContextWindow is how much recent venue activity is attached to an event
+	ContextWindow Duration `json:"context_window"`
+	// MaxStored is the number of events kept per stream
+	MaxStored int64 `json:"max_stored"`
+}
+
+// For resolves the threshold for an instrument.
+func (c WhaleConfig) For(exchange, pair string) WhaleThreshold {
+	if t, ok := c.Instruments[exchange+":"+pair]; ok {
+		return t
+	}
+	return c.Default
+}
+
+// WithDefaults fills the fields left unset.
+func (c WhaleConfig) WithDefaults() WhaleConfig {
+	if c.Samples <= 0 {
+		c.Samples = DefaultWhaleSamples
+	}
+	if c.ClusterWindow.Duration <= 0 {
+		c.ClusterWindow.Duration = DefaultWhaleClusterWindow
+	}
+	if c.ClusterMinTrades <= 0 {
+		c.ClusterMinTrades = DefaultWhaleClusterMinTrades
+	}
+	if c.ContextWindow.Duration <= 0 {
+		c.ContextWindow.Duration = DefaultWhaleContextWindow
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = DefaultWhaleMaxStored
+	}
+	return c
+}
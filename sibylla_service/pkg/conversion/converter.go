@@ -0,0 +1,265 @@
+package conversion
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+)
+
+// parCost makes assumed 1:1 pegs a last resort compared to live markets,
+// including two-hop paths through a third asset.
+const parCost = 3.5
+
+// Hop is one conversion step along a path.
+type Hop struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Rate     float64 `json:"rate"` // units of To per unit of From
+	Exchange string  `json:"exchange,omitempty"`
+	Pair     string  `json:"pair,omitempty"`
+	Par      bool    `json:"par,omitempty"` // assumed 1:1, no live market
+	AgeMs    int64   `json:"age_ms"`
+}
+
+// Rate converts one asset into another through a path of hops.
+type Rate struct {
+	From string  `json:"from"`
+	To   string  `json:"to"`
+	Rate float64 `json:"rate"`
+	Path []Hop   `json:"path"`
+}
+
+// Price is an instrument's price restated in another quote asset.
+type Price struct {
+	Exchange       string  `json:"exchange,omitempty"`
+	Pair           string  `json:"pair"`
+	Base           string  `json:"base"`
+	Quote          string  `json:"quote"`
+	Price          float64 `json:"price"` // in Quote
+	To             string  `json:"to"`
+	ConvertedPrice float64 `json:"converted_price"` // in To
+	Conversion     Rate    `json:"conversion"`
+}
+
+// Converter turns prices into any reachable quote asset, walking the asset
+// graph over live markets and picking the freshest, most liquid path.
+type Converter struct {
+	mu      sync.Mutex
+	config  exchangeconfig.ConversionConfig
+	tracker *market.Tracker
+	trades  map[string]*market.RollingSum // trade count by exchange:pair
+}
+
+func NewConverter(tracker *market.Tracker, config exchangeconfig.ConversionConfig) *Converter {
+	return &Converter{
+		config:  config.WithDefaults(),
+		tracker: tracker,
+		trades:  make(map[string]*market.RollingSum),
+	}
+}
+
+// OnTrade records market activity used to rank competing paths.
+func (c *Converter) OnTrade(t models.Trade) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := t.Exchange + ":" + t.Pair
+	if c.trades[key] == nil {
+		c.trades[key] = market.NewRollingSum(c.config.LiquidityWindow.Duration)
+	}
+	c.trades[key].Add(time.Now(), 1)
+}
+
+// Rate finds the best conversion from one asset to another.
+func (c *Converter) Rate(from, to string) (Rate, error) {
+	return c.rate(from, to, "")
+}
+
+// rate finds the best conversion that doesn't pass through the exclude asset.
+func (c *Converter) rate(from, to, exclude string) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Rate: 1}, nil
+	}
+	if exclude == from || exclude == to {
+		exclude = ""
+	}
+	if _, ok := models.LookupAsset(from); !ok {
+		return Rate{}, fmt.Errorf("unknown asset %s", from)
+	}
+	if _, ok := models.LookupAsset(to); !ok {
+		return Rate{}, fmt.Errorf("unknown asset %s", to)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path, ok := c.shortestPath(from, to, exclude, time.Now())
+	if !ok {
+		return Rate{}, fmt.Errorf("no live conversion path from %s to %s", from, to)
+	}
+
+	rate := Rate{From: from, To: to, Rate: 1, Path: path}
+	for _, hop := range path {
+		rate.Rate *= hop.Rate
+	}
+	return rate, nil
+}
+
+// Convert restates the last price of a pair on an exchange in another quote
+// asset. With no exchange the pair is derived entirely from the asset graph,
+// which also covers synthetic pairs such as ETHBTC.
+func (c *Converter) Convert(exchange, pair, to string) (Price, error) {
+	base, quote, ok := models.SplitPair(pair)
+	if !ok {
+		return Price{}, fmt.Errorf("cannot split pair %s into base and quote", pair)
+	}
+	result := Price{Exchange: exchange, Pair: pair, Base: base, Quote: quote, To: to}
+
+	if exchange == "" {
+		rate, err := c.Rate(base, quote)
+		if err != nil {
+			return Price{}, err
+		}
+		result.Price = rate.Rate
+	} else {
+		q, ok := c.tracker.Last(exchange, pair)
+		if !ok {
+			return Price{}, fmt.Errorf("no trades for %s on %s", pair, exchange)
+		}
+		result.Price = q.Price
+	}
+
+	rate, err := c.QuoteRate(pair, to)
+	if err != nil {
+		return Price{}, err
+	}
+	result.Conversion = rate
+	result.ConvertedPrice = result.Price * rate.Rate
+	return result, nil
+}
+
+// QuoteRate finds the best conversion from a pair's quote asset into another.
+// It doesn't go through the base asset itself: restating BTCUSDT in USD via
+// BTCUSD would just return the BTCUSD price.
+func (c *Converter) QuoteRate(pair, to string) (Rate, error) {
+	base, quote, ok := models.SplitPair(pair)
+	if !ok {
+		return Rate{}, fmt.Errorf("cannot split pair %s into base and quote", pair)
+	}
+	return c.rate(quote, to, base)
+}
+
+// shortestPath runs Dijkstra over the asset graph, using the cheapest live
+// market (or par peg) for every edge and skipping the exclude asset.
+func (c *Converter) shortestPath(from, to, exclude string, now time.Time) ([]Hop, bool) {
+	dist := map[string]float64{from: 0}
+	prev := make(map[string]Hop)
+	visited := map[string]bool{exclude: true}
+
+	for {
+		current, best := "", math.Inf(1)
+		for asset, d := range dist {
+			if !visited[asset] && d < best {
+				current, best = asset, d
+			}
+		}
+		if current == "" {
+			return nil, false
+		}
+		if current == to {
+			break
+		}
+		visited[current] = true
+
+		for _, next := range neighbours(current) {
+			if visited[next] {
+				continue
+			}
+			hop, cost, ok := c.bestEdge(current, next, now)
+			if !ok {
+				continue
+			}
+			if d, seen := dist[next]; !seen || best+cost < d {
+				dist[next] = best + cost
+				prev[next] = hop
+			}
+		}
+	}
+
+	var path []Hop
+	for asset := to; asset != from; asset = prev[asset].From {
+		path = append([]Hop{prev[asset]}, path...)
+	}
+	return path, true
+}
+
+// bestEdge picks the cheapest way to convert from into to: a live market in
+// either direction on any exchange, or a par peg between equivalents.
+func (c *Converter) bestEdge(from, to string, now time.Time) (Hop, float64, bool) {
+	var best Hop
+	bestCost := math.Inf(1)
+
+	consider := func(pair string, inverse bool) {
+		for _, q := range c.tracker.Venues(pair) {
+			age := q.Age(now)
+			if age > c.config.MaxStaleness.Duration || q.Price <= 0 {
+				continue
+			}
+			cost := 1 + age.Seconds()/c.config.MaxStaleness.Duration.Seconds() + 1/(1+c.tradeCount(q.Exchange, pair, now))
+			if cost >= bestCost {
+				continue
+			}
+			rate := q.Price
+			if inverse {
+				rate = 1 / q.Price
+			}
+			best = Hop{From: from, To: to, Rate: rate, Exchange: q.Exchange, Pair: pair, AgeMs: age.Milliseconds()}
+			bestCost = cost
+		}
+	}
+	consider(from+to, false)
+	consider(to+from, true)
+
+	if math.IsInf(bestCost, 1) && !c.config.DisablePar && models.AreEquivalent(from, to) {
+		return Hop{From: from, To: to, Rate: 1, Par: true}, parCost, true
+	}
+	return best, bestCost, !math.IsInf(bestCost, 1)
+}
+
+func (c *Converter) tradeCount(exchange, pair string, now time.Time) float64 {
+	if r := c.trades[exchange+":"+pair]; r != nil {
+		return r.Sum(now)
+	}
+	return 0
+}
+
+// neighbours returns the assets related to an asset in either direction.
+func neighbours(symbol string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	add := func(s string) {
+		if s != symbol && !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+
+	asset, _ := models.LookupAsset(symbol)
+	for _, related := range asset.RelatedAssets {
+		add(related)
+	}
+	for _, other := range models.AssetSymbols() {
+		a, _ := models.LookupAsset(other)
+		for _, related := range a.RelatedAssets {
+			if related == symbol {
+				add(other)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package conversion
+
+import (
+	"math"
+	"testing"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+)
+
+func newTestConverter(config exchangeconfig.ConversionConfig, trades ...models.Trade) *Converter {
+	tracker := market.NewTracker()
+	c := NewConverter(tracker, config)
+	for _, t := range trades {
+		tracker.Update(t)
+		c.OnTrade(t)
+	}
+	return c
+}
+
+func TestRate(t *testing.T) {
+	c := newTestConverter(exchangeconfig.ConversionConfig{},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 50000},
+		models.Trade{Exchange: "binance", Pair: "ETHUSDT", Price: 2500},
+	)
+
+	tests := []struct {
+		from, to string
+		want     float64
+		hops     int
+	}{
+		{"BTC", "USDT", 50000, 1},
+		{"USDT", "ETH", 1.0 / 2500, 1},
+		{"ETH", "BTC", 0.05, 2}, // no ETHBTC market, through USDT
+		{"BTC", "BTC", 1, 0},
+	}
+	for _, tt := range tests {
+		rate, err := c.Rate(tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Rate(%s, %s): %v", tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(rate.Rate-tt.want) > 1e-12 || len(rate.Path) != tt.hops {
+			t.Errorf("Rate(%s, %s) = %v over %d hops, want %v over %d", tt.from, tt.to, rate.Rate, len(rate.Path), tt.want, tt.hops)
+		}
+	}
+
+	if _, err := c.Rate("BTC", "DOGE"); err == nil {
+		t.Error("converted to an unknown asset")
+	}
+}
+
+func TestParPegIsLastResort(t *testing.T) {
+	c := newTestConverter(exchangeconfig.ConversionConfig{},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 50000},
+	)
+	rate, err := c.Rate("BTC", "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Rate != 50000 || len(rate.Path) != 2 || !rate.Path[1].Par {
+		t.Errorf("Rate(BTC, USD) = %+v, want BTCUSDT then USDT at par", rate)
+	}
+
+	// A live USDTUSD market beats the par peg
+	c = newTestConverter(exchangeconfig.ConversionConfig{},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 50000},
+		models.Trade{Exchange: "kraken", Pair: "USDTUSD", Price: 0.999},
+	)
+	rate, err = c.Rate("BTC", "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(rate.Rate-49950) > 1e-9 || rate.Path[1].Par || rate.Path[1].Exchange != "kraken" {
+		t.Errorf("Rate(BTC, USD) = %+v, want through kraken USDTUSD", rate)
+	}
+
+	c = newTestConverter(exchangeconfig.ConversionConfig{DisablePar: true},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 50000},
+	)
+	if _, err := c.Rate("BTC", "USD"); err == nil {
+		t.Error("converted over a par peg with par disabled")
+	}
+}
+
+func TestConvertSkipsBaseAsset(t *testing.T) {
+	c := newTestConverter(exchangeconfig.ConversionConfig{},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 50000},
+		models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 50100},
+	)
+	price, err := c.Convert("binance", "BTCUSDT", "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// USDT to USD must not go through BTC, which would just return kraken's price
+	if price.ConvertedPrice != 50000 || price.Conversion.Path[0].To != "USD" || !price.Conversion.Path[0].Par {
+		t.Errorf("Convert(binance, BTCUSDT, USD) = %+v, want USDT at par", price)
+	}
+}
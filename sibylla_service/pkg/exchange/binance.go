@@ -47,6 +47,7 @@ func ConnectBinanceWebSocket(config exchangeconfig.Config, pairs []string) {
 					log.Println("read:", err)
 					return
 				}
+				received := time.Now()
 
 				// Unpack the trade message into the BinanceTrade struct
 				var binanceMessage trade.BinanceMessageMultistream
@@ -65,10 +66,11 @@ func ConnectBinanceWebSocket(config exchangeconfig.Config, pairs []string) {
 					Quantity:     func() float64 { q, _ := strconv.ParseFloat(binanceMessage.Data.Quantity, 64); return q }(),
 					Timestamp:    binanceMessage.Data.TradeTime,
 					IsBuyerMaker: binanceMessage.Data.IsBuyerMaker,
+					ReceivedAt:   received.UnixMilli(),
 				}
 
 				// Push the trade struct into redis
-				err = config.RedisClient.PushToList("trades:binance:"+binanceMessage.Data.Symbol, tradeData, 100)
+				err = storeTrade(config, tradeData)
 				if err != nil {
 					log.Printf("Could not push trade to Redis: %v", err)
 				} else {
@@ -65,6 +65,7 @@ func ConnectCoinbaseWebSocket(config exchangeconfig.Config, pairs []string) {
 					log.Println("read:", err)
 					return
 				}
+				received := time.Now()
 
 				// Unpack the trade message into the CoinbaseTrade struct
 				var coinbaseTrade trade.CoinbaseTradeMessage
@@ -77,19 +78,24 @@ func ConnectCoinbaseWebSocket(config exchangeconfig.Config, pairs []string) {
 				// Iterate over the events in the CoinbaseTradeMessage
 				for _, event := range coinbaseTrade.Events {
 					for _, tradeData := range event.Trades {
+						pair, _ := ConvertPairReverse(tradeData.ProductID, "coinbase")
+						if pair == "" {
+							pair = tradeData.ProductID
+						}
+
 						// Map CoinbaseTrade data to the Trade struct
 						trade := trade.Trade{
 							Exchange:     "coinbase",
-							Pair:         tradeData.ProductID,
+							Pair:         pair, // Map back to our language for pairs
 							Price:        func() float64 { p, _ := strconv.ParseFloat(tradeData.Price, 64); return p }(),
 							Quantity:     func() float64 { q, _ := strconv.ParseFloat(tradeData.Size, 64); return q }(),
-							Timestamp:    func() int64 { t, _ := time.Parse(time.RFC3339, tradeData.Time); return t.Unix() }(),
+							Timestamp:    func() int64 { t, _ := time.Parse(time.RFC3339Nano, tradeData.Time); return t.UnixMilli() }(),
 							IsBuyerMaker: tradeData.Side == "sell",
+							ReceivedAt:   received.UnixMilli(),
 						}
 
 						// Push the trade struct into redis
-						redisKey := "trades:coinbase:" + tradeData.ProductID
-						err = config.RedisClient.PushToList(redisKey, trade, 100)
+						err = storeTrade(config, trade)
 						if err != nil {
 							log.Printf("Could not push trade to Redis: %v", err)
 						} else {
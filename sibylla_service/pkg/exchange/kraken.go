@@ -8,7 +8,6 @@ import (
 	"os/signal"
 	exchangeconfig "sibylla_service/pkg/config"
 	trade "sibylla_service/pkg/models"
-	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -66,6 +65,7 @@ func ConnectKrakenWebSocket(config exchangeconfig.Config, pairs []string) {
 					log.Println("read:", err)
 					return
 				}
+				received := time.Now()
 
 				// Unpack the trade message into the KrakenTrade struct
 				var krakenTrade trade.KrakenTradeMessage
@@ -77,6 +77,9 @@ func ConnectKrakenWebSocket(config exchangeconfig.Config, pairs []string) {
 				}
 				for _, tradeData := range krakenTrade.Data {
 					pair, err := ConvertPairReverse(tradeData.Symbol, "kraken")
+					if pair == "" {
+						pair = tradeData.Symbol
+					}
 
 					// Map KrakenTradeMessage data to the Trade struct
 					trade := trade.Trade{
@@ -84,13 +87,13 @@ func ConnectKrakenWebSocket(config exchangeconfig.Config, pairs []string) {
 						Pair:         pair, // Map back to our language for pairs
 						Price:        tradeData.Price,
 						Quantity:     tradeData.Quantity,
-						Timestamp:    func() int64 { t, _ := strconv.ParseInt(tradeData.Timestamp, 10, 64); return t }(),
+						Timestamp:    func() int64 { t, _ := time.Parse(time.RFC3339Nano, tradeData.Timestamp); return t.UnixMilli() }(),
 						IsBuyerMaker: tradeData.Side == "sell",
+						ReceivedAt:   received.UnixMilli(),
 					}
 
 					// Push the trade struct into redis
-					redisKey := "trades:kraken:" + tradeData.Symbol
-					err = config.RedisClient.PushToList(redisKey, trade, 100)
+					err = storeTrade(config, trade)
 					if err != nil {
 						log.Printf("Could not push trade to Redis: %v", err)
 					} else {
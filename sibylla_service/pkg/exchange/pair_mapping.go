@@ -14,8 +14,9 @@ var pairMappings = map[string]map[string]string{
 		"WBTCUSDT": "wbtcusdt",
 	},
 	"kraken": {
-		"BTCUSD": "BTC/USD",
-		"ETHUSD": "ETH/USD",
+		"BTCUSD":  "BTC/USD",
+		"ETHUSD":  "ETH/USD",
+		"USDTUSD": "USDT/USD",
 	},
 	"coinbase": {
 		"BTCUSD": "BTC-USD",
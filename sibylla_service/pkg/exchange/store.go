@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"log"
+	"sort"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	trade "sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// storeTrade appends a normalized trade to its instrument's stream at its trade time, trimmed
+// according to the retention policy configured for that instrument, and
+// publishes it to in-process consumers. Trades the filter rejects go no further.
+func storeTrade(config exchangeconfig.Config, t trade.Trade) error {
+	if config.Filter != nil && !config.Filter.Admit(t) {
+		return nil
+	}
+	if config.Bus != nil {
+		config.Bus.Publish(bus.TopicTrades, t)
+	}
+
+	policy := config.Retention.For(t.Exchange, t.Pair)
+	return config.RedisClient.AppendToStreamAt(trade.TradeKey(t.Exchange, t.Pair), t.Timestamp, t, policy.MaxCount, policy.Age())
+}
+
+// MigrateTradeLists converts trade keys still holding the lists trades were
+// stored in before streams, which appending to would fail with WRONGTYPE.
+func MigrateTradeLists(redisClient *redisclient.RedisClient) {
+	keys, err := redisClient.ScanKeys(trade.TradeKey("*", "*"))
+	if err != nil {
+		log.Printf("Could not look for trade lists to migrate: %v", err)
+		return
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		// SCAN may return a key twice
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+		n, err := redisClient.ConvertListToStream(key, func(value string) int64 {
+			t, err := trade.DecodeTrade(value)
+			if err != nil {
+				return 0
+			}
+			return t.Timestamp
+		})
+		if err != nil {
+			log.Printf("Could not migrate trade list %s: %v", key, err)
+		} else if n > 0 {
+			log.Printf("Migrated %d trades in %s from a list to a stream", n, key)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	trade "sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+func TestMigrateTradeLists(t *testing.T) {
+	m := miniredis.RunT(t)
+	redisClient := redisclient.NewRedisClient(m.Addr(), "", 0)
+
+	key := trade.TradeKey("binance", "BTCUSDT")
+	for _, ts := range []int64{1000, 2000} {
+		legacy, _ := json.Marshal(trade.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Timestamp: ts})
+		m.Lpush(key, string(legacy))
+	}
+
+	MigrateTradeLists(redisClient)
+
+	entries, err := m.Stream(key)
+	if err != nil {
+		t.Fatalf("trade key is not a stream after migrating: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "1000-0" || entries[1].ID != "2000-0" {
+		t.Fatalf("migrated entries %v, want IDs at the trade times", entries)
+	}
+	decoded, err := trade.DecodeTrade(entries[1].Values[1])
+	if err != nil || decoded.Timestamp != 2000 {
+		t.Fatalf("migrated trade decodes to %+v, %v", decoded, err)
+	}
+
+	// New trades append to the migrated stream
+	if err := storeTrade(exchangeconfig.Config{RedisClient: redisClient}, trade.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101, Timestamp: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := m.Stream(key); len(entries) != 3 || entries[2].ID != "3000-0" {
+		t.Fatalf("stored trade got entries %v, want ID 3000-0 last", entries)
+	}
+}
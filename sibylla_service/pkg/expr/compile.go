@@ -0,0 +1,548 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"sibylla_service/pkg/average"
+)
+
+// MaxWindow bounds the window of sma, ema and delta, which keep history.
+const MaxWindow = 24 * time.Hour
+
+// maxSamples bounds the history a stateful function keeps: samples closer
+// together than window/maxSamples replace each other.
+const maxSamples = 4096
+
+// evalFunc is a compiled, type checked expression.
+type evalFunc func(now time.Time) (float64, error)
+
+// Program is a compiled expression. Stateful functions keep their history in
+// the program, so each continuously evaluated expression needs its own.
+type Program struct {
+	engine   *Engine
+	source   string
+	root     evalFunc
+	deps     []string
+	stateful bool
+}
+
+// String returns the expression the program was compiled from.
+func (p *Program) String() string {
+	return p.source
+}
+
+// Deps returns the named series the expression refers to, sorted.
+func (p *Program) Deps() []string {
+	return p.deps
+}
+
+// Stateful reports whether the expression keeps history, such as sma.
+func (p *Program) Stateful() bool {
+	return p.stateful
+}
+
+// Eval evaluates the expression against the live market.
+func (p *Program) Eval(now time.Time) (float64, error) {
+	p.engine.mu.Lock()
+	defer p.engine.mu.Unlock()
+	return p.eval(now)
+}
+
+func (p *Program) eval(now time.Time) (float64, error) {
+	v, err := p.root(now)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+type compiler struct {
+	engine   *Engine
+	deps     map[string]bool
+	stateful bool
+}
+
+// compile parses and type checks an expression. The engine's lock must be held.
+func (e *Engine) compile(src string) (*Program, error) {
+	tree, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{engine: e, deps: make(map[string]bool)}
+	root, err := c.number(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Program{engine: e, source: src, root: root, stateful: c.stateful}
+	for name := range c.deps {
+		p.deps = append(p.deps, name)
+	}
+	sort.Strings(p.deps)
+	return p, nil
+}
+
+// number compiles a node that must evaluate to a number.
+func (c *compiler) number(n node) (evalFunc, error) {
+	switch n := n.(type) {
+	case numberLit:
+		v := n.value
+		return func(time.Time) (float64, error) { return v, nil }, nil
+	case durationLit:
+		return nil, errorf(n.pos, "expected a number but found window %s", n.text)
+	case ident:
+		return c.series(n)
+	case marketRef:
+		return c.market(n)
+	case call:
+		return c.call(n)
+	case unary:
+		x, err := c.number(n.x)
+		if err != nil {
+			return nil, err
+		}
+		return func(now time.Time) (float64, error) {
+			v, err := x(now)
+			return -v, err
+		}, nil
+	case binary:
+		return c.binary(n)
+	}
+	return nil, errorf(n.column(), "unsupported expression")
+}
+
+func (c *compiler) binary(n binary) (evalFunc, error) {
+	x, err := c.number(n.x)
+	if err != nil {
+		return nil, err
+	}
+	y, err := c.number(n.y)
+	if err != nil {
+		return nil, err
+	}
+
+	op := n.op
+	return func(now time.Time) (float64, error) {
+		a, err := x(now)
+		if err != nil {
+			return 0, err
+		}
+		b, err := y(now)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		}
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero at column %d", n.pos)
+		}
+		return a / b, nil
+	}, nil
+}
+
+// series compiles a reference to a named series.
+func (c *compiler) series(n ident) (evalFunc, error) {
+	if _, ok := c.engine.series[n.name]; !ok {
+		if _, isFunc := builtins[n.name]; isFunc {
+			return nil, errorf(n.pos, "%s is a function, call it as %s(...)", n.name, n.name)
+		}
+		return nil, errorf(n.pos, "unknown series %s, market prices are written exchange:PAIR.last", n.name)
+	}
+	c.deps[n.name] = true
+
+	e, name := c.engine, n.name
+	return func(time.Time) (float64, error) {
+		v, ok := e.values[name]
+		if !ok {
+			return 0, fmt.Errorf("series %s has no value yet", name)
+		}
+		if !v.Valid {
+			return 0, fmt.Errorf("series %s is unavailable: %s", name, v.Error)
+		}
+		return v.Value, nil
+	}, nil
+}
+
+// Market fields and whether they take a window
+var marketFields = map[string]bool{
+	"last":   false, // last trade price
+	"age":    false, // seconds since the last trade
+	"vwap":   true,
+	"twap":   true,
+	"volume": true, // base volume traded in the window
+}
+
+func (c *compiler) market(n marketRef) (evalFunc, error) {
+	needsWindow, ok := marketFields[n.field]
+	if !ok {
+		return nil, errorf(n.fieldPos, "unknown field %s, use one of %s", n.field, strings.Join(sortedKeys(marketFields), ", "))
+	}
+	if !needsWindow {
+		if n.called {
+			return nil, errorf(n.fieldPos, "%s takes no arguments", n.field)
+		}
+		return c.last(n), nil
+	}
+
+	if len(n.args) != 1 {
+		return nil, errorf(n.fieldPos, "%s needs one window, e.g. %s:%s.%s(5m)", n.field, n.exchange, n.pair, n.field)
+	}
+	window, err := c.trackedWindow(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	averages, exchange, pair, field := c.engine.averages, n.exchange, n.pair, n.field
+	return func(time.Time) (float64, error) {
+		a, ok := averages.Get(exchange, pair, window)
+		if !ok {
+			return 0, fmt.Errorf("no trades for %s on %s", pair, exchange)
+		}
+		switch field {
+		case "volume":
+			return a.Volume, nil
+		case "twap":
+			if a.Trades == 0 {
+				break
+			}
+			return a.TWAP, nil
+		default:
+			if a.Volume <= 0 {
+				break
+			}
+			return a.VWAP, nil
+		}
+		return 0, fmt.Errorf("nothing traded for %s on %s in the last %s", pair, exchange, average.WindowName(window))
+	}, nil
+}
+
+func (c *compiler) last(n marketRef) evalFunc {
+	tracker, exchange, pair, field := c.engine.tracker, n.exchange, n.pair, n.field
+	return func(now time.Time) (float64, error) {
+		q, ok := tracker.Last(exchange, pair)
+		if !ok {
+			return 0, fmt.Errorf("no trades for %s on %s", pair, exchange)
+		}
+		if field == "age" {
+			return q.Age(now).Seconds(), nil
+		}
+		return q.Price, nil
+	}
+}
+
+// trackedWindow checks a window argument against the averaging windows.
+func (c *compiler) trackedWindow(n node) (time.Duration, error) {
+	window, err := c.window(n)
+	if err != nil {
+		return 0, err
+	}
+	if c.engine.averages == nil {
+		return 0, errorf(n.column(), "rolling averages are not available")
+	}
+
+	var names []string
+	for _, w := range c.engine.averages.Windows() {
+		if w == window {
+			return window, nil
+		}
+		names = append(names, average.WindowName(w))
+	}
+	return 0, errorf(n.column(), "window %s is not tracked, use one of %s", average.WindowName(window), strings.Join(names, ", "))
+}
+
+func (c *compiler) window(n node) (time.Duration, error) {
+	d, ok := n.(durationLit)
+	if !ok {
+		return 0, errorf(n.column(), "expected a window such as 5m")
+	}
+	if d.value <= 0 {
+		return 0, errorf(d.pos, "window %s must be positive", d.text)
+	}
+	return d.value, nil
+}
+
+// name checks an exchange or pair argument.
+func (c *compiler) name(n node, what string) (string, error) {
+	id, ok := n.(ident)
+	if !ok {
+		return "", errorf(n.column(), "expected %s name", what)
+	}
+	return id.name, nil
+}
+
+// builtin compiles a call with already positioned arguments.
+type builtin func(c *compiler, n call) (evalFunc, error)
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"abs": math1(math.Abs, nil),
+		"sqrt": math1(math.Sqrt, func(x float64) error {
+			if x < 0 {
+				return fmt.Errorf("sqrt of negative value %g", x)
+			}
+			return nil
+		}),
+		"log": math1(math.Log, func(x float64) error {
+			if x <= 0 {
+				return fmt.Errorf("log of non-positive value %g", x)
+			}
+			return nil
+		}),
+		"exp":    math1(math.Exp, nil),
+		"min":    extremum(math.Min),
+		"max":    extremum(math.Max),
+		"spread": (*compiler).spread,
+		"sma":    (*compiler).sma,
+		"ema":    (*compiler).ema,
+		"delta":  (*compiler).delta,
+	}
+}
+
+func (c *compiler) call(n call) (evalFunc, error) {
+	b, ok := builtins[n.name]
+	if !ok {
+		return nil, errorf(n.pos, "unknown function %s, use one of %s", n.name, strings.Join(sortedKeys(builtins), ", "))
+	}
+	return b(c, n)
+}
+
+func math1(f func(float64) float64, check func(float64) error) builtin {
+	return func(c *compiler, n call) (evalFunc, error) {
+		if len(n.args) != 1 {
+			return nil, errorf(n.pos, "%s takes one argument, got %d", n.name, len(n.args))
+		}
+		x, err := c.number(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(now time.Time) (float64, error) {
+			v, err := x(now)
+			if err != nil {
+				return 0, err
+			}
+			if check != nil {
+				if err := check(v); err != nil {
+					return 0, err
+				}
+			}
+			return f(v), nil
+		}, nil
+	}
+}
+
+func extremum(f func(a, b float64) float64) builtin {
+	return func(c *compiler, n call) (evalFunc, error) {
+		if len(n.args) < 2 {
+			return nil, errorf(n.pos, "%s takes two or more arguments, got %d", n.name, len(n.args))
+		}
+		args := make([]evalFunc, len(n.args))
+		for i, arg := range n.args {
+			var err error
+			if args[i], err = c.number(arg); err != nil {
+				return nil, err
+			}
+		}
+		return func(now time.Time) (float64, error) {
+			result, err := args[0](now)
+			if err != nil {
+				return 0, err
+			}
+			for _, arg := range args[1:] {
+				v, err := arg(now)
+				if err != nil {
+					return 0, err
+				}
+				result = f(result, v)
+			}
+			return result, nil
+		}, nil
+	}
+}
+
+// spread(a, b, PAIR[, PAIR_B]) is (a - b) / mid in bps between the last prices
+// of two exchanges.
+func (c *compiler) spread(n call) (evalFunc, error) {
+	if len(n.args) != 3 && len(n.args) != 4 {
+		return nil, errorf(n.pos, "spread takes (exchange, exchange, pair) or (exchange, exchange, pair, pair), got %d arguments", len(n.args))
+	}
+	var names [4]string
+	for i, arg := range n.args {
+		what := "an exchange"
+		if i >= 2 {
+			what = "a pair"
+		}
+		name, err := c.name(arg, what)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name
+	}
+	if names[3] == "" {
+		names[3] = names[2]
+	}
+
+	tracker := c.engine.tracker
+	return func(time.Time) (float64, error) {
+		a, ok := tracker.Last(names[0], names[2])
+		if !ok {
+			return 0, fmt.Errorf("no trades for %s on %s", names[2], names[0])
+		}
+		b, ok := tracker.Last(names[1], names[3])
+		if !ok {
+			return 0, fmt.Errorf("no trades for %s on %s", names[3], names[1])
+		}
+		return (a.Price - b.Price) / ((a.Price + b.Price) / 2) * 10000, nil
+	}, nil
+}
+
+// windowed compiles the (expression, window) arguments of sma, ema and delta.
+func (c *compiler) windowed(n call) (evalFunc, time.Duration, error) {
+	if len(n.args) != 2 {
+		return nil, 0, errorf(n.pos, "%s takes (expression, window), got %d arguments", n.name, len(n.args))
+	}
+	x, err := c.number(n.args[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	window, err := c.window(n.args[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	if window > MaxWindow {
+		return nil, 0, errorf(n.args[1].column(), "window can be at most %s", average.WindowName(MaxWindow))
+	}
+	c.stateful = true
+	return x, window, nil
+}
+
+// sma(x, window) is the time weighted mean of x over the window.
+func (c *compiler) sma(n call) (evalFunc, error) {
+	x, window, err := c.windowed(n)
+	if err != nil {
+		return nil, err
+	}
+	h := &history{window: window}
+	return func(now time.Time) (float64, error) {
+		v, err := x(now)
+		if err != nil {
+			return 0, err
+		}
+		h.add(now, v)
+		return h.mean(now), nil
+	}, nil
+}
+
+// ema(x, window) is the exponential moving average of x with a time constant
+// of window, so irregular evaluation doesn't skew it.
+func (c *compiler) ema(n call) (evalFunc, error) {
+	x, window, err := c.windowed(n)
+	if err != nil {
+		return nil, err
+	}
+	var value float64
+	var at time.Time
+	return func(now time.Time) (float64, error) {
+		v, err := x(now)
+		if err != nil {
+			return 0, err
+		}
+		if at.IsZero() {
+			value = v
+		} else if dt := now.Sub(at); dt > 0 {
+			value += (1 - math.Exp(-float64(dt)/float64(window))) * (v - value)
+		}
+		at = now
+		return value, nil
+	}, nil
+}
+
+// delta(x, window) is the change of x over the window, or since the first
+// evaluation while the window is filling.
+func (c *compiler) delta(n call) (evalFunc, error) {
+	x, window, err := c.windowed(n)
+	if err != nil {
+		return nil, err
+	}
+	h := &history{window: window}
+	return func(now time.Time) (float64, error) {
+		v, err := x(now)
+		if err != nil {
+			return 0, err
+		}
+		h.add(now, v)
+		return v - h.samples[0].value, nil
+	}, nil
+}
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// history is a step function of the samples seen over a window. It keeps the
+// last sample before the window so the value at its start is known.
+type history struct {
+	window  time.Duration
+	samples []sample
+}
+
+func (h *history) add(now time.Time, v float64) {
+	if n := len(h.samples); n > 0 && now.Sub(h.samples[n-1].at) < h.window/maxSamples {
+		h.samples[n-1].value = v
+	} else {
+		h.samples = append(h.samples, sample{at: now, value: v})
+	}
+
+	cutoff := now.Add(-h.window)
+	i := sort.Search(len(h.samples), func(i int) bool { return !h.samples[i].at.Before(cutoff) })
+	if i > 1 {
+		h.samples = append(h.samples[:0], h.samples[i-1:]...)
+	}
+}
+
+func (h *history) mean(now time.Time) float64 {
+	cutoff := now.Add(-h.window)
+	var sum, total float64
+	for i, s := range h.samples {
+		start := s.at
+		if start.Before(cutoff) {
+			start = cutoff
+		}
+		end := now
+		if i+1 < len(h.samples) {
+			end = h.samples[i+1].at
+		}
+		if d := end.Sub(start).Seconds(); d > 0 {
+			sum += s.value * d
+			total += d
+		}
+	}
+	if total == 0 {
+		return h.samples[len(h.samples)-1].value
+	}
+	return sum / total
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
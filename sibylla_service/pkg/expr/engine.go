@@ -0,0 +1,429 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/redisclient"
+)
+
+// DefinitionsKey is the hash of series defined through the API, by name.
+const DefinitionsKey = "series:definitions"
+
+// MaxSeries bounds the number of named series.
+const MaxSeries = 256
+
+// ErrNotStored is wrapped by Define when the definition can't be stored.
+var ErrNotStored = errors.New("series definition could not be stored")
+
+// Series sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// Value is one evaluation of a named series. Valid is false when the
+// expression couldn't be evaluated, with the reason in Error.
+type Value struct {
+	Name      string  `json:"name"`
+	Value     float64 `json:"value"`
+	Valid     bool    `json:"valid"`
+	Error     string  `json:"error,omitempty"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (v Value) MarshalBinary() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Key is the store key holding the values of a named series.
+func Key(name string) string {
+	return "series:values:" + name
+}
+
+// Series is a named series with its latest value.
+type Series struct {
+	Name    string   `json:"name"`
+	Expr    string   `json:"expr"`
+	Source  string   `json:"source"`
+	Deps    []string `json:"deps,omitempty"`
+	Current *Value   `json:"current"` // nil until first evaluated
+}
+
+type series struct {
+	name    string
+	source  string
+	program *Program
+}
+
+// Engine compiles expressions over the live market and continuously
+// evaluates named series, which expressions can refer to by name.
+type Engine struct {
+	mu          sync.Mutex
+	config      exchangeconfig.SeriesConfig
+	tracker     *market.Tracker
+	averages    *average.Calculator
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	series      map[string]*series
+	values      map[string]Value
+	users       []func(name string) []string // see AddUsers
+	loaded      bool                         // the stored definitions are loaded
+	onLoad      []func()                     // see OnLoad
+}
+
+func NewEngine(tracker *market.Tracker, averages *average.Calculator, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.SeriesConfig) (*Engine, error) {
+	e := &Engine{
+		config:      config.WithDefaults(),
+		tracker:     tracker,
+		averages:    averages,
+		redisClient: redisClient,
+		bus:         b,
+		series:      make(map[string]*series),
+		values:      make(map[string]Value),
+	}
+
+	// Config series may only refer to those defined before them
+	for _, d := range config.Definitions {
+		if err := e.define(d.Name, d.Expr, SourceConfig); err != nil {
+			return nil, fmt.Errorf("series %s: %w", d.Name, err)
+		}
+	}
+	if redisClient == nil {
+		e.loaded = true
+	} else {
+		// Redis may be down at startup: the config series run meanwhile, and the
+		// stored ones join once it is back
+		redisClient.OnAvailable(e.loadDefinitions)
+	}
+	return e, nil
+}
+
+// loadDefinitions defines the series stored through the API, then runs the
+// OnLoad functions.
+func (e *Engine) loadDefinitions() {
+	stored, err := e.redisClient.GetHash(DefinitionsKey)
+	if err != nil {
+		log.Printf("Could not load stored series definitions, using config series only: %v", err)
+	}
+	e.load(stored)
+
+	e.mu.Lock()
+	e.loaded = true
+	hooks := e.onLoad
+	e.onLoad = nil
+	e.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Loaded reports whether the stored definitions are loaded. Until then an
+// expression may refer to a stored series that isn't defined yet.
+func (e *Engine) Loaded() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.loaded
+}
+
+// OnLoad registers fn to run once the stored definitions are loaded, right
+// away if they are, so expressions that failed to compile for want of a
+// stored series can be compiled again. fn runs without the engine's lock held.
+func (e *Engine) OnLoad(fn func()) {
+	e.mu.Lock()
+	if !e.loaded {
+		e.onLoad = append(e.onLoad, fn)
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+	fn()
+}
+
+// load defines stored series, repeating until no more resolve so they can
+// refer to each other in any order.
+func (e *Engine) load(stored map[string]string) {
+	errs := make(map[string]error)
+	for len(stored) > 0 {
+		progress := false
+		for name, src := range stored {
+			if errs[name] = e.define(name, src, SourceAPI); errs[name] == nil {
+				delete(stored, name)
+				progress = true
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+	for name := range stored {
+		log.Printf("Skipping stored series %s: %v", name, errs[name])
+	}
+}
+
+// Define adds or replaces a named series, persisting it so it survives
+// restarts. When it can't be stored the series is left as it was, and the
+// error wraps ErrNotStored.
+func (e *Engine) Define(name, src string) error {
+	e.mu.Lock()
+	previous := e.series[name]
+	value, valued := e.values[name]
+	e.mu.Unlock()
+
+	if err := e.define(name, src, SourceAPI); err != nil {
+		return err
+	}
+	if e.redisClient == nil {
+		return nil
+	}
+	if err := e.redisClient.SetInHash(DefinitionsKey, name, src); err != nil {
+		e.mu.Lock()
+		if previous == nil {
+			delete(e.series, name)
+		} else {
+			e.series[name] = previous
+			if valued {
+				e.values[name] = value
+			}
+		}
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %v", ErrNotStored, err)
+	}
+	return nil
+}
+
+func (e *Engine) define(name, src, source string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	existing, exists := e.series[name]
+	if exists && existing.source != source {
+		return fmt.Errorf("series %s is defined in %s", name, existing.source)
+	}
+	if !exists && len(e.series) >= MaxSeries {
+		return fmt.Errorf("there are already %d series", MaxSeries)
+	}
+	program, err := e.compile(src)
+	if err != nil {
+		return err
+	}
+	for _, dep := range program.deps {
+		if e.dependsOn(dep, name) {
+			return fmt.Errorf("series %s can't refer to itself, through %s", name, dep)
+		}
+	}
+
+	e.series[name] = &series{name: name, source: source, program: program}
+	delete(e.values, name)
+	return nil
+}
+
+func validName(name string) error {
+	tokens, err := lex(name)
+	if err != nil || len(tokens) != 2 || tokens[0].kind != tokIdent {
+		return fmt.Errorf("series name %q must be a single word of letters, digits and underscores", name)
+	}
+	if _, ok := builtins[name]; ok {
+		return fmt.Errorf("series name %s is a function", name)
+	}
+	return nil
+}
+
+// dependsOn reports whether series name refers to target, directly or not.
+func (e *Engine) dependsOn(name, target string) bool {
+	if name == target {
+		return true
+	}
+	s, ok := e.series[name]
+	if !ok {
+		return false
+	}
+	for _, dep := range s.program.deps {
+		if e.dependsOn(dep, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddUsers registers a lookup of what outside the engine refers to a series,
+// such as alert rules, so Remove refuses to delete a series still in use. The
+// lookup runs without the engine's lock held.
+func (e *Engine) AddUsers(users func(name string) []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.users = append(e.users, users)
+}
+
+// Remove deletes a series defined through the API with its stored values. It
+// fails while other series or registered users refer to it.
+func (e *Engine) Remove(name string) error {
+	e.mu.Lock()
+	lookups := e.users
+	e.mu.Unlock()
+	var users []string
+	for _, lookup := range lookups {
+		users = append(users, lookup(name)...)
+	}
+
+	e.mu.Lock()
+	s, ok := e.series[name]
+	if !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("no series %s", name)
+	}
+	if s.source != SourceAPI {
+		e.mu.Unlock()
+		return fmt.Errorf("series %s is defined in config", name)
+	}
+	for _, other := range e.series {
+		for _, dep := range other.program.deps {
+			if dep == name {
+				users = append(users, other.name)
+			}
+		}
+	}
+	if len(users) > 0 {
+		e.mu.Unlock()
+		sort.Strings(users)
+		return fmt.Errorf("series %s is used by %s", name, strings.Join(users, ", "))
+	}
+	delete(e.series, name)
+	delete(e.values, name)
+	e.mu.Unlock()
+
+	if e.redisClient == nil {
+		return nil
+	}
+	if err := e.redisClient.DeleteFromHash(DefinitionsKey, name); err != nil {
+		return err
+	}
+	return e.redisClient.Del(Key(name))
+}
+
+// Compile parses and type checks an expression. Named series it refers to
+// must already be defined.
+func (e *Engine) Compile(src string) (*Program, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.compile(src)
+}
+
+// Evaluate compiles and evaluates an expression once. Stateful functions
+// have no history then, so sma(x, 5m) is just x.
+func (e *Engine) Evaluate(src string, now time.Time) (float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	program, err := e.compile(src)
+	if err != nil {
+		return 0, err
+	}
+	return program.eval(now)
+}
+
+// Run evaluates every named series on the configured interval.
+// It blocks, call it in a goroutine.
+func (e *Engine) Run() {
+	ticker := time.NewTicker(e.config.Interval.Duration)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		e.update(now)
+	}
+}
+
+func (e *Engine) update(now time.Time) {
+	e.mu.Lock()
+	var values []Value
+	for _, name := range e.order() {
+		v := Value{Name: name, Timestamp: now.UnixMilli()}
+		if result, err := e.series[name].program.eval(now); err != nil {
+			v.Error = err.Error()
+		} else {
+			v.Value, v.Valid = result, true
+		}
+		e.values[name] = v
+		values = append(values, v)
+	}
+	e.mu.Unlock()
+
+	for _, v := range values {
+		if e.bus != nil {
+			e.bus.Publish(bus.TopicSeries, v)
+		}
+		if v.Valid && e.redisClient != nil {
+			if err := e.redisClient.AppendToStream(Key(v.Name), v, e.config.MaxStored, 0); err != nil {
+				log.Printf("Could not store series %s: %v", v.Name, err)
+			}
+		}
+	}
+}
+
+// order returns the series names with every series after those it refers to.
+func (e *Engine) order() []string {
+	var order []string
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dep := range e.series[name].program.deps {
+			visit(dep)
+		}
+		order = append(order, name)
+	}
+	for _, name := range sortedKeys(e.series) {
+		visit(name)
+	}
+	return order
+}
+
+// Get returns a named series with its latest value.
+func (e *Engine) Get(name string) (Series, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.series[name]
+	if !ok {
+		return Series{}, false
+	}
+	return e.describe(s), true
+}
+
+// All returns every named series, sorted by name.
+func (e *Engine) All() []Series {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	all := make([]Series, 0, len(e.series))
+	for _, name := range sortedKeys(e.series) {
+		all = append(all, e.describe(e.series[name]))
+	}
+	return all
+}
+
+func (e *Engine) describe(s *series) Series {
+	result := Series{Name: s.name, Expr: s.program.source, Source: s.source, Deps: s.program.deps}
+	if v, ok := e.values[s.name]; ok {
+		result.Current = &v
+	}
+	return result
+}
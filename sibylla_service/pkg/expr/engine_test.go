@@ -0,0 +1,233 @@
+package expr
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spool"
+)
+
+func newEngine(t *testing.T, redisClient *redisclient.RedisClient) (*Engine, *market.Tracker) {
+	t.Helper()
+	tracker := market.NewTracker()
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 101})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100})
+	e, err := NewEngine(tracker, nil, redisClient, nil, exchangeconfig.SeriesConfig{
+		Definitions: []exchangeconfig.SeriesDefinition{{Name: "basis", Expr: "kraken:BTCUSD.last - binance:BTCUSDT.last"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, tracker
+}
+
+func TestEvaluate(t *testing.T) {
+	e, _ := newEngine(t, nil)
+	now := time.Now()
+
+	tests := map[string]float64{
+		"1 + 2 * 3":   7,
+		"(1 + 2) * 3": 9,
+		"abs(-4) / 2": 2,
+		"max(binance:BTCUSDT.last, kraken:BTCUSD.last)": 101,
+	}
+	for src, want := range tests {
+		if got, err := e.Evaluate(src, now); err != nil || got != want {
+			t.Errorf("Evaluate(%q) = %v, %v, want %v", src, got, err, want)
+		}
+	}
+	for _, src := range []string{"1 / 0", "sqrt(-1)", "binance:ETHUSDT.last", "nosuch", "binance:BTCUSDT.vwap(5m)"} {
+		if _, err := e.Evaluate(src, now); err == nil {
+			t.Errorf("Evaluate(%q) succeeded, want an error", src)
+		}
+	}
+}
+
+func TestSeriesUpdate(t *testing.T) {
+	e, tracker := newEngine(t, nil)
+	if err := e.Define("basis_bps", "basis / binance:BTCUSDT.last * 10000"); err != nil {
+		t.Fatal(err)
+	}
+	e.update(time.Now())
+
+	s, ok := e.Get("basis_bps")
+	if !ok || s.Current == nil || !s.Current.Valid || s.Current.Value != 100 {
+		t.Fatalf("basis_bps = %+v, want 100", s)
+	}
+	if len(s.Deps) != 1 || s.Deps[0] != "basis" || s.Source != SourceAPI {
+		t.Errorf("basis_bps = %+v", s)
+	}
+
+	// A failed evaluation is kept with its reason
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101})
+	if err := e.Define("ratio", "1 / basis"); err != nil {
+		t.Fatal(err)
+	}
+	e.update(time.Now())
+	if s, _ := e.Get("ratio"); s.Current.Valid || !strings.Contains(s.Current.Error, "division by zero") {
+		t.Errorf("ratio = %+v, want a division by zero", s.Current)
+	}
+}
+
+func TestDefineRejects(t *testing.T) {
+	e, _ := newEngine(t, nil)
+	if err := e.Define("a", "basis * 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ name, src string }{
+		{"basis", "1"},      // defined in config
+		{"two words", "1"},  // not a name
+		{"sma", "1"},        // a function
+		{"b", "nosuch + 1"}, // unknown series
+		{"a", "a + 1"},      // refers to itself
+	}
+	for _, tt := range tests {
+		if err := e.Define(tt.name, tt.src); err == nil {
+			t.Errorf("Define(%q, %q) succeeded", tt.name, tt.src)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	e, _ := newEngine(t, redisClient)
+	if err := e.Define("doubled", "basis * 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Define("quadrupled", "doubled * 2"); err != nil {
+		t.Fatal(err)
+	}
+	e.update(time.Now())
+	if stored, _ := redisClient.GetStream(Key("doubled"), 10); len(stored) != 1 {
+		t.Fatalf("got %d stored values, want 1", len(stored))
+	}
+
+	if err := e.Remove("basis"); err == nil {
+		t.Error("removed a config series")
+	}
+	if err := e.Remove("doubled"); err == nil || !strings.Contains(err.Error(), "quadrupled") {
+		t.Errorf("Remove(doubled) = %v, want it used by quadrupled", err)
+	}
+
+	// Users outside the engine, such as alert rules, hold a series too
+	e.AddUsers(func(name string) []string {
+		if name == "quadrupled" {
+			return []string{"alert rule wide"}
+		}
+		return nil
+	})
+	if err := e.Remove("quadrupled"); err == nil || !strings.Contains(err.Error(), "alert rule wide") {
+		t.Errorf("Remove(quadrupled) = %v, want it used by the alert rule", err)
+	}
+
+	e.users = nil
+	if err := e.Remove("quadrupled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Remove("doubled"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Get("doubled"); ok {
+		t.Error("doubled is still defined")
+	}
+	if stored, _ := redisClient.GetStream(Key("doubled"), 10); len(stored) != 0 {
+		t.Errorf("%d values of a removed series are still stored", len(stored))
+	}
+	if definitions, _ := redisClient.GetHash(DefinitionsKey); len(definitions) != 0 {
+		t.Errorf("definitions still stored: %v", definitions)
+	}
+}
+
+func TestStoredDefinitionsLoadInAnyOrder(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	redisClient.SetInHash(DefinitionsKey, "z", "y + 1")
+	redisClient.SetInHash(DefinitionsKey, "y", "basis * 2")
+	redisClient.SetInHash(DefinitionsKey, "broken", "nosuch")
+
+	e, _ := newEngine(t, redisClient)
+	if _, ok := e.Get("z"); !ok {
+		t.Error("z wasn't loaded after y")
+	}
+	if _, ok := e.Get("broken"); ok {
+		t.Error("loaded a series referring to nothing")
+	}
+}
+
+func TestStoredDefinitionsLoadOnceRedisIsUp(t *testing.T) {
+	// Nothing listens on the address until the test starts Redis there
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	redisClient := redisclient.NewRedisClient(addr, "", 0)
+	s, err := spool.Open(filepath.Join(t.TempDir(), "trades.spool"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	redisClient.EnableSpool(s, 10*time.Millisecond)
+
+	e, _ := newEngine(t, redisClient)
+	loaded := make(chan bool, 1)
+	e.OnLoad(func() {
+		_, ok := e.Get("doubled")
+		loaded <- ok
+	})
+	if e.Loaded() {
+		t.Fatal("definitions loaded while Redis is down")
+	}
+
+	m := miniredis.NewMiniRedis()
+	m.HSet(DefinitionsKey, "doubled", "basis * 2")
+	if err := m.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	select {
+	case ok := <-loaded:
+		if !ok {
+			t.Error("OnLoad ran before the stored series was defined")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stored definitions not loaded once Redis came up")
+	}
+	if !e.Loaded() {
+		t.Error("Loaded is false after loading")
+	}
+}
+
+func TestDefineThatCantBeStored(t *testing.T) {
+	m := miniredis.RunT(t)
+	e, _ := newEngine(t, redisclient.NewRedisClient(m.Addr(), "", 0))
+	if err := e.Define("doubled", "basis * 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	if err := e.Define("doubled", "basis * 3"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("redefining with Redis down = %v, want ErrNotStored", err)
+	}
+	if s, _ := e.Get("doubled"); s.Expr != "basis * 2" {
+		t.Errorf("doubled = %q, want it left as it was", s.Expr)
+	}
+	if err := e.Define("tripled", "basis * 3"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("defining with Redis down = %v, want ErrNotStored", err)
+	}
+	if _, ok := e.Get("tripled"); ok {
+		t.Error("a series that couldn't be stored is defined")
+	}
+}
@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokDuration
+	tokIdent
+	tokPunct // one of + - * / ( ) , : .
+)
+
+type token struct {
+	kind tokenKind
+	pos  int // column of the first character, from 1
+	text string
+	num  float64
+	dur  time.Duration
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokNumber:
+		return "number " + t.text
+	case tokDuration:
+		return "window " + t.text
+	case tokIdent:
+		return "name " + t.text
+	}
+	return "'" + t.text + "'"
+}
+
+// durationUnits are the units a window can be written in, longest first.
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+}
+
+// lex splits an expression into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.IndexByte("+-*/(),:.", c) >= 0:
+			tokens = append(tokens, token{kind: tokPunct, pos: i + 1, text: string(c)})
+			i++
+		case isDigit(c):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			number := src[start:i]
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return nil, errorf(start+1, "invalid number %q", number)
+			}
+			unitStart := i
+			for i < len(src) && isLetter(src[i]) {
+				i++
+			}
+			if unitStart == i {
+				tokens = append(tokens, token{kind: tokNumber, pos: start + 1, text: number, num: value})
+				continue
+			}
+			d, ok := parseDuration(value, src[unitStart:i])
+			if !ok {
+				return nil, errorf(unitStart+1, "unknown unit %q in %s, use ms, s, m, h or d", src[unitStart:i], src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokDuration, pos: start + 1, text: src[start:i], dur: d})
+		case isLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i]) || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, pos: start + 1, text: src[start:i]})
+		default:
+			return nil, errorf(i+1, "unexpected character %q", c)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src) + 1}), nil
+}
+
+func parseDuration(value float64, suffix string) (time.Duration, bool) {
+	for _, u := range durationUnits {
+		if suffix == u.suffix {
+			return time.Duration(value * float64(u.unit)), true
+		}
+	}
+	return 0, false
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// Error is an invalid expression, with the column it was found at.
+type Error struct {
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+func errorf(column int, format string, args ...interface{}) error {
+	return &Error{Column: column, Message: fmt.Sprintf(format, args...)}
+}
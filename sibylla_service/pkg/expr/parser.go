@@ -0,0 +1,310 @@
+package expr
+
+import "time"
+
+// Limits that keep expressions cheap to evaluate
+const (
+	MaxLength = 1024 // characters
+	MaxNodes  = 256
+	MaxDepth  = 32 // nesting of parentheses, calls and operators
+)
+
+// node is a parsed expression, before type checking.
+type node interface {
+	column() int
+}
+
+type numberLit struct {
+	pos   int
+	value float64
+}
+
+type durationLit struct {
+	pos   int
+	text  string
+	value time.Duration
+}
+
+// ident is a bare name: a named series, or an exchange or pair in a call.
+type ident struct {
+	pos  int
+	name string
+}
+
+// marketRef is exchange:PAIR.field, optionally with arguments.
+type marketRef struct {
+	pos      int
+	exchange string
+	pair     string
+	field    string
+	fieldPos int
+	args     []node
+	called   bool
+}
+
+type call struct {
+	pos  int
+	name string
+	args []node
+}
+
+type unary struct {
+	pos int
+	op  string
+	x   node
+}
+
+type binary struct {
+	pos  int
+	op   string
+	x, y node
+}
+
+func (n numberLit) column() int   { return n.pos }
+func (n durationLit) column() int { return n.pos }
+func (n ident) column() int       { return n.pos }
+func (n marketRef) column() int   { return n.pos }
+func (n call) column() int        { return n.pos }
+func (n unary) column() int       { return n.pos }
+func (n binary) column() int      { return n.pos }
+
+type parser struct {
+	tokens []token
+	next   int
+	nodes  int
+	depth  int
+}
+
+// parse turns an expression into a syntax tree.
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | window | "(" expr ")" | name [ "(" args ")" ]
+//	        | exchange ":" pair "." field [ "(" args ")" ]
+func parse(src string) (node, error) {
+	if len(src) > MaxLength {
+		return nil, errorf(MaxLength+1, "expression is longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(1, "expression is empty")
+	}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %s", t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == text
+}
+
+func (p *parser) expect(text string) (token, error) {
+	if !p.isPunct(text) {
+		t := p.peek()
+		return t, errorf(t.pos, "expected '%s' but found %s", text, t)
+	}
+	return p.advance(), nil
+}
+
+// count enforces the node limit.
+func (p *parser) count(pos int) error {
+	p.nodes++
+	if p.nodes > MaxNodes {
+		return errorf(pos, "expression has more than %d terms", MaxNodes)
+	}
+	return nil
+}
+
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return errorf(pos, "expression is nested more than %d deep", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) expr() (node, error) {
+	if err := p.enter(p.peek().pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	x, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.advance()
+		y, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.count(op.pos); err != nil {
+			return nil, err
+		}
+		x = binary{pos: op.pos, op: op.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) term() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("*") || p.isPunct("/") {
+		op := p.advance()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.count(op.pos); err != nil {
+			return nil, err
+		}
+		x = binary{pos: op.pos, op: op.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if !p.isPunct("-") {
+		return p.primary()
+	}
+	op := p.advance()
+	if err := p.enter(op.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.count(op.pos); err != nil {
+		return nil, err
+	}
+	return unary{pos: op.pos, op: op.text, x: x}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.advance()
+	if err := p.count(t.pos); err != nil {
+		return nil, err
+	}
+
+	switch t.kind {
+	case tokNumber:
+		return numberLit{pos: t.pos, value: t.num}, nil
+	case tokDuration:
+		return durationLit{pos: t.pos, text: t.text, value: t.dur}, nil
+	case tokIdent:
+		switch {
+		case p.isPunct(":"):
+			return p.market(t)
+		case p.isPunct("("):
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			return call{pos: t.pos, name: t.text, args: args}, nil
+		}
+		return ident{pos: t.pos, name: t.text}, nil
+	case tokPunct:
+		if t.text == "(" {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	case tokEOF:
+		return nil, errorf(t.pos, "expression ends early")
+	}
+	return nil, errorf(t.pos, "unexpected %s", t)
+}
+
+// market parses the rest of exchange:PAIR.field(args).
+func (p *parser) market(exchange token) (node, error) {
+	p.advance() // ":"
+	pair := p.advance()
+	if pair.kind != tokIdent {
+		return nil, errorf(pair.pos, "expected a pair after %s: but found %s", exchange.text, pair)
+	}
+	if _, err := p.expect("."); err != nil {
+		return nil, errorf(p.peek().pos, "expected .field after %s:%s, e.g. %s:%s.last", exchange.text, pair.text, exchange.text, pair.text)
+	}
+	field := p.advance()
+	if field.kind != tokIdent {
+		return nil, errorf(field.pos, "expected a field after %s:%s. but found %s", exchange.text, pair.text, field)
+	}
+
+	ref := marketRef{pos: exchange.pos, exchange: exchange.text, pair: pair.text, field: field.text, fieldPos: field.pos}
+	if p.isPunct("(") {
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		ref.args, ref.called = args, true
+	}
+	return ref, nil
+}
+
+// args parses a parenthesised, comma separated argument list.
+func (p *parser) args() ([]node, error) {
+	open := p.advance() // "("
+	if err := p.enter(open.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	var args []node
+	if p.isPunct(")") {
+		p.advance()
+		return args, nil
+	}
+	for {
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.isPunct(",") {
+			p.advance()
+			continue
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
@@ -0,0 +1,292 @@
+package filter
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/metrics"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// StoreKey is the stream holding every quarantined trade; each exchange also has its own, see Key.
+const StoreKey = "quarantine:trades"
+
+// Key is the store key holding the quarantined trades of one exchange.
+func Key(exchange string) string {
+	return "quarantine:trades:" + exchange
+}
+
+// Reasons a trade is rejected
+const (
+	ReasonInvalid   = "invalid"   // missing, non-positive or non-finite fields
+	ReasonJump      = "jump"      // too far from the instrument's recent median
+	ReasonComposite = "composite" // too far from the other venues
+)
+
+// Rejected is a quarantined trade kept for review. Non-finite values are
+// stored as zero and described in Detail.
+type Rejected struct {
+	Trade        models.Trade `json:"trade"`
+	Reason       string       `json:"reason"`
+	Detail       string       `json:"detail,omitempty"`
+	Reference    float64      `json:"reference,omitempty"` // the median the trade was checked against
+	DeviationBps float64      `json:"deviation_bps,omitempty"`
+	Timestamp    int64        `json:"timestamp"` // when it was rejected, Unix ms
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (r Rejected) MarshalBinary() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+type instrument struct {
+	exchange string
+	recent   []float64 // ring of recent prices, accepted or not
+	next     int
+	seen     int
+	seenAt   time.Time // last trade, accepted or not
+}
+
+// median of the recent prices. Quarantined prices count too, so a real move
+// that lasts shifts the median and stops being quarantined.
+func (inst *instrument) median() float64 {
+	n := inst.seen
+	if n > len(inst.recent) {
+		n = len(inst.recent)
+	}
+	return median(append([]float64(nil), inst.recent[:n]...))
+}
+
+func (inst *instrument) observe(price float64) {
+	inst.recent[inst.next] = price
+	inst.next = (inst.next + 1) % len(inst.recent)
+	inst.seen++
+}
+
+// Filter validates trades between the adapters and storage. It rejects
+// malformed trades outright and quarantines prices that jump too far from the
+// instrument's recent median or from the other venues quoting its market, in
+// the same pair or one pegged to it (see models.CanonicalPair). Pegged quotes
+// are compared at par, MaxCompositeBps is wide enough to absorb peg drift.
+type Filter struct {
+	mu          sync.Mutex
+	config      exchangeconfig.FilterConfig
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	instruments map[string]map[string]*instrument // canonical pair -> exchange:pair -> instrument
+	rejections  map[string]map[string]int64       // exchange -> reason -> count
+	accepted    map[string]int64                  // by exchange
+}
+
+func NewFilter(redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.FilterConfig) *Filter {
+	return &Filter{
+		config:      config.WithDefaults(),
+		redisClient: redisClient,
+		bus:         b,
+		instruments: make(map[string]map[string]*instrument),
+		rejections:  make(map[string]map[string]int64),
+		accepted:    make(map[string]int64),
+	}
+}
+
+// Admit reports whether a trade may be published and stored. Rejected trades
+// are counted, published and kept in the quarantine store.
+func (f *Filter) Admit(t models.Trade) bool {
+	now := time.Now()
+
+	if detail := invalid(t); detail != "" {
+		f.reject(Rejected{Trade: sanitize(t), Reason: ReasonInvalid, Detail: detail, Timestamp: now.UnixMilli()})
+		return false
+	}
+
+	canonical := models.CanonicalPair(t.Pair)
+
+	f.mu.Lock()
+	venues, ok := f.instruments[canonical]
+	if !ok {
+		venues = make(map[string]*instrument)
+		f.instruments[canonical] = venues
+	}
+	inst, ok := venues[t.Exchange+":"+t.Pair]
+	if !ok {
+		inst = &instrument{exchange: t.Exchange, recent: make([]float64, f.config.MedianTrades)}
+		venues[t.Exchange+":"+t.Pair] = inst
+	}
+
+	var rejected *Rejected
+	if inst.seen >= f.config.MinTrades {
+		reference := inst.median()
+		if deviation := bps(t.Price, reference); math.Abs(deviation) > f.config.MaxJumpBps {
+			rejected = &Rejected{Reason: ReasonJump, Reference: reference, DeviationBps: deviation}
+		}
+	}
+	if rejected == nil {
+		if reference, ok := f.composite(t.Exchange, canonical, now); ok {
+			if deviation := bps(t.Price, reference); math.Abs(deviation) > f.config.MaxCompositeBps {
+				rejected = &Rejected{Reason: ReasonComposite, Reference: reference, DeviationBps: deviation}
+			}
+		}
+	}
+
+	inst.observe(t.Price)
+	inst.seenAt = now
+	if rejected == nil {
+		f.accepted[t.Exchange]++
+	}
+	f.mu.Unlock()
+
+	if rejected != nil {
+		rejected.Trade = t
+		rejected.Timestamp = now.UnixMilli()
+		f.reject(*rejected)
+		return false
+	}
+	return true
+}
+
+// composite is the median of the recent medians of the market on every other
+// fresh venue. Medians rather than last prices keep one venue's bad tick out
+// of another's check, and a move every venue makes is confirmed by them all.
+func (f *Filter) composite(exchange, canonical string, now time.Time) (float64, bool) {
+	var prices []float64
+	for _, inst := range f.instruments[canonical] {
+		if inst.exchange == exchange || inst.seen < f.config.MinTrades || now.Sub(inst.seenAt) > f.config.MaxStaleness.Duration {
+			continue
+		}
+		prices = append(prices, inst.median())
+	}
+	if len(prices) == 0 {
+		return 0, false
+	}
+	return median(prices), true
+}
+
+func (f *Filter) reject(r Rejected) {
+	f.mu.Lock()
+	if f.rejections[r.Trade.Exchange] == nil {
+		f.rejections[r.Trade.Exchange] = make(map[string]int64)
+	}
+	f.rejections[r.Trade.Exchange][r.Reason]++
+	count := f.rejections[r.Trade.Exchange][r.Reason]
+	f.mu.Unlock()
+
+	// Log on powers of two so a broken feed doesn't flood the logs
+	if count&(count-1) == 0 {
+		log.Printf("Rejected %d %s trades from %s, latest %s %v (%s)", count, r.Reason, r.Trade.Exchange, r.Trade.Pair, r.Trade.Price, r.Detail)
+	}
+
+	if f.bus != nil {
+		f.bus.Publish(bus.TopicQuarantine, r)
+	}
+	if f.redisClient == nil {
+		return
+	}
+	keys := []string{StoreKey}
+	if r.Trade.Exchange != "" {
+		keys = append(keys, Key(r.Trade.Exchange))
+	}
+	for _, key := range keys {
+		if err := f.redisClient.AppendToStream(key, r, f.config.MaxStored, 0); err != nil {
+			log.Printf("Could not store quarantined trade in %s: %v", key, err)
+		}
+	}
+}
+
+// Rejections returns the number of rejected trades by exchange and reason.
+func (f *Filter) Rejections() map[string]map[string]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := make(map[string]map[string]int64, len(f.rejections))
+	for exchange, reasons := range f.rejections {
+		counts[exchange] = make(map[string]int64, len(reasons))
+		for reason, n := range reasons {
+			counts[exchange][reason] = n
+		}
+	}
+	return counts
+}
+
+// Metrics exposes accepted and rejected trade counts per venue for scraping.
+func (f *Filter) Metrics() []metrics.Family {
+	accepted := metrics.Family{Name: "sibylla_filter_accepted_total", Help: "Trades that passed the bad-tick filter.", Type: metrics.Counter}
+	rejected := metrics.Family{Name: "sibylla_filter_rejected_total", Help: "Trades rejected by the bad-tick filter.", Type: metrics.Counter}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, exchange := range sortedKeys(f.accepted) {
+		accepted.Samples = append(accepted.Samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "exchange", Value: exchange}},
+			Value:  float64(f.accepted[exchange]),
+		})
+	}
+	for _, exchange := range sortedKeys(f.rejections) {
+		for _, reason := range sortedKeys(f.rejections[exchange]) {
+			rejected.Samples = append(rejected.Samples, metrics.Sample{
+				Labels: []metrics.Label{{Name: "exchange", Value: exchange}, {Name: "reason", Value: reason}},
+				Value:  float64(f.rejections[exchange][reason]),
+			})
+		}
+	}
+	return []metrics.Family{accepted, rejected}
+}
+
+// invalid describes what is wrong with a malformed trade, or returns "".
+func invalid(t models.Trade) string {
+	switch {
+	case t.Exchange == "" || t.Pair == "":
+		return "missing exchange or pair"
+	case math.IsNaN(t.Price) || math.IsInf(t.Price, 0):
+		return "price not finite"
+	case t.Price <= 0:
+		return "price not positive"
+	case math.IsNaN(t.Quantity) || math.IsInf(t.Quantity, 0):
+		return "quantity not finite"
+	case t.Quantity <= 0:
+		return "quantity not positive"
+	case t.Timestamp <= 0:
+		return "timestamp missing"
+	}
+	return ""
+}
+
+// sanitize zeroes non-finite values so the trade can be stored as JSON.
+func sanitize(t models.Trade) models.Trade {
+	if math.IsNaN(t.Price) || math.IsInf(t.Price, 0) {
+		t.Price = 0
+	}
+	if math.IsNaN(t.Quantity) || math.IsInf(t.Quantity, 0) {
+		t.Quantity = 0
+	}
+	return t
+}
+
+func bps(price, reference float64) float64 {
+	return (price - reference) / reference * 10000
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+func trade(exchange string, price float64) models.Trade {
+	return models.Trade{Exchange: exchange, Pair: "BTCUSDT", Price: price, Quantity: 1, Timestamp: 1000}
+}
+
+func TestInvalidTrades(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	f := NewFilter(redisClient, nil, exchangeconfig.FilterConfig{})
+
+	tests := []struct {
+		trade  models.Trade
+		detail string
+	}{
+		{models.Trade{Exchange: "binance", Price: 1, Quantity: 1, Timestamp: 1}, "missing exchange or pair"},
+		{models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: math.NaN(), Quantity: 1, Timestamp: 1}, "price not finite"},
+		{models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: -1, Quantity: 1, Timestamp: 1}, "price not positive"},
+		{models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 1, Quantity: math.Inf(1), Timestamp: 1}, "quantity not finite"},
+		{models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 1, Quantity: 0, Timestamp: 1}, "quantity not positive"},
+		{models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 1, Quantity: 1}, "timestamp missing"},
+	}
+	for _, tt := range tests {
+		if detail := invalid(tt.trade); detail != tt.detail {
+			t.Errorf("invalid(%+v) = %q, want %q", tt.trade, detail, tt.detail)
+		}
+		if f.Admit(tt.trade) {
+			t.Errorf("admitted %+v", tt.trade)
+		}
+	}
+
+	if n := f.Rejections()["binance"][ReasonInvalid]; n != int64(len(tests)) {
+		t.Errorf("got %d invalid rejections, want %d", n, len(tests))
+	}
+	// Non-finite values are stored as zero so they marshal
+	stored, err := redisClient.GetStream(StoreKey, 10)
+	if err != nil || len(stored) != len(tests) {
+		t.Errorf("got %d quarantined trades (%v), want %d", len(stored), err, len(tests))
+	}
+}
+
+func TestJumpQuarantine(t *testing.T) {
+	f := NewFilter(nil, nil, exchangeconfig.FilterConfig{MedianTrades: 5, MinTrades: 3})
+	for i := 0; i < 3; i++ {
+		if !f.Admit(trade("binance", 100)) {
+			t.Fatal("rejected a steady price")
+		}
+	}
+	// Jumps are checked once MinTrades have been seen
+	if f.Admit(trade("binance", 110)) {
+		t.Error("admitted a 1000 bps jump")
+	}
+	if !f.Admit(trade("binance", 104)) {
+		t.Error("rejected a 400 bps move")
+	}
+
+	// A move that lasts shifts the median and is admitted again
+	admitted := false
+	for i := 0; i < 5 && !admitted; i++ {
+		admitted = f.Admit(trade("binance", 110))
+	}
+	if !admitted {
+		t.Error("a lasting move is still quarantined")
+	}
+	if n := f.Rejections()["binance"][ReasonJump]; n < 2 {
+		t.Errorf("got %d jump rejections, want at least 2", n)
+	}
+}
+
+func TestCompositeQuarantine(t *testing.T) {
+	f := NewFilter(nil, nil, exchangeconfig.FilterConfig{MinTrades: 3, MaxCompositeBps: 500})
+	for i := 0; i < 3; i++ {
+		f.Admit(trade("kraken", 100))
+	}
+
+	// binance has no history of its own, the other venues still check it
+	if f.Admit(trade("binance", 108)) {
+		t.Error("admitted a trade 800 bps from the other venues")
+	}
+	if !f.Admit(trade("binance", 101)) {
+		t.Error("rejected a trade in line with the other venues")
+	}
+	if n := f.Rejections()["binance"][ReasonComposite]; n != 1 {
+		t.Errorf("got %d composite rejections, want 1", n)
+	}
+}
+
+func TestCompositeAcrossPeggedQuotes(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	f := NewFilter(redisClient, nil, exchangeconfig.FilterConfig{MinTrades: 3, MaxCompositeBps: 500})
+	for i := 0; i < 3; i++ {
+		f.Admit(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 100, Quantity: 1, Timestamp: 1000})
+	}
+
+	// kraken quotes the market in USD, it still checks binance's USDT trades
+	if f.Admit(trade("binance", 108)) {
+		t.Error("admitted a trade 800 bps from the venues quoting the market in USD")
+	}
+	if !f.Admit(trade("binance", 101)) {
+		t.Error("rejected a trade in line with the other venues")
+	}
+
+	for key, want := range map[string]int{StoreKey: 1, Key("binance"): 1, Key("kraken"): 0} {
+		if stored, err := redisClient.GetStream(key, 10); err != nil || len(stored) != want {
+			t.Errorf("got %d quarantined trades in %s (%v), want %d", len(stored), key, err, want)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if m := median([]float64{3, 1, 2}); m != 2 {
+		t.Errorf("median = %v, want 2", m)
+	}
+	if m := median([]float64{4, 1, 3, 2}); m != 2.5 {
+		t.Errorf("median = %v, want 2.5", m)
+	}
+}
@@ -0,0 +1,254 @@
+package flow
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/metrics"
+	"sibylla_service/pkg/models"
+)
+
+// Divergences between price and volume delta
+const (
+	DivergenceBullish = "bullish" // price fell while aggressive buying outweighed selling
+	DivergenceBearish = "bearish" // price rose while aggressive selling outweighed buying
+)
+
+// Flow is the aggressor-side order flow of a pair over one window, on one
+// exchange or across every exchange when Exchange is empty. Imbalances run
+// from -1 (all selling) to 1 (all buying).
+type Flow struct {
+	Exchange        string  `json:"exchange,omitempty"`
+	Pair            string  `json:"pair"`
+	Window          string  `json:"window"`
+	BuyVolume       float64 `json:"buy_volume"`
+	SellVolume      float64 `json:"sell_volume"`
+	Delta           float64 `json:"delta"` // BuyVolume - SellVolume
+	VolumeImbalance float64 `json:"volume_imbalance"`
+	BuyTrades       int     `json:"buy_trades"`
+	SellTrades      int     `json:"sell_trades"`
+	TradeImbalance  float64 `json:"trade_imbalance"`
+	// CVD is the cumulative volume delta since the service started
+	CVD            float64 `json:"cvd"`
+	PriceChangeBps float64 `json:"price_change_bps"`
+	Divergence     string  `json:"divergence,omitempty"`
+	Timestamp      int64   `json:"timestamp"`
+}
+
+type venue struct {
+	windows []*window // in the order of Tracker.windows
+	cvd     float64
+}
+
+// Tracker measures buy and sell aggressor flow for every exchange and pair
+// over a set of rolling windows.
+type Tracker struct {
+	mu      sync.Mutex
+	config  exchangeconfig.FlowConfig
+	windows []time.Duration
+	pairs   map[string]map[string]*venue // pair -> exchange -> venue
+}
+
+func NewTracker(config exchangeconfig.FlowConfig) *Tracker {
+	config = config.WithDefaults()
+	var windows []time.Duration
+	for _, w := range config.Windows {
+		if w.Duration > 0 {
+			windows = append(windows, w.Duration)
+		}
+	}
+	return &Tracker{
+		config:  config,
+		windows: windows,
+		pairs:   make(map[string]map[string]*venue),
+	}
+}
+
+// OnTrade adds a trade to its exchange and pair. A trade whose buyer was the
+// maker was initiated by the seller.
+func (t *Tracker) OnTrade(tr models.Trade) {
+	if tr.Price <= 0 || tr.Quantity <= 0 {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	venues, ok := t.pairs[tr.Pair]
+	if !ok {
+		venues = make(map[string]*venue)
+		t.pairs[tr.Pair] = venues
+	}
+	v, ok := venues[tr.Exchange]
+	if !ok {
+		v = &venue{}
+		for _, w := range t.windows {
+			v.windows = append(v.windows, newWindow(w))
+		}
+		venues[tr.Exchange] = v
+	}
+
+	buy := !tr.IsBuyerMaker
+	if buy {
+		v.cvd += tr.Quantity
+	} else {
+		v.cvd -= tr.Quantity
+	}
+	for _, w := range v.windows {
+		w.add(now, tr.Price, tr.Quantity, buy)
+	}
+}
+
+// Get returns the flow of a pair over a window on an exchange, or across
+// exchanges when exchange is empty.
+func (t *Tracker) Get(exchange, pair string, window time.Duration) (Flow, bool) {
+	for i, w := range t.windows {
+		if w == window {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return t.flow(exchange, pair, i, time.Now())
+		}
+	}
+	return Flow{}, false
+}
+
+// All returns the flow of a pair over every window on an exchange, or across
+// exchanges when exchange is empty.
+func (t *Tracker) All(exchange, pair string) []Flow {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var flows []Flow
+	for i := range t.windows {
+		if f, ok := t.flow(exchange, pair, i, now); ok {
+			flows = append(flows, f)
+		}
+	}
+	return flows
+}
+
+// Exchanges returns the exchanges a pair has traded on, sorted.
+func (t *Tracker) Exchanges(pair string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.exchanges(pair)
+}
+
+func (t *Tracker) exchanges(pair string) []string {
+	exchanges := make([]string, 0, len(t.pairs[pair]))
+	for exchange := range t.pairs[pair] {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
+
+// Pairs returns every pair that has traded, sorted.
+func (t *Tracker) Pairs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pairNames()
+}
+
+func (t *Tracker) pairNames() []string {
+	pairs := make([]string, 0, len(t.pairs))
+	for pair := range t.pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (t *Tracker) flow(exchange, pair string, i int, now time.Time) (Flow, bool) {
+	var venues []*venue
+	if exchange != "" {
+		if v, ok := t.pairs[pair][exchange]; ok {
+			venues = append(venues, v)
+		}
+	} else {
+		for _, v := range t.pairs[pair] {
+			venues = append(venues, v)
+		}
+	}
+	if len(venues) == 0 {
+		return Flow{}, false
+	}
+
+	f := Flow{Exchange: exchange, Pair: pair, Window: average.WindowName(t.windows[i]), Timestamp: now.UnixMilli()}
+	var changes float64
+	var priced int
+	for _, v := range venues {
+		s := v.windows[i].sums(now)
+		f.BuyVolume += s.buyVolume
+		f.SellVolume += s.sellVolume
+		f.BuyTrades += s.buyTrades
+		f.SellTrades += s.sellTrades
+		f.CVD += v.cvd
+		if s.open > 0 {
+			changes += (s.close - s.open) / s.open * 10000
+			priced++
+		}
+	}
+
+	f.Delta = f.BuyVolume - f.SellVolume
+	if total := f.BuyVolume + f.SellVolume; total > 0 {
+		f.VolumeImbalance = f.Delta / total
+	}
+	if total := f.BuyTrades + f.SellTrades; total > 0 {
+		f.TradeImbalance = float64(f.BuyTrades-f.SellTrades) / float64(total)
+	}
+	if priced > 0 {
+		f.PriceChangeBps = changes / float64(priced)
+	}
+	switch {
+	case f.PriceChangeBps <= -t.config.DivergenceBps && f.Delta > 0:
+		f.Divergence = DivergenceBullish
+	case f.PriceChangeBps >= t.config.DivergenceBps && f.Delta < 0:
+		f.Divergence = DivergenceBearish
+	}
+	return f, true
+}
+
+// Metrics exposes the flow of every pair, per exchange and in aggregate
+// (exchange "all"), for scraping.
+func (t *Tracker) Metrics() []metrics.Family {
+	now := time.Now()
+	buyVolume := metrics.Family{Name: "sibylla_flow_buy_volume", Help: "Aggressive buy volume over the window.", Type: metrics.Gauge}
+	sellVolume := metrics.Family{Name: "sibylla_flow_sell_volume", Help: "Aggressive sell volume over the window.", Type: metrics.Gauge}
+	volumeImbalance := metrics.Family{Name: "sibylla_flow_volume_imbalance", Help: "Buy minus sell volume over total volume in the window.", Type: metrics.Gauge}
+	tradeImbalance := metrics.Family{Name: "sibylla_flow_trade_imbalance", Help: "Buy minus sell trade count over total trades in the window.", Type: metrics.Gauge}
+	cvd := metrics.Family{Name: "sibylla_flow_cvd", Help: "Cumulative volume delta since start.", Type: metrics.Gauge}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, pair := range t.pairNames() {
+		for _, exchange := range append([]string{""}, t.exchanges(pair)...) {
+			label := exchange
+			if label == "" {
+				label = "all"
+			}
+			for i := range t.windows {
+				f, ok := t.flow(exchange, pair, i, now)
+				if !ok {
+					continue
+				}
+				labels := []metrics.Label{{Name: "exchange", Value: label}, {Name: "pair", Value: pair}, {Name: "window", Value: f.Window}}
+				buyVolume.Samples = append(buyVolume.Samples, metrics.Sample{Labels: labels, Value: f.BuyVolume})
+				sellVolume.Samples = append(sellVolume.Samples, metrics.Sample{Labels: labels, Value: f.SellVolume})
+				volumeImbalance.Samples = append(volumeImbalance.Samples, metrics.Sample{Labels: labels, Value: f.VolumeImbalance})
+				tradeImbalance.Samples = append(tradeImbalance.Samples, metrics.Sample{Labels: labels, Value: f.TradeImbalance})
+				if i == 0 {
+					cvd.Samples = append(cvd.Samples, metrics.Sample{Labels: labels[:2], Value: f.CVD})
+				}
+			}
+		}
+	}
+	return []metrics.Family{buyVolume, sellVolume, volumeImbalance, tradeImbalance, cvd}
+}
@@ -0,0 +1,58 @@
+package flow
+
+import (
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+)
+
+func TestWindowSums(t *testing.T) {
+	w := newWindow(time.Minute)
+	t0 := time.Unix(1000, 0)
+	w.add(t0, 100, 1, true)
+	w.add(t0.Add(10*time.Second), 101, 2, false)
+	w.add(t0.Add(20*time.Second), 102, 3, true)
+
+	s := w.sums(t0.Add(30 * time.Second))
+	if s.buyVolume != 4 || s.sellVolume != 2 || s.buyTrades != 2 || s.sellTrades != 1 {
+		t.Errorf("sums = %+v", s)
+	}
+	if s.open != 100 || s.close != 102 {
+		t.Errorf("open %v close %v, want 100 and 102", s.open, s.close)
+	}
+	if s := w.sums(t0.Add(2 * time.Minute)); s.open != 0 || s.buyVolume != 0 {
+		t.Errorf("expired sums = %+v", s)
+	}
+}
+
+func TestTrackerFlow(t *testing.T) {
+	tracker := NewTracker(exchangeconfig.FlowConfig{})
+	// Price falls 100 bps while aggressive buying outweighs selling
+	tracker.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 3})
+	tracker.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 99, Quantity: 1, IsBuyerMaker: true})
+	tracker.OnTrade(models.Trade{Exchange: "kraken", Pair: "BTCUSDT", Price: 100, Quantity: 1, IsBuyerMaker: true})
+
+	f, ok := tracker.Get("binance", "BTCUSDT", time.Minute)
+	if !ok {
+		t.Fatal("no flow over 1m")
+	}
+	if f.Delta != 2 || f.VolumeImbalance != 0.5 || f.TradeImbalance != 0 || f.CVD != 2 {
+		t.Errorf("flow = %+v", f)
+	}
+	if f.PriceChangeBps != -100 || f.Divergence != DivergenceBullish {
+		t.Errorf("flow = %+v, want a bullish divergence on -100 bps", f)
+	}
+
+	aggregate, _ := tracker.Get("", "BTCUSDT", time.Minute)
+	if aggregate.Delta != 1 || aggregate.BuyTrades != 1 || aggregate.SellTrades != 2 || aggregate.PriceChangeBps != -50 {
+		t.Errorf("aggregate = %+v", aggregate)
+	}
+	if _, ok := tracker.Get("", "BTCUSDT", 2*time.Minute); ok {
+		t.Error("got flow over a window that isn't tracked")
+	}
+	if all := tracker.All("", "BTCUSDT"); len(all) != len(exchangeconfig.DefaultFlowWindows) {
+		t.Errorf("got %d windows from All", len(all))
+	}
+}
@@ -0,0 +1,57 @@
+package flow
+
+import (
+	"time"
+
+	"sibylla_service/pkg/market"
+)
+
+type bucket struct {
+	buyVolume  float64
+	sellVolume float64
+	buyTrades  int
+	sellTrades int
+	open       float64 // first and last trade price in the bucket
+	close      float64
+}
+
+// window is the flow of one venue over one rolling window.
+type window struct {
+	buckets *market.Buckets[bucket]
+}
+
+func newWindow(length time.Duration) *window {
+	return &window{buckets: market.NewBuckets[bucket](length)}
+}
+
+func (w *window) add(at time.Time, price, quantity float64, buy bool) {
+	b := w.buckets.At(at)
+	if b.open == 0 {
+		b.open = price
+	}
+	b.close = price
+	if buy {
+		b.buyVolume += quantity
+		b.buyTrades++
+	} else {
+		b.sellVolume += quantity
+		b.sellTrades++
+	}
+}
+
+// sums adds up the buckets inside the window ending at now. open and close are
+// the first and last prices traded in it, zero when nothing traded.
+func (w *window) sums(now time.Time) bucket {
+	var s bucket
+	w.buckets.Each(now, func(b *bucket) {
+		if s.open == 0 {
+			s.open = b.open
+		}
+		s.close = b.close
+		s.buyVolume += b.buyVolume
+		s.sellVolume += b.sellVolume
+		s.buyTrades += b.buyTrades
+		s.sellTrades += b.sellTrades
+	})
+	return s
+}
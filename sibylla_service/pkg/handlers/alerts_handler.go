@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/alert"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/redisclient"
+)
+
+// AlertsHandler manages alert rules. GET returns every rule with its state and
+// the most recent notifications (limit, default 100, newest first), POST adds
+// a rule from the JSON body and DELETE removes the rule given by id. Rules
+// defined in config can't be removed.
+func AlertsHandler(redisClient *redisclient.RedisClient, engine *alert.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			limit := int64(100)
+			if l := r.URL.Query().Get("limit"); l != "" {
+				var err error
+				limit, err = strconv.ParseInt(l, 10, 64)
+				if err != nil || limit <= 0 {
+					http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+					return
+				}
+			}
+
+			stored, err := redisClient.GetStream(alert.StoreKey, limit)
+			if err != nil {
+				http.Error(w, "Failed to retrieve alerts", http.StatusInternalServerError)
+				return
+			}
+
+			alerts := make([]alert.Alert, 0, len(stored))
+			for _, s := range stored {
+				var a alert.Alert
+				if err := json.Unmarshal([]byte(s), &a); err != nil {
+					log.Printf("Failed to unmarshal alert: %v", err)
+					continue
+				}
+				alerts = append(alerts, a)
+			}
+
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"rules":  engine.Rules(),
+				"alerts": alerts,
+			})
+		case http.MethodPost:
+			var rule exchangeconfig.AlertRule
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				http.Error(w, "Invalid alert rule: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := engine.AddRule(rule); err != nil {
+				if errors.Is(err, alert.ErrNotStored) {
+					http.Error(w, "Failed to store alert rule", http.StatusInternalServerError)
+					return
+				}
+				writeExprError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, rule)
+		case http.MethodDelete:
+			id := r.URL.Query().Get("id")
+			if id == "" {
+				http.Error(w, "id is required", http.StatusBadRequest)
+				return
+			}
+			if err := engine.RemoveRule(id); err != nil {
+				switch {
+				case errors.Is(err, alert.ErrNotStored):
+					http.Error(w, "Failed to remove alert rule", http.StatusInternalServerError)
+				case errors.Is(err, alert.ErrConfigRule):
+					http.Error(w, err.Error(), http.StatusConflict)
+				default:
+					http.Error(w, err.Error(), http.StatusNotFound)
+				}
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/arbitrage"
+	"sibylla_service/pkg/redisclient"
+)
+
+// ArbitrageHandler serves open arbitrage opportunities and the most recently
+// closed ones from the store (limit, default 100, newest first).
+func ArbitrageHandler(redisClient *redisclient.RedisClient, detector *arbitrage.Detector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := int64(100)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stored, err := redisClient.GetStream(arbitrage.StoreKey, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve opportunities", http.StatusInternalServerError)
+			return
+		}
+
+		closed := make([]arbitrage.Opportunity, 0, len(stored))
+		for _, s := range stored {
+			var o arbitrage.Opportunity
+			if err := json.Unmarshal([]byte(s), &o); err != nil {
+				log.Printf("Failed to unmarshal opportunity: %v", err)
+				continue
+			}
+			closed = append(closed, o)
+		}
+
+		responseJSON, err := json.Marshal(map[string]interface{}{
+			"open":   detector.Open(),
+			"closed": closed,
+		})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"sibylla_service/pkg/average"
+)
+
+// AveragesHandler serves rolling VWAP and TWAP. With a pair it returns the
+// aggregate across exchanges and each exchange's own, optionally narrowed to one
+// exchange and one window; without a pair it returns every pair's aggregate.
+func AveragesHandler(calculator *average.Calculator) http.HandlerFunc {
+	return windowedHandler[average.Average](calculator, "averages")
+}
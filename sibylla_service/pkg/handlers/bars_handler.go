@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/bars"
+	"sibylla_service/pkg/redisclient"
+)
+
+// BarsHandler serves closed tick, volume or dollar bars from the store plus the in-progress bar.
+// Query params: exchange, pair, type (default tick), limit (default 100).
+func BarsHandler(redisClient *redisclient.RedisClient, builder *bars.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		exchange := query.Get("exchange")
+		pair := query.Get("pair")
+		if exchange == "" || pair == "" {
+			http.Error(w, "exchange and pair are required", http.StatusBadRequest)
+			return
+		}
+
+		typeName := query.Get("type")
+		if typeName == "" {
+			typeName = bars.TypeTick
+		}
+		barType, err := bars.ParseType(typeName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := int64(100)
+		if l := query.Get("limit"); l != "" {
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stored, err := redisClient.GetSortedSet(bars.Key(exchange, pair, barType), limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve bars", http.StatusInternalServerError)
+			return
+		}
+
+		// Stored newest first, serve oldest first for charting
+		closed := make([]bars.Bar, 0, len(stored))
+		for i := len(stored) - 1; i >= 0; i-- {
+			var b bars.Bar
+			if err := json.Unmarshal([]byte(stored[i]), &b); err != nil {
+				log.Printf("Failed to unmarshal bar for %s %s: %v", exchange, pair, err)
+				continue
+			}
+			closed = append(closed, b)
+		}
+
+		response := map[string]interface{}{
+			"bars": closed,
+		}
+		if current, ok := builder.Current(exchange, pair, barType); ok {
+			response["current"] = current
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
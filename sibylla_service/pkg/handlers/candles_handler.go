@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/candles"
+	"sibylla_service/pkg/redisclient"
+)
+
+// CandlesHandler serves closed candles from the store plus the in-progress candle.
+// Query params: exchange, pair, interval (default 1m), limit (default 100).
+func CandlesHandler(redisClient *redisclient.RedisClient, builder *candles.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		exchange := query.Get("exchange")
+		pair := query.Get("pair")
+		if exchange == "" || pair == "" {
+			http.Error(w, "exchange and pair are required", http.StatusBadRequest)
+			return
+		}
+
+		intervalName := query.Get("interval")
+		if intervalName == "" {
+			intervalName = "1m"
+		}
+		interval, err := candles.ParseInterval(intervalName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := int64(100)
+		if l := query.Get("limit"); l != "" {
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stored, err := redisClient.GetSortedSet(candles.Key(exchange, pair, interval.Name), limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve candles", http.StatusInternalServerError)
+			return
+		}
+
+		// Stored newest first, serve oldest first for charting
+		closed := make([]candles.Candle, 0, len(stored))
+		for i := len(stored) - 1; i >= 0; i-- {
+			var c candles.Candle
+			if err := json.Unmarshal([]byte(stored[i]), &c); err != nil {
+				log.Printf("Failed to unmarshal candle for %s %s: %v", exchange, pair, err)
+				continue
+			}
+			closed = append(closed, c)
+		}
+
+		response := map[string]interface{}{
+			"candles": closed,
+		}
+		if current, ok := builder.Current(exchange, pair, interval.Name); ok {
+			response["current"] = current
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
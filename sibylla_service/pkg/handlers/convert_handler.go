@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"sibylla_service/pkg/conversion"
+)
+
+// ConvertHandler restates prices in another quote asset.
+//
+//	?from=USDT&to=USD                    conversion rate between two assets
+//	?pair=BTCUSDT&exchange=binance&to=USD  a venue's last price in another quote
+//	?pair=ETHBTC&to=BTC                  a pair derived from the asset graph
+func ConvertHandler(converter *conversion.Converter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		to := query.Get("to")
+		if to == "" {
+			http.Error(w, "to is required", http.StatusBadRequest)
+			return
+		}
+
+		var response interface{}
+		var err error
+		switch {
+		case query.Get("from") != "":
+			response, err = converter.Rate(query.Get("from"), to)
+		case query.Get("pair") != "":
+			response, err = converter.Convert(query.Get("exchange"), query.Get("pair"), to)
+		default:
+			http.Error(w, "from or pair is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"sibylla_service/pkg/flow"
+)
+
+// FlowHandler serves aggressor order flow for a pair: the aggregate across
+// exchanges and each exchange's own, optionally narrowed to one exchange and
+// one window. Without a pair it returns every pair's aggregate.
+func FlowHandler(tracker *flow.Tracker) http.HandlerFunc {
+	return windowedHandler[flow.Flow](tracker, "flow")
+}
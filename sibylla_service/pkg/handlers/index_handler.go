@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"sibylla_service/pkg/index"
+)
+
+// IndexHandler serves the composite index price for a pair, or for every pair
+// when no pair is given.
+func IndexHandler(engine *index.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		if pair := r.URL.Query().Get("pair"); pair != "" {
+			price, ok := engine.Current(pair)
+			if !ok {
+				http.Error(w, "No index for pair "+pair, http.StatusNotFound)
+				return
+			}
+			response = price
+		} else {
+			response = map[string]interface{}{"index": engine.All()}
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/leadlag"
+	"sibylla_service/pkg/redisclient"
+)
+
+// LeadLagHandler serves the latest lead-lag report for the market a pair
+// prices, or every market when none is given. With a limit it also returns the
+// market's stored reports, newest first.
+func LeadLagHandler(redisClient *redisclient.RedisClient, analyzer *leadlag.Analyzer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pair := query.Get("pair")
+
+		var response interface{}
+		if pair == "" {
+			reports := make([]leadlag.Report, 0)
+			for _, p := range analyzer.Pairs() {
+				if report, ok := analyzer.Current(p); ok {
+					reports = append(reports, report)
+				}
+			}
+			response = map[string]interface{}{"reports": reports}
+		} else {
+			report, ok := analyzer.Current(pair)
+			if !ok {
+				http.Error(w, "No lead-lag report for pair "+pair, http.StatusNotFound)
+				return
+			}
+			result := map[string]interface{}{"report": report}
+
+			if l := query.Get("limit"); l != "" {
+				limit, err := strconv.ParseInt(l, 10, 64)
+				if err != nil || limit <= 0 {
+					http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+					return
+				}
+				stored, err := redisClient.GetStream(leadlag.Key(report.Pair), limit)
+				if err != nil {
+					http.Error(w, "Failed to retrieve lead-lag history", http.StatusInternalServerError)
+					return
+				}
+				history := make([]leadlag.Report, 0, len(stored))
+				for _, s := range stored {
+					var rep leadlag.Report
+					if err := json.Unmarshal([]byte(s), &rep); err != nil {
+						log.Printf("Failed to unmarshal lead-lag report for %s: %v", pair, err)
+						continue
+					}
+					history = append(history, rep)
+				}
+				result["history"] = history
+			}
+			response = result
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/peg"
+	"sibylla_service/pkg/redisclient"
+)
+
+// PegObservation is the body of a POST feeding a peg an estimate from outside
+// the trade stream, such as the ratio quoted by an on-chain pool.
+type PegObservation struct {
+	Asset     string  `json:"asset"`
+	Reference string  `json:"reference"`
+	Source    string  `json:"source"`
+	Ratio     float64 `json:"ratio"`
+}
+
+// PegsHandler serves the current state of every monitored peg and the most
+// recent peg events (limit, default 50, newest first). POST feeds a peg an
+// external observation, which counts until it goes stale.
+func PegsHandler(redisClient *redisclient.RedisClient, monitor *peg.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			observePeg(w, r, monitor)
+			return
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit := int64(50)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stored, err := redisClient.GetStream(peg.EventsKey, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve peg events", http.StatusInternalServerError)
+			return
+		}
+
+		events := make([]peg.Event, 0, len(stored))
+		for _, s := range stored {
+			var e peg.Event
+			if err := json.Unmarshal([]byte(s), &e); err != nil {
+				log.Printf("Failed to unmarshal peg event: %v", err)
+				continue
+			}
+			events = append(events, e)
+		}
+
+		responseJSON, err := json.Marshal(map[string]interface{}{
+			"pegs":   monitor.Statuses(),
+			"events": events,
+		})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
+
+func observePeg(w http.ResponseWriter, r *http.Request, monitor *peg.Monitor) {
+	var o PegObservation
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		http.Error(w, "Invalid peg observation: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := monitor.Observe(o.Asset, o.Reference, o.Source, o.Ratio); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, peg.ErrUnknownPeg) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
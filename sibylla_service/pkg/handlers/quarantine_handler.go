@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/filter"
+	"sibylla_service/pkg/redisclient"
+)
+
+// QuarantineHandler serves rejection counts per exchange and reason, and the
+// most recently quarantined trades (limit, default 100, newest first),
+// optionally only those from one exchange.
+func QuarantineHandler(redisClient *redisclient.RedisClient, f *filter.Filter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		exchange := query.Get("exchange")
+
+		limit := int64(100)
+		if l := query.Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		key := filter.StoreKey
+		if exchange != "" {
+			key = filter.Key(exchange)
+		}
+		stored, err := redisClient.GetStream(key, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve quarantined trades", http.StatusInternalServerError)
+			return
+		}
+
+		trades := make([]filter.Rejected, 0, len(stored))
+		for _, s := range stored {
+			var rejected filter.Rejected
+			if err := json.Unmarshal([]byte(s), &rejected); err != nil {
+				log.Printf("Failed to unmarshal quarantined trade: %v", err)
+				continue
+			}
+			trades = append(trades, rejected)
+		}
+
+		responseJSON, err := json.Marshal(map[string]interface{}{
+			"rejections": f.Rejections(),
+			"trades":     trades,
+		})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes of structured API errors
+const (
+	ErrInvalidParameter = "invalid_parameter"
+	ErrInternal         = "internal"
+)
+
+// APIError is the body of a structured error response.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"` // query parameter or body field at fault
+}
+
+// ErrorResponse wraps an APIError as {"error": {...}}.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	responseJSON, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+// writeError responds with a structured JSON error.
+func writeError(w http.ResponseWriter, status int, code, param, message string) {
+	writeJSON(w, status, ErrorResponse{Error: APIError{Code: code, Message: message, Param: param}})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/expr"
+	"sibylla_service/pkg/redisclient"
+)
+
+// SeriesHandler manages derived series. GET returns every series, one series
+// (name) with its stored values (limit, default 100, newest first), or the
+// value of an ad hoc expression (expr). POST defines a series from a JSON
+// {"name", "expr"} body and DELETE removes the series given by name. Invalid
+// expressions are answered with a structured error giving the offending column.
+func SeriesHandler(redisClient *redisclient.RedisClient, engine *expr.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch r.Method {
+		case http.MethodGet:
+			if src := query.Get("expr"); src != "" {
+				value, err := engine.Evaluate(src, time.Now())
+				if err != nil {
+					writeExprError(w, err)
+					return
+				}
+				writeJSON(w, http.StatusOK, map[string]interface{}{"expr": src, "value": value})
+				return
+			}
+
+			name := query.Get("name")
+			if name == "" {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"series": engine.All()})
+				return
+			}
+			s, ok := engine.Get(name)
+			if !ok {
+				http.Error(w, "No series "+name, http.StatusNotFound)
+				return
+			}
+
+			limit := int64(100)
+			if l := query.Get("limit"); l != "" {
+				var err error
+				limit, err = strconv.ParseInt(l, 10, 64)
+				if err != nil || limit <= 0 {
+					http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+					return
+				}
+			}
+			stored, err := redisClient.GetStream(expr.Key(name), limit)
+			if err != nil {
+				http.Error(w, "Failed to retrieve series values", http.StatusInternalServerError)
+				return
+			}
+			values := make([]expr.Value, 0, len(stored))
+			for _, raw := range stored {
+				var v expr.Value
+				if err := json.Unmarshal([]byte(raw), &v); err != nil {
+					log.Printf("Failed to unmarshal value of series %s: %v", name, err)
+					continue
+				}
+				values = append(values, v)
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"series": s, "values": values})
+		case http.MethodPost:
+			var def exchangeconfig.SeriesDefinition
+			if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+				http.Error(w, "Invalid series definition: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := engine.Define(def.Name, def.Expr); err != nil {
+				if errors.Is(err, expr.ErrNotStored) {
+					http.Error(w, "Failed to store series definition", http.StatusInternalServerError)
+					return
+				}
+				writeExprError(w, err)
+				return
+			}
+			s, _ := engine.Get(def.Name)
+			writeJSON(w, http.StatusCreated, s)
+		case http.MethodDelete:
+			name := query.Get("name")
+			if name == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			if err := engine.Remove(name); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// writeExprError reports an invalid definition, or an expression that failed
+// to compile or evaluate. Errors within the expression name the expr
+// parameter, and their message gives the offending column.
+func writeExprError(w http.ResponseWriter, err error) {
+	param := ""
+	var exprErr *expr.Error
+	if errors.As(err, &exprErr) {
+		param = "expr"
+	}
+	writeError(w, http.StatusBadRequest, ErrInvalidParameter, param, err.Error())
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/expr"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/redisclient"
+)
+
+func TestSeriesErrors(t *testing.T) {
+	m := miniredis.RunT(t)
+	redisClient := redisclient.NewRedisClient(m.Addr(), "", 0)
+	engine, err := expr.NewEngine(market.NewTracker(), nil, redisClient, nil, exchangeconfig.SeriesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := SeriesHandler(redisClient, engine)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/api/series", strings.NewReader(body)))
+		return w
+	}
+
+	w := get(t, handler, "/api/series?expr=1+%2B+%2A")
+	var response ErrorResponse
+	if w.Code != http.StatusBadRequest || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+		t.Fatalf("GET with an invalid expr = %d %s", w.Code, w.Body)
+	}
+	if e := response.Error; e.Code != ErrInvalidParameter || e.Param != "expr" || !strings.Contains(e.Message, "column 5") {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	w = post(`{"name": "two words", "expr": "1"}`)
+	response = ErrorResponse{}
+	if w.Code != http.StatusBadRequest || json.Unmarshal(w.Body.Bytes(), &response) != nil || response.Error.Param != "" {
+		t.Errorf("POST with an invalid name = %d %s", w.Code, w.Body)
+	}
+
+	// Storage failures are the service's, not the request's
+	m.Close()
+	if w = post(`{"name": "one", "expr": "1"}`); w.Code != http.StatusInternalServerError {
+		t.Errorf("POST with Redis down = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spread"
+)
+
+// SpreadsHandler serves the live cross-exchange spreads, optionally filtered by
+// pair. Pegged quotes are equivalent, so pair=BTCUSDT also matches BTCUSD.
+// With exchange_a and exchange_b it also returns that spread's stored time series
+// (limit points, default 100, oldest first).
+func SpreadsHandler(redisClient *redisclient.RedisClient, engine *spread.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pair := query.Get("pair")
+		if pair != "" {
+			pair = models.CanonicalPair(pair)
+		}
+
+		response := map[string]interface{}{
+			"spreads": engine.Current(pair),
+		}
+
+		exchangeA, exchangeB := query.Get("exchange_a"), query.Get("exchange_b")
+		if exchangeA != "" || exchangeB != "" {
+			if pair == "" || exchangeA == "" || exchangeB == "" {
+				http.Error(w, "pair, exchange_a and exchange_b are required for history", http.StatusBadRequest)
+				return
+			}
+			if exchangeB < exchangeA {
+				exchangeA, exchangeB = exchangeB, exchangeA
+			}
+
+			limit := int64(100)
+			if l := query.Get("limit"); l != "" {
+				var err error
+				limit, err = strconv.ParseInt(l, 10, 64)
+				if err != nil || limit <= 0 {
+					http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+					return
+				}
+			}
+
+			stored, err := redisClient.GetStream(spread.Key(pair, exchangeA, exchangeB), limit)
+			if err != nil {
+				http.Error(w, "Failed to retrieve spread history", http.StatusInternalServerError)
+				return
+			}
+
+			history := make([]spread.Spread, 0, len(stored))
+			for i := len(stored) - 1; i >= 0; i-- {
+				var s spread.Spread
+				if err := json.Unmarshal([]byte(stored[i]), &s); err != nil {
+					log.Printf("Failed to unmarshal spread for %s: %v", pair, err)
+					continue
+				}
+				history = append(history, s)
+			}
+			response["history"] = history
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
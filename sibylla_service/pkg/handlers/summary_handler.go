@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spread"
+)
+
+// HistoryDepth is how many trades are retained for a market and how far back they go.
+type HistoryDepth struct {
+	Count       int64   `json:"count"`
+	Oldest      int64   `json:"oldest,omitempty"`
+	Newest      int64   `json:"newest,omitempty"`
+	SpanSeconds float64 `json:"span_seconds,omitempty"`
+}
+
+// MarketSummary is the latest trade of one exchange and pair.
+type MarketSummary struct {
+	Key   string        `json:"key"`
+	Trade TradeRecord   `json:"trade"`
+	Depth *HistoryDepth `json:"depth,omitempty"`
+}
+
+// SummaryResponse is what the dashboard polls: every market's latest trade and
+// the live spreads, with the widest as its delta.
+type SummaryResponse struct {
+	Markets []MarketSummary `json:"markets"`
+	SpreadSummary
+}
+
+// SummaryHandler serves the latest trade of every market and the live spreads.
+func SummaryHandler(redisClient *redisclient.RedisClient, spreadEngine *spread.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := tradeKeys(redisClient, tradesQuery{})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrInternal, "", "Failed to retrieve trade keys")
+			return
+		}
+
+		response := SummaryResponse{
+			Markets:       make([]MarketSummary, 0, len(keys)),
+			SpreadSummary: newSpreadSummary(spreadEngine),
+		}
+		for _, key := range keys {
+			entries, err := redisClient.GetStreamRange(key, "+", "-", 1)
+			if err != nil || len(entries) == 0 {
+				log.Printf("No trades found for key: %s", key)
+				continue
+			}
+
+			// Stored trades may be compact binary or legacy JSON, decode to the struct
+			t, err := models.DecodeTrade(entries[0].Value)
+			if err != nil {
+				log.Printf("Failed to decode trade data for key %s: %v", key, err)
+				continue
+			}
+			response.Markets = append(response.Markets, MarketSummary{
+				Key:   key,
+				Trade: newTradeRecord(entries[0].ID, t),
+				Depth: historyDepth(redisClient, key),
+			})
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// historyDepth reports how many trades are retained for a key and how far back they go.
+func historyDepth(redisClient *redisclient.RedisClient, key string) *HistoryDepth {
+	depth, err := redisClient.GetStreamDepth(key)
+	if err != nil {
+		return nil
+	}
+
+	result := &HistoryDepth{Count: depth.Count}
+	if depth.Count > 0 {
+		result.Oldest = depth.Oldest.UnixMilli()
+		result.Newest = depth.Newest.UnixMilli()
+		result.SpanSeconds = depth.Newest.Sub(depth.Oldest).Seconds()
+	}
+	return result
+}
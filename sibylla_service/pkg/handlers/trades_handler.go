@@ -1,51 +1,321 @@
 package handlers
 
 import (
-	"encoding/json"
+	"encoding/base64"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sibylla_service/pkg/models"
 	"sibylla_service/pkg/redisclient"
-	"sibylla_service/pkg/utils"
+	"sibylla_service/pkg/spread"
+)
+
+// Page sizes of the trades history
+const (
+	defaultTradesLimit = 100
+	maxTradesLimit     = 1000
 )
 
-func TradesHandler(redisClient *redisclient.RedisClient) http.HandlerFunc {
+// TradeRecord is a stored trade as served by the API.
+type TradeRecord struct {
+	ID           string  `json:"id"` // stream entry ID, the trade time in Unix ms
+	Exchange     string  `json:"exchange"`
+	Pair         string  `json:"pair"`
+	Price        float64 `json:"price"`
+	Quantity     float64 `json:"quantity"`
+	IsBuyerMaker bool    `json:"is_buyer_maker"`
+	Timestamp    int64   `json:"timestamp"`             // exchange trade time, Unix ms
+	ReceivedAt   int64   `json:"received_at,omitempty"` // local receive time, Unix ms
+}
+
+func newTradeRecord(id string, t models.Trade) TradeRecord {
+	return TradeRecord{
+		ID:           id,
+		Exchange:     t.Exchange,
+		Pair:         t.Pair,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		IsBuyerMaker: t.IsBuyerMaker,
+		Timestamp:    t.Timestamp,
+		ReceivedAt:   t.ReceivedAt,
+	}
+}
+
+// SpreadSummary is the live spread between every pair of venues, with the
+// widest as the dashboard's delta.
+type SpreadSummary struct {
+	Spreads     []spread.Spread `json:"spreads"`
+	Delta       float64         `json:"delta"`
+	DeltaSpread *spread.Spread  `json:"delta_spread,omitempty"`
+}
+
+func newSpreadSummary(spreadEngine *spread.Engine) SpreadSummary {
+	summary := SpreadSummary{Spreads: spreadEngine.Current("")}
+	if widest, ok := spreadEngine.Widest(); ok {
+		summary.Delta = widest.Absolute
+		summary.DeltaSpread = &widest
+	}
+	return summary
+}
+
+// TradesResponse is a page of trades, newest first, with the live spreads.
+// NextCursor is set when more trades match and is passed back as cursor to
+// get the next page.
+type TradesResponse struct {
+	Trades     []TradeRecord `json:"trades"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	SpreadSummary
+}
+
+type tradesQuery struct {
+	exchange string
+	pair     string
+	from, to int64 // Unix ms, zero when open
+	limit    int64
+	cursor   *tradesCursor
+}
+
+// tradesCursor is the last trade of a page. Pages are ordered by stream ID,
+// newest first, then by store key so trades stored in the same millisecond on
+// different markets have a stable order.
+type tradesCursor struct {
+	id  string
+	key string
+}
+
+func (c tradesCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.id + " " + c.key))
+}
+
+func decodeCursor(s string) (*tradesCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	id, key, ok := strings.Cut(string(raw), " ")
+	if !ok || !strings.HasPrefix(key, "trades:") {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if _, ok := parseStreamID(id); !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	return &tradesCursor{id: id, key: key}, nil
+}
+
+// TradesHandler serves stored trades newest first, optionally filtered by
+// exchange, instrument and a from/to range of trade time (Unix ms or RFC 3339,
+// inclusive). Pages hold limit trades (default 100, at most 1000) and continue
+// from cursor. Every page carries the live spreads.
+func TradesHandler(redisClient *redisclient.RedisClient, spreadEngine *spread.Engine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Get all keys matching the pattern "trades:*"
-		keys, err := redisClient.Keys("trades:*")
-		if err != nil {
-			http.Error(w, "Failed to retrieve trade keys", http.StatusInternalServerError)
+		q, apiErr := parseTradesQuery(r.URL.Query())
+		if apiErr != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: *apiErr})
 			return
 		}
 
-		response := make(map[string]interface{})
+		keys, err := tradeKeys(redisClient, q)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrInternal, "", "Failed to retrieve trade keys")
+			return
+		}
 
+		type keyed struct {
+			key    string
+			record TradeRecord
+		}
+		var page []keyed
 		for _, key := range keys {
-			trades, err := redisClient.GetList(key, 1)
-			if err != nil || len(trades) == 0 {
-				log.Printf("No trades found for key: %s", key)
-				trades = []string{"{\"Price\":0}"}
+			// One more than the limit tells whether there is a next page
+			entries, err := redisClient.GetStreamRange(key, q.newest(key), q.oldest(), q.limit+1)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, ErrInternal, "", "Failed to retrieve trades")
+				return
 			}
-
-			var tradeData map[string]interface{}
-			if err := json.Unmarshal([]byte(trades[0]), &tradeData); err != nil {
-				log.Printf("Failed to unmarshal trade data for key: %s", key)
-				continue
+			for _, e := range entries {
+				t, err := models.DecodeTrade(e.Value)
+				if err != nil {
+					log.Printf("Failed to decode trade %s in %s: %v", e.ID, key, err)
+					continue
+				}
+				page = append(page, keyed{key: key, record: newTradeRecord(e.ID, t)})
 			}
+		}
 
-			price := utils.ParseTradePrice(trades[0])
-			response[key] = map[string]interface{}{
-				"trades": tradeData,
-				"price":  price,
+		sort.Slice(page, func(i, j int) bool {
+			if c := compareStreamIDs(page[i].record.ID, page[j].record.ID); c != 0 {
+				return c > 0
 			}
+			return page[i].key < page[j].key
+		})
+
+		response := TradesResponse{Trades: make([]TradeRecord, 0, len(page)), SpreadSummary: newSpreadSummary(spreadEngine)}
+		if int64(len(page)) > q.limit {
+			page = page[:q.limit]
+			last := page[len(page)-1]
+			response.NextCursor = tradesCursor{id: last.record.ID, key: last.key}.encode()
+		}
+		for _, k := range page {
+			response.Trades = append(response.Trades, k.record)
 		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
 
-		responseJSON, err := json.Marshal(response)
+func parseTradesQuery(query url.Values) (tradesQuery, *APIError) {
+	q := tradesQuery{
+		exchange: query.Get("exchange"),
+		pair:     query.Get("instrument"),
+		limit:    defaultTradesLimit,
+	}
+	invalid := func(param, format string, args ...interface{}) (tradesQuery, *APIError) {
+		return q, &APIError{Code: ErrInvalidParameter, Param: param, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if q.exchange != "" && !validKeyPart(q.exchange) {
+		return invalid("exchange", "exchange may only contain letters, digits, '.', '_' and '-'")
+	}
+	if q.pair != "" && !validKeyPart(q.pair) {
+		return invalid("instrument", "instrument may only contain letters, digits, '.', '_' and '-'")
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || limit <= 0 || limit > maxTradesLimit {
+			return invalid("limit", "limit must be an integer from 1 to %d", maxTradesLimit)
+		}
+		q.limit = limit
+	}
+	for _, bound := range []struct {
+		param string
+		value *int64
+	}{{"from", &q.from}, {"to", &q.to}} {
+		s := query.Get(bound.param)
+		if s == "" {
+			continue
+		}
+		ms, err := parseTime(s)
 		if err != nil {
-			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
-			return
+			return invalid(bound.param, "%s must be Unix milliseconds or an RFC 3339 time", bound.param)
 		}
+		*bound.value = ms
+	}
+	if q.from > 0 && q.to > 0 && q.from > q.to {
+		return invalid("from", "from must not be after to")
+	}
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := decodeCursor(c)
+		if err != nil {
+			return invalid("cursor", "cursor is not one returned as next_cursor")
+		}
+		q.cursor = cursor
+	}
+	return q, nil
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(responseJSON)
+// newest is the upper stream ID bound for a key: the end of the time range, or
+// just past the cursor. Trades are stored under their trade time, see
+// redisclient.AppendToStreamAt, so the range is one of trade time.
+func (q tradesQuery) newest(key string) string {
+	if q.cursor != nil {
+		// Keys after the cursor's may still hold trades stored in its millisecond
+		if key > q.cursor.key {
+			return q.cursor.id
+		}
+		return "(" + q.cursor.id
+	}
+	if q.to > 0 {
+		// A bare millisecond covers every sequence stored in it
+		return strconv.FormatInt(q.to, 10)
+	}
+	return "+"
+}
+
+func (q tradesQuery) oldest() string {
+	if q.from > 0 {
+		return strconv.FormatInt(q.from, 10)
+	}
+	return "-"
+}
+
+// tradeKeys lists the store keys matching the exchange and instrument filters, sorted.
+func tradeKeys(redisClient *redisclient.RedisClient, q tradesQuery) ([]string, error) {
+	if q.exchange != "" && q.pair != "" {
+		return []string{models.TradeKey(q.exchange, q.pair)}, nil
+	}
+
+	exchange, pair := q.exchange, q.pair
+	if exchange == "" {
+		exchange = "*"
+	}
+	if pair == "" {
+		pair = "*"
+	}
+	keys, err := redisClient.ScanKeys(models.TradeKey(exchange, pair))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	// SCAN may return a key twice
+	unique := keys[:0]
+	for i, key := range keys {
+		if i == 0 || keys[i-1] != key {
+			unique = append(unique, key)
+		}
+	}
+	return unique, nil
+}
+
+// validKeyPart keeps filters free of Redis pattern characters.
+func validKeyPart(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTime(s string) (int64, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("negative time")
+		}
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// parseStreamID splits a stream entry ID into its millisecond time and sequence.
+func parseStreamID(id string) ([2]uint64, bool) {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return [2]uint64{}, false
+	}
+	a, errA := strconv.ParseUint(ms, 10, 64)
+	b, errB := strconv.ParseUint(seq, 10, 64)
+	return [2]uint64{a, b}, errA == nil && errB == nil
+}
+
+func compareStreamIDs(a, b string) int {
+	x, _ := parseStreamID(a)
+	y, _ := parseStreamID(b)
+	for i := range x {
+		if x[i] != y[i] {
+			if x[i] > y[i] {
+				return 1
+			}
+			return -1
+		}
 	}
+	return 0
 }
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spread"
+)
+
+func tradesFixture(t *testing.T) http.HandlerFunc {
+	t.Helper()
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	spreads := spread.NewEngine(tracker, nil, nil, nil, exchangeconfig.SpreadConfig{})
+
+	for _, tr := range []models.Trade{
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1, Timestamp: 1000},
+		{Exchange: "kraken", Pair: "BTCUSD", Price: 101, Quantity: 1, Timestamp: 1500},
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 102, Quantity: 1, Timestamp: 2000},
+		{Exchange: "binance", Pair: "ETHUSDT", Price: 5, Quantity: 1, Timestamp: 2000},
+		{Exchange: "kraken", Pair: "BTCUSD", Price: 103, Quantity: 1, Timestamp: 3000},
+	} {
+		if err := redisClient.AppendToStreamAt(models.TradeKey(tr.Exchange, tr.Pair), tr.Timestamp, tr, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		tracker.Update(tr)
+		spreads.OnTrade(tr)
+	}
+	return TradesHandler(redisClient, spreads)
+}
+
+func getTrades(t *testing.T, handler http.HandlerFunc, url string) TradesResponse {
+	t.Helper()
+	w := get(t, handler, url)
+	var response TradesResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+		t.Fatalf("GET %s = %d %s", url, w.Code, w.Body)
+	}
+	return response
+}
+
+func timestamps(response TradesResponse) []int64 {
+	var ts []int64
+	for _, tr := range response.Trades {
+		ts = append(ts, tr.Timestamp)
+	}
+	return ts
+}
+
+func TestTradesFilters(t *testing.T) {
+	handler := tradesFixture(t)
+
+	all := getTrades(t, handler, "/api/trades")
+	if got := timestamps(all); len(got) != 5 || got[0] != 3000 || got[4] != 1000 {
+		t.Errorf("trades at %v, want all 5 newest first", got)
+	}
+	if len(all.Spreads) == 0 || all.DeltaSpread == nil || all.Delta == 0 {
+		t.Errorf("spreads %+v, delta %v, want the live spreads with the widest as delta", all.Spreads, all.Delta)
+	}
+
+	if got := timestamps(getTrades(t, handler, "/api/trades?exchange=binance")); len(got) != 3 {
+		t.Errorf("binance trades at %v, want 3", got)
+	}
+	if got := timestamps(getTrades(t, handler, "/api/trades?instrument=BTCUSD")); len(got) != 2 || got[0] != 3000 {
+		t.Errorf("BTCUSD trades at %v, want kraken's 2", got)
+	}
+	// The range is inclusive and on trade time
+	if got := timestamps(getTrades(t, handler, "/api/trades?from=1500&to=2000")); len(got) != 3 || got[0] != 2000 || got[2] != 1500 {
+		t.Errorf("trades from 1500 to 2000 at %v", got)
+	}
+	if got := timestamps(getTrades(t, handler, "/api/trades?from=1970-01-01T00:00:02.5Z")); len(got) != 1 || got[0] != 3000 {
+		t.Errorf("trades from an RFC 3339 time at %v", got)
+	}
+}
+
+func TestTradesPagination(t *testing.T) {
+	handler := tradesFixture(t)
+
+	var got []int64
+	var pairs []string
+	url := "/api/trades?limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination doesn't end")
+		}
+		page := getTrades(t, handler, url)
+		if len(page.Trades) > 2 {
+			t.Fatalf("page of %d trades, want at most 2", len(page.Trades))
+		}
+		got = append(got, timestamps(page)...)
+		for _, tr := range page.Trades {
+			pairs = append(pairs, tr.Pair)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		url = "/api/trades?limit=2&cursor=" + page.NextCursor
+	}
+
+	// Both trades at 2000 are on one page boundary or the other, never twice
+	want := []int64{3000, 2000, 2000, 1500, 1000}
+	if len(got) != len(want) {
+		t.Fatalf("paged through %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("paged through %v, want %v", got, want)
+			break
+		}
+	}
+	if pairs[1] == pairs[2] {
+		t.Errorf("the trades at 2000 were %v", pairs[1:3])
+	}
+}
+
+func TestTradesInvalidParameters(t *testing.T) {
+	handler := tradesFixture(t)
+
+	for param, url := range map[string]string{
+		"exchange":   "/api/trades?exchange=bin*",
+		"instrument": "/api/trades?instrument=BTC%20USD",
+		"limit":      "/api/trades?limit=5000",
+		"from":       "/api/trades?from=2000&to=1000",
+		"to":         "/api/trades?to=yesterday",
+		"cursor":     "/api/trades?cursor=bm9wZQ",
+	} {
+		w := get(t, handler, url)
+		var response ErrorResponse
+		if w.Code != http.StatusBadRequest || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+			t.Errorf("GET %s = %d %s, want a 400", url, w.Code, w.Body)
+			continue
+		}
+		if response.Error.Code != ErrInvalidParameter || response.Error.Param != param {
+			t.Errorf("GET %s error %+v, want %s invalid", url, response.Error, param)
+		}
+	}
+}
+
+func TestTradeKeysScanEveryInstrument(t *testing.T) {
+	m := miniredis.RunT(t)
+	redisClient := redisclient.NewRedisClient(m.Addr(), "", 0)
+	// More keys than one SCAN call looks at
+	for i := 0; i < 1500; i++ {
+		m.Set(models.TradeKey("binance", fmt.Sprintf("P%04d", i)), "1")
+	}
+	m.Set(models.TradeKey("kraken", "P0000"), "1")
+
+	keys, err := tradeKeys(redisClient, tradesQuery{exchange: "binance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1500 {
+		t.Fatalf("got %d keys, want the 1500 binance keys", len(keys))
+	}
+	if keys[0] != models.TradeKey("binance", "P0000") || keys[1499] != models.TradeKey("binance", "P1499") {
+		t.Errorf("keys run from %s to %s", keys[0], keys[1499])
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			t.Fatalf("keys out of order or repeated at %d: %s, %s", i, keys[i-1], keys[i])
+		}
+	}
+}
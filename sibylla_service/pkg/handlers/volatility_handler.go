@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"sibylla_service/pkg/volatility"
+)
+
+// VolatilityHandler serves realized volatility and return statistics for a pair
+// on every exchange, or on one exchange, over every window or just one.
+func VolatilityHandler(service *volatility.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pair := query.Get("pair")
+		if pair == "" {
+			http.Error(w, "pair is required", http.StatusBadRequest)
+			return
+		}
+
+		var window time.Duration
+		if name := query.Get("window"); name != "" {
+			var err error
+			window, err = time.ParseDuration(name)
+			if err != nil || window <= 0 {
+				http.Error(w, "window must be a duration such as 24h", http.StatusBadRequest)
+				return
+			}
+		}
+
+		exchanges := service.Exchanges(pair)
+		if exchange := query.Get("exchange"); exchange != "" {
+			exchanges = []string{exchange}
+		}
+
+		estimates := make(map[string][]volatility.Estimates)
+		for _, exchange := range exchanges {
+			if window == 0 {
+				if all := service.All(exchange, pair); len(all) > 0 {
+					estimates[exchange] = all
+				}
+			} else if e, ok := service.Estimate(exchange, pair, window); ok {
+				estimates[exchange] = []volatility.Estimates{e}
+			}
+		}
+		if len(estimates) == 0 {
+			http.Error(w, "No volatility estimates for pair "+pair, http.StatusNotFound)
+			return
+		}
+
+		responseJSON, err := json.Marshal(map[string]interface{}{
+			"pair":      pair,
+			"exchanges": estimates,
+		})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
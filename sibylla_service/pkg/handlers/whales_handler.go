@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/whale"
+)
+
+// WhalesHandler serves the most recent large-trade events (limit, default 100,
+// newest first), across every instrument or for one exchange and pair.
+func WhalesHandler(redisClient *redisclient.RedisClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		exchange, pair := query.Get("exchange"), query.Get("pair")
+		if (exchange == "") != (pair == "") {
+			http.Error(w, "exchange and pair must be given together", http.StatusBadRequest)
+			return
+		}
+
+		limit := int64(100)
+		if l := query.Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		key := whale.StoreKey
+		if exchange != "" {
+			key = whale.Key(exchange, pair)
+		}
+		stored, err := redisClient.GetStream(key, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve whale events", http.StatusInternalServerError)
+			return
+		}
+
+		events := make([]whale.Event, 0, len(stored))
+		for _, s := range stored {
+			var e whale.Event
+			if err := json.Unmarshal([]byte(s), &e); err != nil {
+				log.Printf("Failed to unmarshal whale event: %v", err)
+				continue
+			}
+			events = append(events, e)
+		}
+
+		responseJSON, err := json.Marshal(map[string]interface{}{"events": events})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// windowedSource keeps a per-venue measure over a set of rolling windows,
+// aggregated across exchanges when exchange is empty.
+type windowedSource[T any] interface {
+	Get(exchange, pair string, window time.Duration) (T, bool)
+	All(exchange, pair string) []T
+	Exchanges(pair string) []string
+	Pairs() []string
+}
+
+// windowedHandler serves a windowed measure. With a pair it returns the
+// aggregate across exchanges and each exchange's own, optionally narrowed to one
+// exchange and one window; without a pair it returns every pair's aggregate
+// under name.
+func windowedHandler[T any](source windowedSource[T], name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pair, exchange := query.Get("pair"), query.Get("exchange")
+
+		var window time.Duration
+		if name := query.Get("window"); name != "" {
+			var err error
+			window, err = time.ParseDuration(name)
+			if err != nil || window <= 0 {
+				http.Error(w, "window must be a duration such as 5m", http.StatusBadRequest)
+				return
+			}
+		}
+
+		values := func(exchange, pair string) []T {
+			if window == 0 {
+				return source.All(exchange, pair)
+			}
+			if v, ok := source.Get(exchange, pair, window); ok {
+				return []T{v}
+			}
+			return []T{}
+		}
+
+		var response interface{}
+		if pair == "" {
+			all := make(map[string][]T)
+			for _, p := range source.Pairs() {
+				all[p] = values("", p)
+			}
+			response = map[string]interface{}{name: all}
+		} else {
+			exchanges := source.Exchanges(pair)
+			if len(exchanges) == 0 {
+				http.Error(w, "No trades for pair "+pair, http.StatusNotFound)
+				return
+			}
+			if exchange != "" {
+				exchanges = []string{exchange}
+			}
+
+			venues := make(map[string][]T)
+			for _, e := range exchanges {
+				venues[e] = values(e, pair)
+			}
+			response = map[string]interface{}{
+				"pair":      pair,
+				"aggregate": values("", pair),
+				"exchanges": venues,
+			}
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/flow"
+	"sibylla_service/pkg/models"
+)
+
+func get(t *testing.T, handler http.HandlerFunc, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+func TestWindowedHandler(t *testing.T) {
+	tracker := flow.NewTracker(exchangeconfig.FlowConfig{})
+	tracker.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1})
+	tracker.OnTrade(models.Trade{Exchange: "kraken", Pair: "BTCUSDT", Price: 100, Quantity: 1})
+	handler := FlowHandler(tracker)
+
+	var all map[string]map[string][]flow.Flow
+	if w := get(t, handler, "/api/flow"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &all) != nil {
+		t.Fatalf("GET /api/flow = %d %s", w.Code, w.Body)
+	}
+	if flows := all["flow"]["BTCUSDT"]; len(flows) != len(exchangeconfig.DefaultFlowWindows) || flows[0].Exchange != "" {
+		t.Errorf("all pairs = %+v, want the aggregate over every window", all)
+	}
+
+	var one struct {
+		Pair      string                 `json:"pair"`
+		Aggregate []flow.Flow            `json:"aggregate"`
+		Exchanges map[string][]flow.Flow `json:"exchanges"`
+	}
+	w := get(t, handler, "/api/flow?pair=BTCUSDT&exchange=kraken&window=5m")
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &one) != nil {
+		t.Fatalf("GET pair = %d %s", w.Code, w.Body)
+	}
+	if len(one.Aggregate) != 1 || one.Aggregate[0].Window != "5m" || one.Aggregate[0].BuyVolume != 2 {
+		t.Errorf("aggregate = %+v, want 5m over both venues", one.Aggregate)
+	}
+	if len(one.Exchanges) != 1 || len(one.Exchanges["kraken"]) != 1 {
+		t.Errorf("exchanges = %+v, want kraken only", one.Exchanges)
+	}
+
+	if w := get(t, handler, "/api/flow?pair=ETHUSDT"); w.Code != http.StatusNotFound {
+		t.Errorf("untraded pair = %d, want 404", w.Code)
+	}
+	if w := get(t, handler, "/api/flow?pair=BTCUSDT&window=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad window = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,286 @@
+package index
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// Reasons a venue is left out of an index
+const (
+	ReasonStale   = "stale"
+	ReasonOutlier = "outlier"
+)
+
+// Contributor is a venue that was used in an index price, quoting it in Pair.
+type Contributor struct {
+	Exchange     string  `json:"exchange"`
+	Pair         string  `json:"pair"`
+	Price        float64 `json:"price"`
+	Weight       float64 `json:"weight"` // share of the total weight, 0..1
+	DeviationBps float64 `json:"deviation_bps"`
+}
+
+// Excluded is a venue that quoted the pair but was left out of the index.
+type Excluded struct {
+	Exchange string  `json:"exchange"`
+	Pair     string  `json:"pair"`
+	Price    float64 `json:"price"`
+	Reason   string  `json:"reason"`
+}
+
+// Price is the composite price of a pair across venues. Pair is the market
+// (see models.CanonicalPair), so venues quoting BTCUSDT and BTCUSD both
+// contribute to BTCUSD. Low and High bound the contributing prices and
+// ConfidenceBps is their weighted mean absolute deviation from Price. Valid
+// is false when too few venues contributed.
+type Price struct {
+	Pair          string        `json:"pair"`
+	Price         float64       `json:"price"`
+	Valid         bool          `json:"valid"`
+	Low           float64       `json:"low"`
+	High          float64       `json:"high"`
+	ConfidenceBps float64       `json:"confidence_bps"`
+	Contributors  []Contributor `json:"contributors"`
+	Excluded      []Excluded    `json:"excluded,omitempty"`
+	Timestamp     int64         `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (p Price) MarshalBinary() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Key is the store key holding the time series of an index. pair is the canonical pair.
+func Key(pair string) string {
+	return "index:" + pair
+}
+
+// Engine computes a volume-weighted median price per pair from every live
+// venue, rejecting stale venues and outliers.
+type Engine struct {
+	mu          sync.RWMutex
+	config      exchangeconfig.IndexConfig
+	tracker     *market.Tracker
+	averages    *average.Calculator // nil uses last trade prices
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	volume      map[string]*market.RollingSum // by exchange:pair
+	current     map[string]Price              // by pair
+	lastStored  map[string]time.Time          // by pair
+}
+
+func NewEngine(tracker *market.Tracker, averages *average.Calculator, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.IndexConfig) *Engine {
+	return &Engine{
+		config:      config.WithDefaults(),
+		tracker:     tracker,
+		averages:    averages,
+		redisClient: redisClient,
+		bus:         b,
+		volume:      make(map[string]*market.RollingSum),
+		current:     make(map[string]Price),
+		lastStored:  make(map[string]time.Time),
+	}
+}
+
+// OnTrade records the trade's volume and, unless the engine runs on a tick,
+// recomputes the index for the market its pair prices. The tracker must
+// already hold the trade.
+func (e *Engine) OnTrade(t models.Trade) {
+	now := time.Now()
+
+	e.mu.Lock()
+	key := t.Exchange + ":" + t.Pair
+	if e.volume[key] == nil {
+		e.volume[key] = market.NewRollingSum(e.config.VolumeWindow.Duration)
+	}
+	e.volume[key].Add(now, t.Quantity)
+	e.mu.Unlock()
+
+	if e.config.Interval.Duration <= 0 {
+		e.update(models.CanonicalPair(t.Pair), now)
+	}
+}
+
+// Run recomputes every index on a tick when an interval is configured.
+// It blocks, call it in a goroutine.
+func (e *Engine) Run() {
+	if e.config.Interval.Duration <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.config.Interval.Duration)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		updated := make(map[string]bool)
+		for _, pair := range e.tracker.Pairs() {
+			if canonical := models.CanonicalPair(pair); !updated[canonical] {
+				updated[canonical] = true
+				e.update(canonical, now)
+			}
+		}
+	}
+}
+
+func (e *Engine) update(pair string, now time.Time) {
+	e.mu.Lock()
+	price := e.compute(pair, now)
+	e.current[pair] = price
+	store := price.Valid && now.Sub(e.lastStored[pair]) >= e.config.StoreInterval.Duration
+	if store {
+		e.lastStored[pair] = now
+	}
+	e.mu.Unlock()
+
+	if e.bus != nil {
+		e.bus.Publish(bus.TopicIndex, price)
+	}
+	if store && e.redisClient != nil {
+		if err := e.redisClient.AppendToStream(Key(pair), price, e.config.MaxStored, 0); err != nil {
+			log.Printf("Could not store index %s: %v", pair, err)
+		}
+	}
+}
+
+type weighted struct {
+	quote  market.Quote
+	weight float64
+}
+
+// compute builds the index for a canonical pair from the tracker's latest
+// quotes of every pair pricing it.
+func (e *Engine) compute(pair string, now time.Time) Price {
+	result := Price{Pair: pair, Timestamp: now.UnixMilli()}
+
+	var live []weighted
+	for _, q := range e.tracker.Market(pair) {
+		if q.Age(now) > e.config.MaxStaleness.Duration {
+			result.Excluded = append(result.Excluded, Excluded{Exchange: q.Exchange, Pair: q.Pair, Price: q.Price, Reason: ReasonStale})
+			continue
+		}
+		q.Price = e.averages.Price(q, e.config.Price)
+		var weight float64
+		if v := e.volume[q.Exchange+":"+q.Pair]; v != nil {
+			weight = v.Sum(now)
+		}
+		live = append(live, weighted{quote: q, weight: weight})
+	}
+	if len(live) == 0 {
+		return result
+	}
+
+	// Reject venues too far from the median of all live venues. The plain median
+	// is used here so a single heavy venue can't drag the reference with it.
+	median := weightedMedian(equalWeights(live))
+	var contributing []weighted
+	for _, w := range live {
+		if math.Abs(w.quote.Price-median)/median*10000 > e.config.BandBps {
+			result.Excluded = append(result.Excluded, Excluded{Exchange: w.quote.Exchange, Pair: w.quote.Pair, Price: w.quote.Price, Reason: ReasonOutlier})
+			continue
+		}
+		contributing = append(contributing, w)
+	}
+	if len(contributing) == 0 {
+		return result
+	}
+
+	result.Price = weightedMedian(contributing)
+	result.Valid = len(contributing) >= e.config.MinVenues
+
+	total := totalWeight(contributing)
+	result.Low, result.High = contributing[0].quote.Price, contributing[0].quote.Price
+	for _, w := range contributing {
+		share := 1 / float64(len(contributing))
+		if total > 0 {
+			share = w.weight / total
+		}
+		deviation := (w.quote.Price - result.Price) / result.Price * 10000
+		result.ConfidenceBps += share * math.Abs(deviation)
+		result.Low = math.Min(result.Low, w.quote.Price)
+		result.High = math.Max(result.High, w.quote.Price)
+		result.Contributors = append(result.Contributors, Contributor{
+			Exchange:     w.quote.Exchange,
+			Pair:         w.quote.Pair,
+			Price:        w.quote.Price,
+			Weight:       share,
+			DeviationBps: deviation,
+		})
+	}
+	return result
+}
+
+// weightedMedian returns the price at which half the weight lies on either side.
+// Venues are weighted equally when none has any volume.
+func weightedMedian(quotes []weighted) float64 {
+	sorted := append([]weighted(nil), quotes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].quote.Price < sorted[j].quote.Price })
+
+	total := totalWeight(sorted)
+	if total == 0 {
+		for i := range sorted {
+			sorted[i].weight = 1
+		}
+		total = float64(len(sorted))
+	}
+
+	cumulative := 0.0
+	for i, w := range sorted {
+		cumulative += w.weight
+		if cumulative > total/2 {
+			return w.quote.Price
+		}
+		// Exactly half: average with the next price, as with an even count
+		if cumulative == total/2 && i+1 < len(sorted) {
+			return (w.quote.Price + sorted[i+1].quote.Price) / 2
+		}
+	}
+	return sorted[len(sorted)-1].quote.Price
+}
+
+func equalWeights(quotes []weighted) []weighted {
+	equal := make([]weighted, len(quotes))
+	for i, w := range quotes {
+		equal[i] = weighted{quote: w.quote, weight: 1}
+	}
+	return equal
+}
+
+func totalWeight(quotes []weighted) float64 {
+	total := 0.0
+	for _, w := range quotes {
+		total += w.weight
+	}
+	return total
+}
+
+// Current returns the latest index for the market a pair prices.
+func (e *Engine) Current(pair string) (Price, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	p, ok := e.current[models.CanonicalPair(pair)]
+	return p, ok
+}
+
+// All returns the latest index for every pair, sorted by pair.
+func (e *Engine) All() []Price {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	prices := make([]Price, 0, len(e.current))
+	for _, p := range e.current {
+		prices = append(prices, p)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Pair < prices[j].Pair })
+	return prices
+}
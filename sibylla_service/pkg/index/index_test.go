@@ -0,0 +1,101 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+func TestIndexAcrossPeggedQuotes(t *testing.T) {
+	tracker := market.NewTracker()
+	e := NewEngine(tracker, nil, nil, nil, exchangeconfig.IndexConfig{BandBps: 200})
+	for _, tr := range []models.Trade{
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1},
+		{Exchange: "kraken", Pair: "BTCUSD", Price: 101, Quantity: 3},
+		{Exchange: "coinbase", Pair: "BTCUSD", Price: 150, Quantity: 1},
+	} {
+		tracker.Update(tr)
+		e.OnTrade(tr)
+	}
+
+	for _, pair := range []string{"BTCUSD", "BTCUSDT"} {
+		p, ok := e.Current(pair)
+		if !ok {
+			t.Fatalf("no index for %s", pair)
+		}
+		if p.Pair != "BTCUSD" || !p.Valid || p.Price != 101 || p.Low != 100 || p.High != 101 {
+			t.Errorf("unexpected index %+v", p)
+		}
+		if len(p.Contributors) != 2 || p.Contributors[0].Exchange != "binance" || p.Contributors[0].Pair != "BTCUSDT" || p.Contributors[0].Weight != 0.25 {
+			t.Errorf("unexpected contributors %+v", p.Contributors)
+		}
+		if len(p.Excluded) != 1 || p.Excluded[0].Exchange != "coinbase" || p.Excluded[0].Reason != ReasonOutlier {
+			t.Errorf("unexpected exclusions %+v", p.Excluded)
+		}
+	}
+	if all := e.All(); len(all) != 1 {
+		t.Errorf("got %d indexes, want one for BTCUSD", len(all))
+	}
+}
+
+func TestIndexNeedsMinVenues(t *testing.T) {
+	tracker := market.NewTracker()
+	e := NewEngine(tracker, nil, nil, nil, exchangeconfig.IndexConfig{})
+	tr := models.Trade{Exchange: "binance", Pair: "ETHUSDT", Price: 10, Quantity: 1}
+	tracker.Update(tr)
+	e.OnTrade(tr)
+
+	if p, ok := e.Current("ETHUSD"); !ok || p.Valid || p.Price != 10 {
+		t.Errorf("single venue index = %+v, want price 10 marked invalid", p)
+	}
+}
+
+func TestStoreInterval(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	e := NewEngine(tracker, nil, redisClient, nil, exchangeconfig.IndexConfig{StoreInterval: exchangeconfig.Duration{Duration: time.Hour}})
+	for _, price := range []float64{100, 101, 102} {
+		for _, exchange := range []string{"binance", "kraken"} {
+			tr := models.Trade{Exchange: exchange, Pair: "BTCUSD", Price: price, Quantity: 1}
+			tracker.Update(tr)
+			e.OnTrade(tr)
+		}
+	}
+
+	stored, err := redisClient.GetStream(Key("BTCUSD"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 {
+		t.Errorf("got %d stored points within the store interval, want 1", len(stored))
+	}
+	if p, _ := e.Current("BTCUSD"); p.Price != 102 {
+		t.Errorf("current index %v, want 102 whatever is stored", p.Price)
+	}
+}
+
+func TestWeightedMedian(t *testing.T) {
+	quote := func(price, weight float64) weighted {
+		return weighted{quote: market.Quote{Price: price}, weight: weight}
+	}
+	tests := []struct {
+		quotes []weighted
+		want   float64
+	}{
+		{[]weighted{quote(3, 1), quote(1, 1), quote(2, 1)}, 2},
+		{[]weighted{quote(1, 1), quote(2, 1)}, 1.5},
+		{[]weighted{quote(1, 1), quote(2, 5), quote(3, 1)}, 2},
+		{[]weighted{quote(1, 0), quote(3, 0)}, 2}, // no volume weighs venues equally
+	}
+	for _, tt := range tests {
+		if got := weightedMedian(tt.quotes); got != tt.want {
+			t.Errorf("weightedMedian(%+v) = %v, want %v", tt.quotes, got, tt.want)
+		}
+	}
+}
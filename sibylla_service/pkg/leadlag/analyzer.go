@@ -0,0 +1,326 @@
+package leadlag
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// LagCorrelation is the correlation of two venues' returns at one lag.
+type LagCorrelation struct {
+	LagMs       int64   `json:"lag_ms"`
+	Correlation float64 `json:"correlation"`
+}
+
+// PairStats compare two venues quoting the same market. A positive LeadMs means
+// ExchangeA's moves show up on ExchangeB that much later. The shares are
+// ExchangeA's; ExchangeB's are one minus them.
+type PairStats struct {
+	ExchangeA        string           `json:"exchange_a"`
+	ExchangeB        string           `json:"exchange_b"`
+	Samples          int              `json:"samples"`
+	LeadMs           int64            `json:"lead_ms"`
+	Correlation      float64          `json:"correlation"` // at LeadMs
+	Correlations     []LagCorrelation `json:"correlations"`
+	ComponentShare   float64          `json:"component_share"`
+	InformationShare Bounds           `json:"information_share"`
+	// Cointegrated is false when the prices didn't error-correct towards each
+	// other in the window, and the shares are meaningless
+	Cointegrated bool `json:"cointegrated"`
+}
+
+// Report is the lead-lag and price discovery analysis of one canonical pair
+// (see models.CanonicalPair). Leader is the venue with the highest mean
+// information share against the others.
+type Report struct {
+	Pair       string            `json:"pair"`
+	Clock      string            `json:"clock"`
+	Window     string            `json:"window"`
+	Resolution string            `json:"resolution"`
+	Start      int64             `json:"start"`
+	End        int64             `json:"end"`
+	Venues     []string          `json:"venues"`
+	VenuePairs map[string]string `json:"venue_pairs"` // the pair each venue quotes the market in
+	Pairs      []PairStats       `json:"pairs"`
+	Leader     string            `json:"leader,omitempty"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (r Report) MarshalBinary() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Key is the store key holding the reports of a canonical pair.
+func Key(pair string) string {
+	return "leadlag:" + pair
+}
+
+type point struct {
+	at    int64 // Unix ms on the configured clock
+	price float64
+}
+
+// venue is one exchange's trades of a market, in the pair it first traded.
+type venue struct {
+	pair   string
+	points []point
+}
+
+// Analyzer keeps a window of trades per market and venue and measures which
+// venue leads price moves. Venues quoting a market in different pegged pairs,
+// such as BTCUSDT and BTCUSD, are compared as one market; an exchange trading
+// it in several is represented by the first. Time comes from the trades
+// themselves, so it behaves the same fed live or from a recording.
+type Analyzer struct {
+	mu          sync.Mutex
+	config      exchangeconfig.LeadLagConfig
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	series      map[string]map[string]*venue // canonical pair -> exchange -> venue
+	latest      map[string]int64             // newest trade time by canonical pair
+	reports     map[string]Report            // by canonical pair
+}
+
+func NewAnalyzer(redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.LeadLagConfig) *Analyzer {
+	return &Analyzer{
+		config:      config.WithDefaults(),
+		redisClient: redisClient,
+		bus:         b,
+		series:      make(map[string]map[string]*venue),
+		latest:      make(map[string]int64),
+		reports:     make(map[string]Report),
+	}
+}
+
+// OnTrade adds a trade at its time on the configured clock. Trades without
+// that time are ignored.
+func (a *Analyzer) OnTrade(t models.Trade) {
+	at := t.Timestamp
+	if a.config.Clock == exchangeconfig.ClockReceived {
+		at = t.ReceivedAt
+	}
+	if at <= 0 || t.Price <= 0 {
+		return
+	}
+
+	canonical := models.CanonicalPair(t.Pair)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	venues, ok := a.series[canonical]
+	if !ok {
+		venues = make(map[string]*venue)
+		a.series[canonical] = venues
+	}
+	v, ok := venues[t.Exchange]
+	if !ok {
+		v = &venue{pair: t.Pair}
+		venues[t.Exchange] = v
+	}
+	if v.pair != t.Pair {
+		return
+	}
+	v.points = append(v.points, point{at: at, price: t.Price})
+	if at > a.latest[canonical] {
+		a.latest[canonical] = at
+	}
+
+	// Keep one resolution step before the window so its first price is known
+	cutoff := a.latest[canonical] - (a.config.Window.Duration + a.config.Resolution.Duration).Milliseconds()
+	n := 0
+	for n < len(v.points) && v.points[n].at < cutoff {
+		n++
+	}
+	if n > 0 {
+		v.points = append(v.points[:0], v.points[n:]...)
+	}
+}
+
+// Run analyzes every pair on the configured interval, then stores and
+// publishes the reports. It blocks, call it in a goroutine.
+func (a *Analyzer) Run() {
+	ticker := time.NewTicker(a.config.Interval.Duration)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, pair := range a.Pairs() {
+			report, ok := a.Analyze(pair)
+			if !ok {
+				continue
+			}
+			if a.bus != nil {
+				a.bus.Publish(bus.TopicLeadLag, report)
+			}
+			if a.redisClient != nil {
+				if err := a.redisClient.AppendToStream(Key(pair), report, a.config.MaxStored, 0); err != nil {
+					log.Printf("Could not store lead-lag report %s: %v", pair, err)
+				}
+			}
+		}
+	}
+}
+
+// Pairs returns every canonical pair quoted on at least two venues, sorted.
+func (a *Analyzer) Pairs() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var pairs []string
+	for pair, venues := range a.series {
+		if len(venues) >= 2 {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Current returns the latest report of the market a pair prices.
+func (a *Analyzer) Current(pair string) (Report, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	r, ok := a.reports[models.CanonicalPair(pair)]
+	return r, ok
+}
+
+// Analyze builds a report for the market a pair prices over the window ending
+// at its newest trade.
+func (a *Analyzer) Analyze(pair string) (Report, bool) {
+	pair = models.CanonicalPair(pair)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	resolution := a.config.Resolution.Duration.Milliseconds()
+	if resolution <= 0 {
+		resolution = 1
+	}
+	end := a.latest[pair]
+	start := end - a.config.Window.Duration.Milliseconds()
+	steps := int((end-start)/resolution) + 1
+
+	report := Report{
+		Pair:       pair,
+		Clock:      a.config.Clock,
+		Window:     average.WindowName(a.config.Window.Duration),
+		Resolution: a.config.Resolution.Duration.String(),
+		Start:      start,
+		End:        end,
+	}
+
+	prices := make(map[string][]float64)
+	report.VenuePairs = make(map[string]string)
+	for exchange, v := range a.series[pair] {
+		if len(v.points) < 2 {
+			continue
+		}
+		prices[exchange] = grid(v.points, start, resolution, steps)
+		report.Venues = append(report.Venues, exchange)
+		report.VenuePairs[exchange] = v.pair
+	}
+	if len(report.Venues) < 2 {
+		return Report{}, false
+	}
+	sort.Strings(report.Venues)
+
+	maxLag := int(a.config.MaxLag.Duration.Milliseconds() / resolution)
+	shareSum := make(map[string]float64)
+	shareCount := make(map[string]int)
+	for i, exchangeA := range report.Venues {
+		for _, exchangeB := range report.Venues[i+1:] {
+			stats := compare(prices[exchangeA], prices[exchangeB], maxLag, resolution)
+			stats.ExchangeA, stats.ExchangeB = exchangeA, exchangeB
+			report.Pairs = append(report.Pairs, stats)
+
+			if stats.Cointegrated {
+				shareSum[exchangeA] += stats.InformationShare.Mid
+				shareSum[exchangeB] += 1 - stats.InformationShare.Mid
+				shareCount[exchangeA]++
+				shareCount[exchangeB]++
+			}
+		}
+	}
+
+	best := -1.0
+	for _, exchange := range report.Venues {
+		if shareCount[exchange] == 0 {
+			continue
+		}
+		if mean := shareSum[exchange] / float64(shareCount[exchange]); mean > best {
+			best, report.Leader = mean, exchange
+		}
+	}
+
+	a.reports[pair] = report
+	return report, true
+}
+
+// grid samples the last traded log price at each step from start, NaN before
+// the first trade.
+func grid(points []point, start, resolution int64, steps int) []float64 {
+	sorted := append([]point(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].at < sorted[j].at })
+
+	prices := make([]float64, steps)
+	next, last := 0, math.NaN()
+	for k := range prices {
+		at := start + int64(k)*resolution
+		for next < len(sorted) && sorted[next].at <= at {
+			last = math.Log(sorted[next].price)
+			next++
+		}
+		prices[k] = last
+	}
+	return prices
+}
+
+func compare(a, b []float64, maxLag int, resolution int64) PairStats {
+	returnsA, returnsB := returns(a), returns(b)
+
+	var stats PairStats
+	stats.Correlation = math.Inf(-1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		c, n := correlation(returnsA, returnsB, lag)
+		if lag == 0 {
+			stats.Samples = n
+		}
+		stats.Correlations = append(stats.Correlations, LagCorrelation{LagMs: int64(lag) * resolution, Correlation: c})
+		// Prefer the shortest lag on ties, so flat series report no lead
+		if c > stats.Correlation || (c == stats.Correlation && abs(lag) < abs(int(stats.LeadMs/resolution))) {
+			stats.Correlation, stats.LeadMs = c, int64(lag)*resolution
+		}
+	}
+
+	alphaA, alphaB, omega, _ := vecm(a, b)
+	stats.ComponentShare, stats.InformationShare, stats.Cointegrated = shares(alphaA, alphaB, omega)
+	// Sampling noise can push a leader's share just past the ends
+	stats.ComponentShare = math.Max(0, math.Min(1, stats.ComponentShare))
+	return stats
+}
+
+func returns(prices []float64) []float64 {
+	r := make([]float64, len(prices))
+	r[0] = math.NaN()
+	for k := 1; k < len(prices); k++ {
+		r[k] = prices[k] - prices[k-1]
+	}
+	return r
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,98 @@
+package leadlag
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+)
+
+func TestGrid(t *testing.T) {
+	points := []point{{at: 250, price: math.E}, {at: 100, price: 1}}
+	prices := grid(points, 0, 100, 4)
+	if !math.IsNaN(prices[0]) || prices[1] != 0 || prices[2] != 0 || prices[3] != 1 {
+		t.Errorf("grid = %v, want [NaN 0 0 1]", prices)
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	a := []float64{math.NaN(), 1, 2, 3, 4}
+	b := []float64{0, 0, 1, 2, 3}
+	if c, n := correlation(a, b, 1); math.Abs(c-1) > 1e-9 || n != 3 {
+		t.Errorf("correlation at lag 1 = %v over %d, want 1 over 3", c, n)
+	}
+	if c, _ := correlation(a, []float64{1, 1, 1, 1, 1}, 0); c != 0 {
+		t.Errorf("correlation with a flat series = %v, want 0", c)
+	}
+}
+
+func TestShares(t *testing.T) {
+	// Only B adjusts, A carries all the information
+	component, information, ok := shares(0, 0.5, [2][2]float64{{1, 0}, {0, 1}})
+	if !ok || component != 1 || information.Low != 1 || information.High != 1 {
+		t.Errorf("shares = %v, %+v, %v, want A with every share", component, information, ok)
+	}
+	// Diverging prices aren't cointegrated
+	if _, _, ok := shares(0.5, 0, [2][2]float64{{1, 0}, {0, 1}}); ok {
+		t.Error("diverging prices reported as cointegrated")
+	}
+}
+
+func TestAnalyzerFindsLeader(t *testing.T) {
+	a := NewAnalyzer(nil, nil, exchangeconfig.LeadLagConfig{
+		Window:     exchangeconfig.Duration{Duration: time.Minute},
+		Resolution: exchangeconfig.Duration{Duration: 100 * time.Millisecond},
+		MaxLag:     exchangeconfig.Duration{Duration: time.Second},
+	})
+
+	// kraken follows binance's random walk 300ms later, quoting the market in USD
+	random := rand.New(rand.NewSource(1))
+	const lag = 3
+	prices := []float64{100}
+	for i := 1; i < 600+lag; i++ {
+		prices = append(prices, prices[i-1]*math.Exp(random.NormFloat64()*0.001))
+	}
+	for i := lag; i < len(prices); i++ {
+		at := int64(1_000_000 + i*100)
+		a.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: prices[i], Timestamp: at})
+		a.OnTrade(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: prices[i-lag], Timestamp: at})
+	}
+	// A single venue isn't analyzed
+	a.OnTrade(models.Trade{Exchange: "kraken", Pair: "ETHUSDT", Price: 1, Timestamp: 1_000_000})
+
+	if pairs := a.Pairs(); len(pairs) != 1 || pairs[0] != "BTCUSD" {
+		t.Fatalf("Pairs = %v, want BTCUSD only", pairs)
+	}
+	report, ok := a.Analyze("BTCUSDT")
+	if !ok || len(report.Pairs) != 1 || report.Pair != "BTCUSD" {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.VenuePairs["binance"] != "BTCUSDT" || report.VenuePairs["kraken"] != "BTCUSD" {
+		t.Errorf("venue pairs = %v", report.VenuePairs)
+	}
+	stats := report.Pairs[0]
+	if stats.ExchangeA != "binance" || stats.LeadMs != 300 || stats.Correlation < 0.99 {
+		t.Errorf("stats = %+v, want binance leading by 300ms", stats)
+	}
+	if !stats.Cointegrated || report.Leader != "binance" || stats.InformationShare.Mid < 0.5 {
+		t.Errorf("leader %q, share %+v, want binance", report.Leader, stats.InformationShare)
+	}
+	if current, ok := a.Current("BTCUSDT"); !ok || current.End != report.End {
+		t.Errorf("Current = %+v, want the last report", current)
+	}
+	if report.Window != "1m" || report.Clock != exchangeconfig.ClockExchange {
+		t.Errorf("report window %s clock %s", report.Window, report.Clock)
+	}
+}
+
+func TestAnalyzerClock(t *testing.T) {
+	a := NewAnalyzer(nil, nil, exchangeconfig.LeadLagConfig{Clock: exchangeconfig.ClockReceived})
+	a.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 1, Timestamp: 1000})
+	a.OnTrade(models.Trade{Exchange: "kraken", Pair: "BTCUSDT", Price: 1, Timestamp: 1000, ReceivedAt: 2000})
+	if pairs := a.Pairs(); len(pairs) != 0 {
+		t.Errorf("Pairs = %v, a trade without a receive time was kept", pairs)
+	}
+}
@@ -0,0 +1,134 @@
+package leadlag
+
+import "math"
+
+// Bounds are the lower and upper Hasbrouck information share of a venue from
+// the two orderings of the Cholesky factorisation, and their midpoint.
+type Bounds struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+	Mid  float64 `json:"mid"`
+}
+
+// correlation is the Pearson correlation of a[k] and b[k+lag] over every k
+// where both are defined (not NaN).
+func correlation(a, b []float64, lag int) (float64, int) {
+	var n, sumA, sumB, sumAA, sumBB, sumAB float64
+	for k := range a {
+		j := k + lag
+		if j < 0 || j >= len(b) || math.IsNaN(a[k]) || math.IsNaN(b[j]) {
+			continue
+		}
+		x, y := a[k], b[j]
+		n++
+		sumA += x
+		sumB += y
+		sumAA += x * x
+		sumBB += y * y
+		sumAB += x * y
+	}
+	if n < 2 {
+		return 0, int(n)
+	}
+	cov := sumAB - sumA*sumB/n
+	varA := sumAA - sumA*sumA/n
+	varB := sumBB - sumB*sumB/n
+	if varA <= 0 || varB <= 0 {
+		return 0, int(n)
+	}
+	return cov / math.Sqrt(varA*varB), int(n)
+}
+
+// vecm fits the error correction model of two cointegrated log price series
+//
+//	Δa[k] = c_a + α_a (a[k-1] - b[k-1]) + e_a[k]
+//	Δb[k] = c_b + α_b (a[k-1] - b[k-1]) + e_b[k]
+//
+// by least squares, without short-run lag terms, and returns the speeds of
+// adjustment and the residual covariance.
+func vecm(a, b []float64) (alphaA, alphaB float64, omega [2][2]float64, n int) {
+	var z, da, db []float64
+	for k := 1; k < len(a); k++ {
+		if math.IsNaN(a[k]) || math.IsNaN(a[k-1]) || math.IsNaN(b[k]) || math.IsNaN(b[k-1]) {
+			continue
+		}
+		z = append(z, a[k-1]-b[k-1])
+		da = append(da, a[k]-a[k-1])
+		db = append(db, b[k]-b[k-1])
+	}
+	n = len(z)
+	if n < 3 {
+		return 0, 0, omega, n
+	}
+
+	alphaA, interceptA := regress(z, da)
+	alphaB, interceptB := regress(z, db)
+	for i := range z {
+		ea := da[i] - interceptA - alphaA*z[i]
+		eb := db[i] - interceptB - alphaB*z[i]
+		omega[0][0] += ea * ea
+		omega[0][1] += ea * eb
+		omega[1][1] += eb * eb
+	}
+	for i := range omega {
+		for j := range omega[i] {
+			omega[i][j] /= float64(n - 2)
+		}
+	}
+	omega[1][0] = omega[0][1]
+	return alphaA, alphaB, omega, n
+}
+
+// regress returns the slope and intercept of y on x.
+func regress(x, y []float64) (slope, intercept float64) {
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	var cov, variance float64
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+		variance += (x[i] - meanX) * (x[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, meanY
+	}
+	slope = cov / variance
+	return slope, meanY - slope*meanX
+}
+
+// shares turns a fitted model into venue A's Gonzalo-Granger component share
+// and Hasbrouck information share bounds. ok is false when the prices don't
+// error-correct towards each other.
+func shares(alphaA, alphaB float64, omega [2][2]float64) (component float64, information Bounds, ok bool) {
+	// The common factor weights are orthogonal to the adjustment speeds. With
+	// z = a - b the gap only closes if b rises faster than a does
+	denominator := alphaB - alphaA
+	if denominator <= 0 {
+		return 0, Bounds{}, false
+	}
+	gammaA, gammaB := alphaB/denominator, -alphaA/denominator
+
+	total := gammaA*gammaA*omega[0][0] + 2*gammaA*gammaB*omega[0][1] + gammaB*gammaB*omega[1][1]
+	if total <= 0 || omega[0][0] <= 0 || omega[1][1] <= 0 {
+		return gammaA, Bounds{}, false
+	}
+
+	// A first in the Cholesky ordering gives A its upper bound
+	f11 := math.Sqrt(omega[0][0])
+	f21 := omega[0][1] / f11
+	aFirst := math.Pow(gammaA*f11+gammaB*f21, 2) / total
+
+	// B first gives B its upper bound, and A the rest
+	g11 := math.Sqrt(omega[1][1])
+	g21 := omega[0][1] / g11
+	bFirst := math.Pow(gammaB*g11+gammaA*g21, 2) / total
+
+	information = Bounds{Low: math.Min(aFirst, 1-bFirst), High: math.Max(aFirst, 1-bFirst)}
+	information.Mid = (information.Low + information.High) / 2
+	return gammaA, information, true
+}
@@ -0,0 +1,68 @@
+package market
+
+import "time"
+
+// BucketsPerWindow is how finely Buckets divide a window. The oldest bucket
+// drops out whole, so the window edge is accurate to window/BucketsPerWindow.
+const BucketsPerWindow = 300
+
+type slot[T any] struct {
+	index int64 // start time / resolution, -1 while unused
+	value T
+}
+
+// Buckets keeps per-interval state over a sliding time window in a ring of
+// fixed-width time buckets, so each update and query is bounded work
+// regardless of the event rate. A bucket's value starts as T's zero value.
+// It is not safe for concurrent use.
+type Buckets[T any] struct {
+	resolution time.Duration
+	slots      []slot[T]
+}
+
+func NewBuckets[T any](window time.Duration) *Buckets[T] {
+	resolution := window / BucketsPerWindow
+	if resolution <= 0 {
+		resolution = time.Millisecond
+	}
+	slots := make([]slot[T], BucketsPerWindow+1)
+	for i := range slots {
+		slots[i].index = -1
+	}
+	return &Buckets[T]{resolution: resolution, slots: slots}
+}
+
+func (b *Buckets[T]) index(at time.Time) int64 {
+	return at.UnixNano() / int64(b.resolution)
+}
+
+// At returns the bucket holding a time, emptying the slot when it still holds
+// an older bucket. Times must not go backwards by more than the window.
+func (b *Buckets[T]) At(at time.Time) *T {
+	index := b.index(at)
+	s := &b.slots[index%int64(len(b.slots))]
+	if s.index != index {
+		*s = slot[T]{index: index}
+	}
+	return &s.value
+}
+
+// Each calls fn with every bucket used inside the window ending at now, oldest first.
+func (b *Buckets[T]) Each(now time.Time, fn func(*T)) {
+	nowIndex := b.index(now)
+	for index := max(nowIndex-BucketsPerWindow+1, 0); index <= nowIndex; index++ {
+		if s := &b.slots[index%int64(len(b.slots))]; s.index == index {
+			fn(&s.value)
+		}
+	}
+}
+
+// Start returns the start of the oldest bucket inside the window ending at now.
+func (b *Buckets[T]) Start(now time.Time) time.Time {
+	return time.Unix(0, (b.index(now)-BucketsPerWindow+1)*int64(b.resolution))
+}
+
+// End returns the end of the bucket holding a time.
+func (b *Buckets[T]) End(at time.Time) time.Time {
+	return time.Unix(0, (b.index(at)+1)*int64(b.resolution))
+}
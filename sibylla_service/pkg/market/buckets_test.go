@@ -0,0 +1,55 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func sumBuckets(b *Buckets[float64], now time.Time) (sum float64, n int) {
+	b.Each(now, func(v *float64) {
+		sum += *v
+		n++
+	})
+	return sum, n
+}
+
+func TestBucketsWindow(t *testing.T) {
+	b := NewBuckets[float64](time.Minute)
+	t0 := time.Unix(1000, 0)
+	*b.At(t0) += 1
+	*b.At(t0.Add(100 * time.Millisecond)) += 2 // same 200ms bucket
+	*b.At(t0.Add(30 * time.Second)) += 4
+
+	if sum, n := sumBuckets(b, t0.Add(40*time.Second)); sum != 7 || n != 2 {
+		t.Errorf("sum = %v over %d buckets, want 7 over 2", sum, n)
+	}
+	// The first bucket has left the window
+	if sum, _ := sumBuckets(b, t0.Add(time.Minute)); sum != 4 {
+		t.Errorf("sum after a minute = %v, want 4", sum)
+	}
+	if start := b.Start(t0.Add(time.Minute)); !start.Equal(t0.Add(200 * time.Millisecond)) {
+		t.Errorf("Start = %v, want the bucket after t0", start)
+	}
+	if end := b.End(t0.Add(50 * time.Millisecond)); !end.Equal(t0.Add(200 * time.Millisecond)) {
+		t.Errorf("End = %v", end)
+	}
+}
+
+func TestBucketsReuseSlots(t *testing.T) {
+	b := NewBuckets[float64](time.Minute)
+	t0 := time.Unix(1000, 0)
+	*b.At(t0) += 1
+
+	// A full ring later the slot is emptied before reuse
+	later := t0.Add(time.Duration(BucketsPerWindow+1) * 200 * time.Millisecond)
+	if v := b.At(later); *v != 0 {
+		t.Errorf("reused bucket holds %v, want 0", *v)
+	}
+}
+
+func TestBucketsUnusedAtEpoch(t *testing.T) {
+	b := NewBuckets[float64](time.Minute)
+	if _, n := sumBuckets(b, time.Unix(0, 0)); n != 0 {
+		t.Errorf("got %d buckets before any was used", n)
+	}
+}
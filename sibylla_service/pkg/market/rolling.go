@@ -0,0 +1,55 @@
+package market
+
+import "time"
+
+type point struct {
+	at    time.Time
+	value float64
+}
+
+// RollingSum is the sum of values added within a sliding time window.
+// It is not safe for concurrent use.
+type RollingSum struct {
+	window time.Duration
+	points []point
+	sum    float64
+}
+
+func NewRollingSum(window time.Duration) *RollingSum {
+	return &RollingSum{window: window}
+}
+
+// Add records a value at the given time.
+func (r *RollingSum) Add(at time.Time, value float64) {
+	r.points = append(r.points, point{at: at, value: value})
+	r.sum += value
+	r.expire(at)
+}
+
+// Sum returns the total of the values inside the window ending at now.
+func (r *RollingSum) Sum(now time.Time) float64 {
+	r.expire(now)
+	if len(r.points) == 0 {
+		// Reset to avoid float drift accumulating across windows
+		r.sum = 0
+	}
+	return r.sum
+}
+
+// Len returns the number of values inside the window.
+func (r *RollingSum) Len(now time.Time) int {
+	r.expire(now)
+	return len(r.points)
+}
+
+func (r *RollingSum) expire(now time.Time) {
+	cutoff := now.Add(-r.window)
+	n := 0
+	for n < len(r.points) && r.points[n].at.Before(cutoff) {
+		r.sum -= r.points[n].value
+		n++
+	}
+	if n > 0 {
+		r.points = append(r.points[:0], r.points[n:]...)
+	}
+}
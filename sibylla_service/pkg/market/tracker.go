@@ -0,0 +1,126 @@
+package market
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/models"
+)
+
+// Quote is the latest observed price for an instrument on one exchange.
+// Price is the last trade price; the adapters don't subscribe to order books.
+type Quote struct {
+	Exchange  string    `json:"exchange"`
+	Pair      string    `json:"pair"`
+	Price     float64   `json:"price"`
+	Quantity  float64   `json:"quantity"`
+	Timestamp int64     `json:"timestamp"` // exchange trade time, Unix ms
+	Received  time.Time `json:"received"`
+}
+
+// Age returns how long ago the quote was received.
+func (q Quote) Age(now time.Time) time.Duration {
+	return now.Sub(q.Received)
+}
+
+// Tracker keeps the latest quote for every exchange and pair.
+type Tracker struct {
+	mu      sync.RWMutex
+	pairs   map[string]map[string]Quote // pair -> exchange -> quote
+	markets map[string][]string         // canonical pair -> pairs pricing it
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		pairs:   make(map[string]map[string]Quote),
+		markets: make(map[string][]string),
+	}
+}
+
+// Update records a trade as the latest quote for its exchange and pair.
+func (t *Tracker) Update(tr models.Trade) {
+	if tr.Price <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	venues, ok := t.pairs[tr.Pair]
+	if !ok {
+		venues = make(map[string]Quote)
+		t.pairs[tr.Pair] = venues
+		market := models.CanonicalPair(tr.Pair)
+		t.markets[market] = append(t.markets[market], tr.Pair)
+	}
+	venues[tr.Exchange] = Quote{
+		Exchange:  tr.Exchange,
+		Pair:      tr.Pair,
+		Price:     tr.Price,
+		Quantity:  tr.Quantity,
+		Timestamp: tr.Timestamp,
+		Received:  time.Now(),
+	}
+}
+
+// Last returns the latest quote for an exchange and pair.
+func (t *Tracker) Last(exchange, pair string) (Quote, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	q, ok := t.pairs[pair][exchange]
+	return q, ok
+}
+
+// Venues returns the latest quote on every exchange for a pair, sorted by exchange.
+func (t *Tracker) Venues(pair string) []Quote {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	quotes := make([]Quote, 0, len(t.pairs[pair]))
+	for _, q := range t.pairs[pair] {
+		quotes = append(quotes, q)
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].Exchange < quotes[j].Exchange })
+	return quotes
+}
+
+// Market returns the latest quote on every exchange for the market a pair
+// prices (see models.CanonicalPair), sorted by exchange. An exchange quoting
+// the pair itself is represented by that quote, otherwise by the most recently
+// received quote of an equivalent pair, e.g. BTCUSD for BTCUSDT.
+func (t *Tracker) Market(pair string) []Quote {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	best := make(map[string]Quote)
+	for _, p := range t.markets[models.CanonicalPair(pair)] {
+		for exchange, q := range t.pairs[p] {
+			current, ok := best[exchange]
+			if !ok || current.Pair != pair && (q.Pair == pair || q.Received.After(current.Received)) {
+				best[exchange] = q
+			}
+		}
+	}
+
+	quotes := make([]Quote, 0, len(best))
+	for _, q := range best {
+		quotes = append(quotes, q)
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].Exchange < quotes[j].Exchange })
+	return quotes
+}
+
+// Pairs returns every pair with at least one quote, sorted.
+func (t *Tracker) Pairs() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pairs := make([]string, 0, len(t.pairs))
+	for pair := range t.pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
@@ -0,0 +1,35 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	"sibylla_service/pkg/models"
+)
+
+func TestMarketMatchesPeggedQuotes(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 101})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "ETHUSDT", Price: 5})
+
+	quotes := tracker.Market("BTCUSDT")
+	if len(quotes) != 2 || quotes[0].Pair != "BTCUSDT" || quotes[1].Pair != "BTCUSD" {
+		t.Fatalf("unexpected quotes %+v", quotes)
+	}
+}
+
+func TestMarketPrefersSamePair(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 101})
+	time.Sleep(time.Millisecond)
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSDT", Price: 100})
+
+	// kraken quotes both, the asked pair wins even when older
+	if quotes := tracker.Market("BTCUSD"); len(quotes) != 1 || quotes[0].Pair != "BTCUSD" {
+		t.Errorf("Market(BTCUSD) = %+v, want kraken's BTCUSD quote", quotes)
+	}
+	if quotes := tracker.Market("BTCUSDT"); len(quotes) != 1 || quotes[0].Pair != "BTCUSDT" {
+		t.Errorf("Market(BTCUSDT) = %+v, want kraken's BTCUSDT quote", quotes)
+	}
+}
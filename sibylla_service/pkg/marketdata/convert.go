@@ -0,0 +1,99 @@
+package marketdata
+
+import (
+	"sibylla_service/pkg/candles"
+	"sibylla_service/pkg/index"
+	"sibylla_service/pkg/marketdata/marketdatapb"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/spread"
+)
+
+// TradeToProto converts a trade to its protobuf message.
+func TradeToProto(t models.Trade) *marketdatapb.Trade {
+	return &marketdatapb.Trade{
+		Exchange:     t.Exchange,
+		Pair:         t.Pair,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		Timestamp:    t.Timestamp,
+		IsBuyerMaker: t.IsBuyerMaker,
+		ReceivedAt:   t.ReceivedAt,
+	}
+}
+
+// TradeFromProto converts a protobuf trade message back to a trade.
+func TradeFromProto(t *marketdatapb.Trade) models.Trade {
+	return models.Trade{
+		Exchange:     t.GetExchange(),
+		Pair:         t.GetPair(),
+		Price:        t.GetPrice(),
+		Quantity:     t.GetQuantity(),
+		Timestamp:    t.GetTimestamp(),
+		IsBuyerMaker: t.GetIsBuyerMaker(),
+		ReceivedAt:   t.GetReceivedAt(),
+	}
+}
+
+func candleToProto(c candles.Candle) *marketdatapb.Candle {
+	return &marketdatapb.Candle{
+		Exchange:   c.Exchange,
+		Pair:       c.Pair,
+		Interval:   c.Interval,
+		OpenTime:   c.OpenTime,
+		CloseTime:  c.CloseTime,
+		Open:       c.Open,
+		High:       c.High,
+		Low:        c.Low,
+		Close:      c.Close,
+		Volume:     c.Volume,
+		BuyVolume:  c.BuyVolume,
+		SellVolume: c.SellVolume,
+		Trades:     c.Trades,
+		Closed:     c.Closed,
+	}
+}
+
+func indexToProto(p index.Price) *marketdatapb.IndexPrice {
+	result := &marketdatapb.IndexPrice{
+		Pair:          p.Pair,
+		Price:         p.Price,
+		Valid:         p.Valid,
+		Low:           p.Low,
+		High:          p.High,
+		ConfidenceBps: p.ConfidenceBps,
+		Timestamp:     p.Timestamp,
+	}
+	for _, c := range p.Contributors {
+		result.Contributors = append(result.Contributors, &marketdatapb.IndexPrice_Contributor{
+			Exchange:     c.Exchange,
+			Pair:         c.Pair,
+			Price:        c.Price,
+			Weight:       c.Weight,
+			DeviationBps: c.DeviationBps,
+		})
+	}
+	for _, e := range p.Excluded {
+		result.Excluded = append(result.Excluded, &marketdatapb.IndexPrice_Excluded{
+			Exchange: e.Exchange,
+			Pair:     e.Pair,
+			Price:    e.Price,
+			Reason:   e.Reason,
+		})
+	}
+	return result
+}
+
+func spreadToProto(s spread.Spread) *marketdatapb.Spread {
+	return &marketdatapb.Spread{
+		Pair:      s.Pair,
+		ExchangeA: s.ExchangeA,
+		ExchangeB: s.ExchangeB,
+		PriceA:    s.PriceA,
+		PriceB:    s.PriceB,
+		Absolute:  s.Absolute,
+		Bps:       s.Bps,
+		Timestamp: s.Timestamp,
+		PairA:     s.PairA,
+		PairB:     s.PairB,
+	}
+}
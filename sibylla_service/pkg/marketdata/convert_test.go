@@ -0,0 +1,37 @@
+package marketdata
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"sibylla_service/pkg/marketdata/marketdatapb"
+	"sibylla_service/pkg/models"
+)
+
+func TestTradeProtoRoundTrip(t *testing.T) {
+	trades := []models.Trade{
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 64123.45, Quantity: 0.015, Timestamp: 1718000000123, IsBuyerMaker: true, ReceivedAt: 1718000000150},
+		{Exchange: "kraken", Pair: "ETHUSD", Price: 3500, Quantity: 2},
+		{},
+	}
+	for _, want := range trades {
+		// Through the wire format too, as a client sees it
+		data, err := proto.Marshal(TradeToProto(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded marketdatapb.Trade
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := TradeFromProto(&decoded); got != want {
+			t.Errorf("round trip of %+v gave %+v", want, got)
+		}
+	}
+
+	// A nil message reads as the zero trade
+	if got := TradeFromProto(nil); got != (models.Trade{}) {
+		t.Errorf("TradeFromProto(nil) = %+v", got)
+	}
+}
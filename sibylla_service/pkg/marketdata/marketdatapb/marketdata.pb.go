@@ -0,0 +1,1394 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: marketdata/v1/marketdata.proto
+
+package marketdatapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Trade is a single trade on an exchange, as models.Trade.
+type Trade struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Exchange string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Pair     string                 `protobuf:"bytes,2,opt,name=pair,proto3" json:"pair,omitempty"`
+	Price    float64                `protobuf:"fixed64,3,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity float64                `protobuf:"fixed64,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	// Exchange trade time
+	Timestamp    int64 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	IsBuyerMaker bool  `protobuf:"varint,6,opt,name=is_buyer_maker,json=isBuyerMaker,proto3" json:"is_buyer_maker,omitempty"`
+	// Local receive time, zero if unknown
+	ReceivedAt    int64 `protobuf:"varint,7,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trade) Reset() {
+	*x = Trade{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trade) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trade) ProtoMessage() {}
+
+func (x *Trade) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trade.ProtoReflect.Descriptor instead.
+func (*Trade) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Trade) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *Trade) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+func (x *Trade) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Trade) GetQuantity() float64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Trade) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Trade) GetIsBuyerMaker() bool {
+	if x != nil {
+		return x.IsBuyerMaker
+	}
+	return false
+}
+
+func (x *Trade) GetReceivedAt() int64 {
+	if x != nil {
+		return x.ReceivedAt
+	}
+	return 0
+}
+
+// Candle is an OHLCV summary of the trades in one interval. close_time is
+// exclusive.
+type Candle struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exchange      string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Pair          string                 `protobuf:"bytes,2,opt,name=pair,proto3" json:"pair,omitempty"`
+	Interval      string                 `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	OpenTime      int64                  `protobuf:"varint,4,opt,name=open_time,json=openTime,proto3" json:"open_time,omitempty"`
+	CloseTime     int64                  `protobuf:"varint,5,opt,name=close_time,json=closeTime,proto3" json:"close_time,omitempty"`
+	Open          float64                `protobuf:"fixed64,6,opt,name=open,proto3" json:"open,omitempty"`
+	High          float64                `protobuf:"fixed64,7,opt,name=high,proto3" json:"high,omitempty"`
+	Low           float64                `protobuf:"fixed64,8,opt,name=low,proto3" json:"low,omitempty"`
+	Close         float64                `protobuf:"fixed64,9,opt,name=close,proto3" json:"close,omitempty"`
+	Volume        float64                `protobuf:"fixed64,10,opt,name=volume,proto3" json:"volume,omitempty"`
+	BuyVolume     float64                `protobuf:"fixed64,11,opt,name=buy_volume,json=buyVolume,proto3" json:"buy_volume,omitempty"`
+	SellVolume    float64                `protobuf:"fixed64,12,opt,name=sell_volume,json=sellVolume,proto3" json:"sell_volume,omitempty"`
+	Trades        int64                  `protobuf:"varint,13,opt,name=trades,proto3" json:"trades,omitempty"`
+	Closed        bool                   `protobuf:"varint,14,opt,name=closed,proto3" json:"closed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Candle) Reset() {
+	*x = Candle{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Candle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Candle) ProtoMessage() {}
+
+func (x *Candle) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Candle.ProtoReflect.Descriptor instead.
+func (*Candle) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Candle) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *Candle) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+func (x *Candle) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *Candle) GetOpenTime() int64 {
+	if x != nil {
+		return x.OpenTime
+	}
+	return 0
+}
+
+func (x *Candle) GetCloseTime() int64 {
+	if x != nil {
+		return x.CloseTime
+	}
+	return 0
+}
+
+func (x *Candle) GetOpen() float64 {
+	if x != nil {
+		return x.Open
+	}
+	return 0
+}
+
+func (x *Candle) GetHigh() float64 {
+	if x != nil {
+		return x.High
+	}
+	return 0
+}
+
+func (x *Candle) GetLow() float64 {
+	if x != nil {
+		return x.Low
+	}
+	return 0
+}
+
+func (x *Candle) GetClose() float64 {
+	if x != nil {
+		return x.Close
+	}
+	return 0
+}
+
+func (x *Candle) GetVolume() float64 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Candle) GetBuyVolume() float64 {
+	if x != nil {
+		return x.BuyVolume
+	}
+	return 0
+}
+
+func (x *Candle) GetSellVolume() float64 {
+	if x != nil {
+		return x.SellVolume
+	}
+	return 0
+}
+
+func (x *Candle) GetTrades() int64 {
+	if x != nil {
+		return x.Trades
+	}
+	return 0
+}
+
+func (x *Candle) GetClosed() bool {
+	if x != nil {
+		return x.Closed
+	}
+	return false
+}
+
+// IndexPrice is the volume-weighted median price of a pair across venues.
+// valid is false when too few venues contributed.
+type IndexPrice struct {
+	state         protoimpl.MessageState    `protogen:"open.v1"`
+	Pair          string                    `protobuf:"bytes,1,opt,name=pair,proto3" json:"pair,omitempty"`
+	Price         float64                   `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	Valid         bool                      `protobuf:"varint,3,opt,name=valid,proto3" json:"valid,omitempty"`
+	Low           float64                   `protobuf:"fixed64,4,opt,name=low,proto3" json:"low,omitempty"`
+	High          float64                   `protobuf:"fixed64,5,opt,name=high,proto3" json:"high,omitempty"`
+	ConfidenceBps float64                   `protobuf:"fixed64,6,opt,name=confidence_bps,json=confidenceBps,proto3" json:"confidence_bps,omitempty"`
+	Contributors  []*IndexPrice_Contributor `protobuf:"bytes,7,rep,name=contributors,proto3" json:"contributors,omitempty"`
+	Excluded      []*IndexPrice_Excluded    `protobuf:"bytes,8,rep,name=excluded,proto3" json:"excluded,omitempty"`
+	Timestamp     int64                     `protobuf:"varint,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexPrice) Reset() {
+	*x = IndexPrice{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexPrice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexPrice) ProtoMessage() {}
+
+func (x *IndexPrice) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexPrice.ProtoReflect.Descriptor instead.
+func (*IndexPrice) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IndexPrice) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+func (x *IndexPrice) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *IndexPrice) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *IndexPrice) GetLow() float64 {
+	if x != nil {
+		return x.Low
+	}
+	return 0
+}
+
+func (x *IndexPrice) GetHigh() float64 {
+	if x != nil {
+		return x.High
+	}
+	return 0
+}
+
+func (x *IndexPrice) GetConfidenceBps() float64 {
+	if x != nil {
+		return x.ConfidenceBps
+	}
+	return 0
+}
+
+func (x *IndexPrice) GetContributors() []*IndexPrice_Contributor {
+	if x != nil {
+		return x.Contributors
+	}
+	return nil
+}
+
+func (x *IndexPrice) GetExcluded() []*IndexPrice_Excluded {
+	if x != nil {
+		return x.Excluded
+	}
+	return nil
+}
+
+func (x *IndexPrice) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// Spread is the price difference of a pair between two venues.
+type Spread struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// market both venues price, with pegged quotes mapped to their equivalent
+	Pair      string  `protobuf:"bytes,1,opt,name=pair,proto3" json:"pair,omitempty"`
+	ExchangeA string  `protobuf:"bytes,2,opt,name=exchange_a,json=exchangeA,proto3" json:"exchange_a,omitempty"`
+	ExchangeB string  `protobuf:"bytes,3,opt,name=exchange_b,json=exchangeB,proto3" json:"exchange_b,omitempty"`
+	PriceA    float64 `protobuf:"fixed64,4,opt,name=price_a,json=priceA,proto3" json:"price_a,omitempty"`
+	PriceB    float64 `protobuf:"fixed64,5,opt,name=price_b,json=priceB,proto3" json:"price_b,omitempty"`
+	Absolute  float64 `protobuf:"fixed64,6,opt,name=absolute,proto3" json:"absolute,omitempty"`
+	// absolute relative to the mid of the two prices
+	Bps       float64 `protobuf:"fixed64,7,opt,name=bps,proto3" json:"bps,omitempty"`
+	Timestamp int64   `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// pairs each venue quotes the market in, e.g. BTCUSDT and BTCUSD
+	PairA         string `protobuf:"bytes,9,opt,name=pair_a,json=pairA,proto3" json:"pair_a,omitempty"`
+	PairB         string `protobuf:"bytes,10,opt,name=pair_b,json=pairB,proto3" json:"pair_b,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Spread) Reset() {
+	*x = Spread{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Spread) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Spread) ProtoMessage() {}
+
+func (x *Spread) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Spread.ProtoReflect.Descriptor instead.
+func (*Spread) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Spread) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+func (x *Spread) GetExchangeA() string {
+	if x != nil {
+		return x.ExchangeA
+	}
+	return ""
+}
+
+func (x *Spread) GetExchangeB() string {
+	if x != nil {
+		return x.ExchangeB
+	}
+	return ""
+}
+
+func (x *Spread) GetPriceA() float64 {
+	if x != nil {
+		return x.PriceA
+	}
+	return 0
+}
+
+func (x *Spread) GetPriceB() float64 {
+	if x != nil {
+		return x.PriceB
+	}
+	return 0
+}
+
+func (x *Spread) GetAbsolute() float64 {
+	if x != nil {
+		return x.Absolute
+	}
+	return 0
+}
+
+func (x *Spread) GetBps() float64 {
+	if x != nil {
+		return x.Bps
+	}
+	return 0
+}
+
+func (x *Spread) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Spread) GetPairA() string {
+	if x != nil {
+		return x.PairA
+	}
+	return ""
+}
+
+func (x *Spread) GetPairB() string {
+	if x != nil {
+		return x.PairB
+	}
+	return ""
+}
+
+// Instrument is a market with stored trades.
+type Instrument struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exchange      string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Pair          string                 `protobuf:"bytes,2,opt,name=pair,proto3" json:"pair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Instrument) Reset() {
+	*x = Instrument{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Instrument) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Instrument) ProtoMessage() {}
+
+func (x *Instrument) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Instrument.ProtoReflect.Descriptor instead.
+func (*Instrument) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Instrument) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *Instrument) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+type GetLatestTradeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exchange      string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Pair          string                 `protobuf:"bytes,2,opt,name=pair,proto3" json:"pair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLatestTradeRequest) Reset() {
+	*x = GetLatestTradeRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLatestTradeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLatestTradeRequest) ProtoMessage() {}
+
+func (x *GetLatestTradeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLatestTradeRequest.ProtoReflect.Descriptor instead.
+func (*GetLatestTradeRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetLatestTradeRequest) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *GetLatestTradeRequest) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+type GetLatestTradeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trade         *Trade                 `protobuf:"bytes,1,opt,name=trade,proto3" json:"trade,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLatestTradeResponse) Reset() {
+	*x = GetLatestTradeResponse{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLatestTradeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLatestTradeResponse) ProtoMessage() {}
+
+func (x *GetLatestTradeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLatestTradeResponse.ProtoReflect.Descriptor instead.
+func (*GetLatestTradeResponse) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetLatestTradeResponse) GetTrade() *Trade {
+	if x != nil {
+		return x.Trade
+	}
+	return nil
+}
+
+type GetCandlesRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Exchange string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Pair     string                 `protobuf:"bytes,2,opt,name=pair,proto3" json:"pair,omitempty"`
+	// Defaults to 1m
+	Interval string `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	// Closed candles to return, defaults to 100
+	Limit         int64 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCandlesRequest) Reset() {
+	*x = GetCandlesRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCandlesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCandlesRequest) ProtoMessage() {}
+
+func (x *GetCandlesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCandlesRequest.ProtoReflect.Descriptor instead.
+func (*GetCandlesRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetCandlesRequest) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetCandlesResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Candles []*Candle              `protobuf:"bytes,1,rep,name=candles,proto3" json:"candles,omitempty"`
+	// Unset when no trade has fallen in the current interval
+	Current       *Candle `protobuf:"bytes,2,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCandlesResponse) Reset() {
+	*x = GetCandlesResponse{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCandlesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCandlesResponse) ProtoMessage() {}
+
+func (x *GetCandlesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCandlesResponse.ProtoReflect.Descriptor instead.
+func (*GetCandlesResponse) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetCandlesResponse) GetCandles() []*Candle {
+	if x != nil {
+		return x.Candles
+	}
+	return nil
+}
+
+func (x *GetCandlesResponse) GetCurrent() *Candle {
+	if x != nil {
+		return x.Current
+	}
+	return nil
+}
+
+type GetIndexPriceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pair          string                 `protobuf:"bytes,1,opt,name=pair,proto3" json:"pair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIndexPriceRequest) Reset() {
+	*x = GetIndexPriceRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIndexPriceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIndexPriceRequest) ProtoMessage() {}
+
+func (x *GetIndexPriceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIndexPriceRequest.ProtoReflect.Descriptor instead.
+func (*GetIndexPriceRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetIndexPriceRequest) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+type GetIndexPriceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         *IndexPrice            `protobuf:"bytes,1,opt,name=index,proto3" json:"index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIndexPriceResponse) Reset() {
+	*x = GetIndexPriceResponse{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIndexPriceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIndexPriceResponse) ProtoMessage() {}
+
+func (x *GetIndexPriceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIndexPriceResponse.ProtoReflect.Descriptor instead.
+func (*GetIndexPriceResponse) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetIndexPriceResponse) GetIndex() *IndexPrice {
+	if x != nil {
+		return x.Index
+	}
+	return nil
+}
+
+type ListInstrumentsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list this exchange's instruments when set
+	Exchange      string `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListInstrumentsRequest) Reset() {
+	*x = ListInstrumentsRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListInstrumentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInstrumentsRequest) ProtoMessage() {}
+
+func (x *ListInstrumentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInstrumentsRequest.ProtoReflect.Descriptor instead.
+func (*ListInstrumentsRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListInstrumentsRequest) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+type ListInstrumentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Instruments   []*Instrument          `protobuf:"bytes,1,rep,name=instruments,proto3" json:"instruments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListInstrumentsResponse) Reset() {
+	*x = ListInstrumentsResponse{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListInstrumentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInstrumentsResponse) ProtoMessage() {}
+
+func (x *ListInstrumentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInstrumentsResponse.ProtoReflect.Descriptor instead.
+func (*ListInstrumentsResponse) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListInstrumentsResponse) GetInstruments() []*Instrument {
+	if x != nil {
+		return x.Instruments
+	}
+	return nil
+}
+
+// Empty filters match everything.
+type StreamTradesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exchanges     []string               `protobuf:"bytes,1,rep,name=exchanges,proto3" json:"exchanges,omitempty"`
+	Pairs         []string               `protobuf:"bytes,2,rep,name=pairs,proto3" json:"pairs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTradesRequest) Reset() {
+	*x = StreamTradesRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTradesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTradesRequest) ProtoMessage() {}
+
+func (x *StreamTradesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTradesRequest.ProtoReflect.Descriptor instead.
+func (*StreamTradesRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StreamTradesRequest) GetExchanges() []string {
+	if x != nil {
+		return x.Exchanges
+	}
+	return nil
+}
+
+func (x *StreamTradesRequest) GetPairs() []string {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+// Empty filters match everything. A spread matches an exchange filter when
+// either of its venues is listed.
+type StreamSpreadsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exchanges     []string               `protobuf:"bytes,1,rep,name=exchanges,proto3" json:"exchanges,omitempty"`
+	Pairs         []string               `protobuf:"bytes,2,rep,name=pairs,proto3" json:"pairs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamSpreadsRequest) Reset() {
+	*x = StreamSpreadsRequest{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamSpreadsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamSpreadsRequest) ProtoMessage() {}
+
+func (x *StreamSpreadsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamSpreadsRequest.ProtoReflect.Descriptor instead.
+func (*StreamSpreadsRequest) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StreamSpreadsRequest) GetExchanges() []string {
+	if x != nil {
+		return x.Exchanges
+	}
+	return nil
+}
+
+func (x *StreamSpreadsRequest) GetPairs() []string {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+type IndexPrice_Contributor struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Exchange string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Price    float64                `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	// Share of the total weight, 0..1
+	Weight       float64 `protobuf:"fixed64,3,opt,name=weight,proto3" json:"weight,omitempty"`
+	DeviationBps float64 `protobuf:"fixed64,4,opt,name=deviation_bps,json=deviationBps,proto3" json:"deviation_bps,omitempty"`
+	// pair the venue quotes the market in
+	Pair          string `protobuf:"bytes,5,opt,name=pair,proto3" json:"pair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexPrice_Contributor) Reset() {
+	*x = IndexPrice_Contributor{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexPrice_Contributor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexPrice_Contributor) ProtoMessage() {}
+
+func (x *IndexPrice_Contributor) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexPrice_Contributor.ProtoReflect.Descriptor instead.
+func (*IndexPrice_Contributor) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{2, 0}
+}
+
+func (x *IndexPrice_Contributor) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *IndexPrice_Contributor) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *IndexPrice_Contributor) GetWeight() float64 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *IndexPrice_Contributor) GetDeviationBps() float64 {
+	if x != nil {
+		return x.DeviationBps
+	}
+	return 0
+}
+
+func (x *IndexPrice_Contributor) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+type IndexPrice_Excluded struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Exchange string                 `protobuf:"bytes,1,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Price    float64                `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	// stale or outlier
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	// pair the venue quotes the market in
+	Pair          string `protobuf:"bytes,4,opt,name=pair,proto3" json:"pair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexPrice_Excluded) Reset() {
+	*x = IndexPrice_Excluded{}
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexPrice_Excluded) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexPrice_Excluded) ProtoMessage() {}
+
+func (x *IndexPrice_Excluded) ProtoReflect() protoreflect.Message {
+	mi := &file_marketdata_v1_marketdata_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexPrice_Excluded.ProtoReflect.Descriptor instead.
+func (*IndexPrice_Excluded) Descriptor() ([]byte, []int) {
+	return file_marketdata_v1_marketdata_proto_rawDescGZIP(), []int{2, 1}
+}
+
+func (x *IndexPrice_Excluded) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *IndexPrice_Excluded) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *IndexPrice_Excluded) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *IndexPrice_Excluded) GetPair() string {
+	if x != nil {
+		return x.Pair
+	}
+	return ""
+}
+
+var File_marketdata_v1_marketdata_proto protoreflect.FileDescriptor
+
+const file_marketdata_v1_marketdata_proto_rawDesc = "" +
+	"\n" +
+	"\x1emarketdata/v1/marketdata.proto\x12\x15sibylla.marketdata.v1\"\xce\x01\n" +
+	"\x05Trade\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x12\n" +
+	"\x04pair\x18\x02 \x01(\tR\x04pair\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x01R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x04 \x01(\x01R\bquantity\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12$\n" +
+	"\x0eis_buyer_maker\x18\x06 \x01(\bR\fisBuyerMaker\x12\x1f\n" +
+	"\vreceived_at\x18\a \x01(\x03R\n" +
+	"receivedAt\"\xe8\x02\n" +
+	"\x06Candle\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x12\n" +
+	"\x04pair\x18\x02 \x01(\tR\x04pair\x12\x1a\n" +
+	"\binterval\x18\x03 \x01(\tR\binterval\x12\x1b\n" +
+	"\topen_time\x18\x04 \x01(\x03R\bopenTime\x12\x1d\n" +
+	"\n" +
+	"close_time\x18\x05 \x01(\x03R\tcloseTime\x12\x12\n" +
+	"\x04open\x18\x06 \x01(\x01R\x04open\x12\x12\n" +
+	"\x04high\x18\a \x01(\x01R\x04high\x12\x10\n" +
+	"\x03low\x18\b \x01(\x01R\x03low\x12\x14\n" +
+	"\x05close\x18\t \x01(\x01R\x05close\x12\x16\n" +
+	"\x06volume\x18\n" +
+	" \x01(\x01R\x06volume\x12\x1d\n" +
+	"\n" +
+	"buy_volume\x18\v \x01(\x01R\tbuyVolume\x12\x1f\n" +
+	"\vsell_volume\x18\f \x01(\x01R\n" +
+	"sellVolume\x12\x16\n" +
+	"\x06trades\x18\r \x01(\x03R\x06trades\x12\x16\n" +
+	"\x06closed\x18\x0e \x01(\bR\x06closed\"\xcf\x04\n" +
+	"\n" +
+	"IndexPrice\x12\x12\n" +
+	"\x04pair\x18\x01 \x01(\tR\x04pair\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x01R\x05price\x12\x14\n" +
+	"\x05valid\x18\x03 \x01(\bR\x05valid\x12\x10\n" +
+	"\x03low\x18\x04 \x01(\x01R\x03low\x12\x12\n" +
+	"\x04high\x18\x05 \x01(\x01R\x04high\x12%\n" +
+	"\x0econfidence_bps\x18\x06 \x01(\x01R\rconfidenceBps\x12Q\n" +
+	"\fcontributors\x18\a \x03(\v2-.sibylla.marketdata.v1.IndexPrice.ContributorR\fcontributors\x12F\n" +
+	"\bexcluded\x18\b \x03(\v2*.sibylla.marketdata.v1.IndexPrice.ExcludedR\bexcluded\x12\x1c\n" +
+	"\ttimestamp\x18\t \x01(\x03R\ttimestamp\x1a\x90\x01\n" +
+	"\vContributor\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x01R\x05price\x12\x16\n" +
+	"\x06weight\x18\x03 \x01(\x01R\x06weight\x12#\n" +
+	"\rdeviation_bps\x18\x04 \x01(\x01R\fdeviationBps\x12\x12\n" +
+	"\x04pair\x18\x05 \x01(\tR\x04pair\x1ah\n" +
+	"\bExcluded\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x01R\x05price\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x12\n" +
+	"\x04pair\x18\x04 \x01(\tR\x04pair\"\x86\x02\n" +
+	"\x06Spread\x12\x12\n" +
+	"\x04pair\x18\x01 \x01(\tR\x04pair\x12\x1d\n" +
+	"\n" +
+	"exchange_a\x18\x02 \x01(\tR\texchangeA\x12\x1d\n" +
+	"\n" +
+	"exchange_b\x18\x03 \x01(\tR\texchangeB\x12\x17\n" +
+	"\aprice_a\x18\x04 \x01(\x01R\x06priceA\x12\x17\n" +
+	"\aprice_b\x18\x05 \x01(\x01R\x06priceB\x12\x1a\n" +
+	"\babsolute\x18\x06 \x01(\x01R\babsolute\x12\x10\n" +
+	"\x03bps\x18\a \x01(\x01R\x03bps\x12\x1c\n" +
+	"\ttimestamp\x18\b \x01(\x03R\ttimestamp\x12\x15\n" +
+	"\x06pair_a\x18\t \x01(\tR\x05pairA\x12\x15\n" +
+	"\x06pair_b\x18\n" +
+	" \x01(\tR\x05pairB\"<\n" +
+	"\n" +
+	"Instrument\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x12\n" +
+	"\x04pair\x18\x02 \x01(\tR\x04pair\"G\n" +
+	"\x15GetLatestTradeRequest\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x12\n" +
+	"\x04pair\x18\x02 \x01(\tR\x04pair\"L\n" +
+	"\x16GetLatestTradeResponse\x122\n" +
+	"\x05trade\x18\x01 \x01(\v2\x1c.sibylla.marketdata.v1.TradeR\x05trade\"u\n" +
+	"\x11GetCandlesRequest\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\x12\x12\n" +
+	"\x04pair\x18\x02 \x01(\tR\x04pair\x12\x1a\n" +
+	"\binterval\x18\x03 \x01(\tR\binterval\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x03R\x05limit\"\x86\x01\n" +
+	"\x12GetCandlesResponse\x127\n" +
+	"\acandles\x18\x01 \x03(\v2\x1d.sibylla.marketdata.v1.CandleR\acandles\x127\n" +
+	"\acurrent\x18\x02 \x01(\v2\x1d.sibylla.marketdata.v1.CandleR\acurrent\"*\n" +
+	"\x14GetIndexPriceRequest\x12\x12\n" +
+	"\x04pair\x18\x01 \x01(\tR\x04pair\"P\n" +
+	"\x15GetIndexPriceResponse\x127\n" +
+	"\x05index\x18\x01 \x01(\v2!.sibylla.marketdata.v1.IndexPriceR\x05index\"4\n" +
+	"\x16ListInstrumentsRequest\x12\x1a\n" +
+	"\bexchange\x18\x01 \x01(\tR\bexchange\"^\n" +
+	"\x17ListInstrumentsResponse\x12C\n" +
+	"\vinstruments\x18\x01 \x03(\v2!.sibylla.marketdata.v1.InstrumentR\vinstruments\"I\n" +
+	"\x13StreamTradesRequest\x12\x1c\n" +
+	"\texchanges\x18\x01 \x03(\tR\texchanges\x12\x14\n" +
+	"\x05pairs\x18\x02 \x03(\tR\x05pairs\"J\n" +
+	"\x14StreamSpreadsRequest\x12\x1c\n" +
+	"\texchanges\x18\x01 \x03(\tR\texchanges\x12\x14\n" +
+	"\x05pairs\x18\x02 \x03(\tR\x05pairs2\xf7\x04\n" +
+	"\n" +
+	"MarketData\x12m\n" +
+	"\x0eGetLatestTrade\x12,.sibylla.marketdata.v1.GetLatestTradeRequest\x1a-.sibylla.marketdata.v1.GetLatestTradeResponse\x12a\n" +
+	"\n" +
+	"GetCandles\x12(.sibylla.marketdata.v1.GetCandlesRequest\x1a).sibylla.marketdata.v1.GetCandlesResponse\x12j\n" +
+	"\rGetIndexPrice\x12+.sibylla.marketdata.v1.GetIndexPriceRequest\x1a,.sibylla.marketdata.v1.GetIndexPriceResponse\x12p\n" +
+	"\x0fListInstruments\x12-.sibylla.marketdata.v1.ListInstrumentsRequest\x1a..sibylla.marketdata.v1.ListInstrumentsResponse\x12Z\n" +
+	"\fStreamTrades\x12*.sibylla.marketdata.v1.StreamTradesRequest\x1a\x1c.sibylla.marketdata.v1.Trade0\x01\x12]\n" +
+	"\rStreamSpreads\x12+.sibylla.marketdata.v1.StreamSpreadsRequest\x1a\x1d.sibylla.marketdata.v1.Spread0\x01B-Z+sibylla_service/pkg/marketdata/marketdatapbb\x06proto3"
+
+var (
+	file_marketdata_v1_marketdata_proto_rawDescOnce sync.Once
+	file_marketdata_v1_marketdata_proto_rawDescData []byte
+)
+
+func file_marketdata_v1_marketdata_proto_rawDescGZIP() []byte {
+	file_marketdata_v1_marketdata_proto_rawDescOnce.Do(func() {
+		file_marketdata_v1_marketdata_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_marketdata_v1_marketdata_proto_rawDesc), len(file_marketdata_v1_marketdata_proto_rawDesc)))
+	})
+	return file_marketdata_v1_marketdata_proto_rawDescData
+}
+
+var file_marketdata_v1_marketdata_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_marketdata_v1_marketdata_proto_goTypes = []any{
+	(*Trade)(nil),                   // 0: sibylla.marketdata.v1.Trade
+	(*Candle)(nil),                  // 1: sibylla.marketdata.v1.Candle
+	(*IndexPrice)(nil),              // 2: sibylla.marketdata.v1.IndexPrice
+	(*Spread)(nil),                  // 3: sibylla.marketdata.v1.Spread
+	(*Instrument)(nil),              // 4: sibylla.marketdata.v1.Instrument
+	(*GetLatestTradeRequest)(nil),   // 5: sibylla.marketdata.v1.GetLatestTradeRequest
+	(*GetLatestTradeResponse)(nil),  // 6: sibylla.marketdata.v1.GetLatestTradeResponse
+	(*GetCandlesRequest)(nil),       // 7: sibylla.marketdata.v1.GetCandlesRequest
+	(*GetCandlesResponse)(nil),      // 8: sibylla.marketdata.v1.GetCandlesResponse
+	(*GetIndexPriceRequest)(nil),    // 9: sibylla.marketdata.v1.GetIndexPriceRequest
+	(*GetIndexPriceResponse)(nil),   // 10: sibylla.marketdata.v1.GetIndexPriceResponse
+	(*ListInstrumentsRequest)(nil),  // 11: sibylla.marketdata.v1.ListInstrumentsRequest
+	(*ListInstrumentsResponse)(nil), // 12: sibylla.marketdata.v1.ListInstrumentsResponse
+	(*StreamTradesRequest)(nil),     // 13: sibylla.marketdata.v1.StreamTradesRequest
+	(*StreamSpreadsRequest)(nil),    // 14: sibylla.marketdata.v1.StreamSpreadsRequest
+	(*IndexPrice_Contributor)(nil),  // 15: sibylla.marketdata.v1.IndexPrice.Contributor
+	(*IndexPrice_Excluded)(nil),     // 16: sibylla.marketdata.v1.IndexPrice.Excluded
+}
+var file_marketdata_v1_marketdata_proto_depIdxs = []int32{
+	15, // 0: sibylla.marketdata.v1.IndexPrice.contributors:type_name -> sibylla.marketdata.v1.IndexPrice.Contributor
+	16, // 1: sibylla.marketdata.v1.IndexPrice.excluded:type_name -> sibylla.marketdata.v1.IndexPrice.Excluded
+	0,  // 2: sibylla.marketdata.v1.GetLatestTradeResponse.trade:type_name -> sibylla.marketdata.v1.Trade
+	1,  // 3: sibylla.marketdata.v1.GetCandlesResponse.candles:type_name -> sibylla.marketdata.v1.Candle
+	1,  // 4: sibylla.marketdata.v1.GetCandlesResponse.current:type_name -> sibylla.marketdata.v1.Candle
+	2,  // 5: sibylla.marketdata.v1.GetIndexPriceResponse.index:type_name -> sibylla.marketdata.v1.IndexPrice
+	4,  // 6: sibylla.marketdata.v1.ListInstrumentsResponse.instruments:type_name -> sibylla.marketdata.v1.Instrument
+	5,  // 7: sibylla.marketdata.v1.MarketData.GetLatestTrade:input_type -> sibylla.marketdata.v1.GetLatestTradeRequest
+	7,  // 8: sibylla.marketdata.v1.MarketData.GetCandles:input_type -> sibylla.marketdata.v1.GetCandlesRequest
+	9,  // 9: sibylla.marketdata.v1.MarketData.GetIndexPrice:input_type -> sibylla.marketdata.v1.GetIndexPriceRequest
+	11, // 10: sibylla.marketdata.v1.MarketData.ListInstruments:input_type -> sibylla.marketdata.v1.ListInstrumentsRequest
+	13, // 11: sibylla.marketdata.v1.MarketData.StreamTrades:input_type -> sibylla.marketdata.v1.StreamTradesRequest
+	14, // 12: sibylla.marketdata.v1.MarketData.StreamSpreads:input_type -> sibylla.marketdata.v1.StreamSpreadsRequest
+	6,  // 13: sibylla.marketdata.v1.MarketData.GetLatestTrade:output_type -> sibylla.marketdata.v1.GetLatestTradeResponse
+	8,  // 14: sibylla.marketdata.v1.MarketData.GetCandles:output_type -> sibylla.marketdata.v1.GetCandlesResponse
+	10, // 15: sibylla.marketdata.v1.MarketData.GetIndexPrice:output_type -> sibylla.marketdata.v1.GetIndexPriceResponse
+	12, // 16: sibylla.marketdata.v1.MarketData.ListInstruments:output_type -> sibylla.marketdata.v1.ListInstrumentsResponse
+	0,  // 17: sibylla.marketdata.v1.MarketData.StreamTrades:output_type -> sibylla.marketdata.v1.Trade
+	3,  // 18: sibylla.marketdata.v1.MarketData.StreamSpreads:output_type -> sibylla.marketdata.v1.Spread
+	13, // [13:19] is the sub-list for method output_type
+	7,  // [7:13] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_marketdata_v1_marketdata_proto_init() }
+func file_marketdata_v1_marketdata_proto_init() {
+	if File_marketdata_v1_marketdata_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_marketdata_v1_marketdata_proto_rawDesc), len(file_marketdata_v1_marketdata_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_marketdata_v1_marketdata_proto_goTypes,
+		DependencyIndexes: file_marketdata_v1_marketdata_proto_depIdxs,
+		MessageInfos:      file_marketdata_v1_marketdata_proto_msgTypes,
+	}.Build()
+	File_marketdata_v1_marketdata_proto = out.File
+	file_marketdata_v1_marketdata_proto_goTypes = nil
+	file_marketdata_v1_marketdata_proto_depIdxs = nil
+}
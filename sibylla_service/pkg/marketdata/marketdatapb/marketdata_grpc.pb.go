@@ -0,0 +1,338 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: marketdata/v1/marketdata.proto
+
+package marketdatapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MarketData_GetLatestTrade_FullMethodName  = "/sibylla.marketdata.v1.MarketData/GetLatestTrade"
+	MarketData_GetCandles_FullMethodName      = "/sibylla.marketdata.v1.MarketData/GetCandles"
+	MarketData_GetIndexPrice_FullMethodName   = "/sibylla.marketdata.v1.MarketData/GetIndexPrice"
+	MarketData_ListInstruments_FullMethodName = "/sibylla.marketdata.v1.MarketData/ListInstruments"
+	MarketData_StreamTrades_FullMethodName    = "/sibylla.marketdata.v1.MarketData/StreamTrades"
+	MarketData_StreamSpreads_FullMethodName   = "/sibylla.marketdata.v1.MarketData/StreamSpreads"
+)
+
+// MarketDataClient is the client API for MarketData service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MarketData serves the same trades, candles, index prices and spreads as
+// the HTTP API. Times are Unix milliseconds.
+type MarketDataClient interface {
+	// GetLatestTrade returns the most recent stored trade of a market.
+	GetLatestTrade(ctx context.Context, in *GetLatestTradeRequest, opts ...grpc.CallOption) (*GetLatestTradeResponse, error)
+	// GetCandles returns closed candles, oldest first, and the one in progress.
+	GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (*GetCandlesResponse, error)
+	// GetIndexPrice returns the composite price of a pair across venues.
+	GetIndexPrice(ctx context.Context, in *GetIndexPriceRequest, opts ...grpc.CallOption) (*GetIndexPriceResponse, error)
+	// ListInstruments returns every market with stored trades.
+	ListInstruments(ctx context.Context, in *ListInstrumentsRequest, opts ...grpc.CallOption) (*ListInstrumentsResponse, error)
+	// StreamTrades sends trades as they are received. A client that falls
+	// behind misses trades rather than slowing the service down.
+	StreamTrades(ctx context.Context, in *StreamTradesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Trade], error)
+	// StreamSpreads sends cross-venue spreads as they change.
+	StreamSpreads(ctx context.Context, in *StreamSpreadsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Spread], error)
+}
+
+type marketDataClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMarketDataClient(cc grpc.ClientConnInterface) MarketDataClient {
+	return &marketDataClient{cc}
+}
+
+func (c *marketDataClient) GetLatestTrade(ctx context.Context, in *GetLatestTradeRequest, opts ...grpc.CallOption) (*GetLatestTradeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLatestTradeResponse)
+	err := c.cc.Invoke(ctx, MarketData_GetLatestTrade_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marketDataClient) GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (*GetCandlesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCandlesResponse)
+	err := c.cc.Invoke(ctx, MarketData_GetCandles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marketDataClient) GetIndexPrice(ctx context.Context, in *GetIndexPriceRequest, opts ...grpc.CallOption) (*GetIndexPriceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetIndexPriceResponse)
+	err := c.cc.Invoke(ctx, MarketData_GetIndexPrice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marketDataClient) ListInstruments(ctx context.Context, in *ListInstrumentsRequest, opts ...grpc.CallOption) (*ListInstrumentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListInstrumentsResponse)
+	err := c.cc.Invoke(ctx, MarketData_ListInstruments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marketDataClient) StreamTrades(ctx context.Context, in *StreamTradesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Trade], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MarketData_ServiceDesc.Streams[0], MarketData_StreamTrades_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTradesRequest, Trade]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MarketData_StreamTradesClient = grpc.ServerStreamingClient[Trade]
+
+func (c *marketDataClient) StreamSpreads(ctx context.Context, in *StreamSpreadsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Spread], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MarketData_ServiceDesc.Streams[1], MarketData_StreamSpreads_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamSpreadsRequest, Spread]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MarketData_StreamSpreadsClient = grpc.ServerStreamingClient[Spread]
+
+// MarketDataServer is the server API for MarketData service.
+// All implementations must embed UnimplementedMarketDataServer
+// for forward compatibility.
+//
+// MarketData serves the same trades, candles, index prices and spreads as
+// the HTTP API. Times are Unix milliseconds.
+type MarketDataServer interface {
+	// GetLatestTrade returns the most recent stored trade of a market.
+	GetLatestTrade(context.Context, *GetLatestTradeRequest) (*GetLatestTradeResponse, error)
+	// GetCandles returns closed candles, oldest first, and the one in progress.
+	GetCandles(context.Context, *GetCandlesRequest) (*GetCandlesResponse, error)
+	// GetIndexPrice returns the composite price of a pair across venues.
+	GetIndexPrice(context.Context, *GetIndexPriceRequest) (*GetIndexPriceResponse, error)
+	// ListInstruments returns every market with stored trades.
+	ListInstruments(context.Context, *ListInstrumentsRequest) (*ListInstrumentsResponse, error)
+	// StreamTrades sends trades as they are received. A client that falls
+	// behind misses trades rather than slowing the service down.
+	StreamTrades(*StreamTradesRequest, grpc.ServerStreamingServer[Trade]) error
+	// StreamSpreads sends cross-venue spreads as they change.
+	StreamSpreads(*StreamSpreadsRequest, grpc.ServerStreamingServer[Spread]) error
+	mustEmbedUnimplementedMarketDataServer()
+}
+
+// UnimplementedMarketDataServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMarketDataServer struct{}
+
+func (UnimplementedMarketDataServer) GetLatestTrade(context.Context, *GetLatestTradeRequest) (*GetLatestTradeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatestTrade not implemented")
+}
+func (UnimplementedMarketDataServer) GetCandles(context.Context, *GetCandlesRequest) (*GetCandlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCandles not implemented")
+}
+func (UnimplementedMarketDataServer) GetIndexPrice(context.Context, *GetIndexPriceRequest) (*GetIndexPriceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetIndexPrice not implemented")
+}
+func (UnimplementedMarketDataServer) ListInstruments(context.Context, *ListInstrumentsRequest) (*ListInstrumentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInstruments not implemented")
+}
+func (UnimplementedMarketDataServer) StreamTrades(*StreamTradesRequest, grpc.ServerStreamingServer[Trade]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTrades not implemented")
+}
+func (UnimplementedMarketDataServer) StreamSpreads(*StreamSpreadsRequest, grpc.ServerStreamingServer[Spread]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSpreads not implemented")
+}
+func (UnimplementedMarketDataServer) mustEmbedUnimplementedMarketDataServer() {}
+func (UnimplementedMarketDataServer) testEmbeddedByValue()                    {}
+
+// UnsafeMarketDataServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MarketDataServer will
+// result in compilation errors.
+type UnsafeMarketDataServer interface {
+	mustEmbedUnimplementedMarketDataServer()
+}
+
+func RegisterMarketDataServer(s grpc.ServiceRegistrar, srv MarketDataServer) {
+	// If the following call pancis, it indicates UnimplementedMarketDataServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MarketData_ServiceDesc, srv)
+}
+
+func _MarketData_GetLatestTrade_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestTradeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketDataServer).GetLatestTrade(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MarketData_GetLatestTrade_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketDataServer).GetLatestTrade(ctx, req.(*GetLatestTradeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MarketData_GetCandles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCandlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketDataServer).GetCandles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MarketData_GetCandles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketDataServer).GetCandles(ctx, req.(*GetCandlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MarketData_GetIndexPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetIndexPriceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketDataServer).GetIndexPrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MarketData_GetIndexPrice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketDataServer).GetIndexPrice(ctx, req.(*GetIndexPriceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MarketData_ListInstruments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInstrumentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketDataServer).ListInstruments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MarketData_ListInstruments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketDataServer).ListInstruments(ctx, req.(*ListInstrumentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MarketData_StreamTrades_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTradesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MarketDataServer).StreamTrades(m, &grpc.GenericServerStream[StreamTradesRequest, Trade]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MarketData_StreamTradesServer = grpc.ServerStreamingServer[Trade]
+
+func _MarketData_StreamSpreads_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSpreadsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MarketDataServer).StreamSpreads(m, &grpc.GenericServerStream[StreamSpreadsRequest, Spread]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MarketData_StreamSpreadsServer = grpc.ServerStreamingServer[Spread]
+
+// MarketData_ServiceDesc is the grpc.ServiceDesc for MarketData service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MarketData_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sibylla.marketdata.v1.MarketData",
+	HandlerType: (*MarketDataServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLatestTrade",
+			Handler:    _MarketData_GetLatestTrade_Handler,
+		},
+		{
+			MethodName: "GetCandles",
+			Handler:    _MarketData_GetCandles_Handler,
+		},
+		{
+			MethodName: "GetIndexPrice",
+			Handler:    _MarketData_GetIndexPrice_Handler,
+		},
+		{
+			MethodName: "ListInstruments",
+			Handler:    _MarketData_ListInstruments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTrades",
+			Handler:       _MarketData_StreamTrades_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamSpreads",
+			Handler:       _MarketData_StreamSpreads_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "marketdata/v1/marketdata.proto",
+}
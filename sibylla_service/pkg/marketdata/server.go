@@ -0,0 +1,231 @@
+// Package marketdata serves market data over gRPC, from the same store,
+// engines and bus as the HTTP API.
+package marketdata
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=sibylla_service --go-grpc_out=../.. --go-grpc_opt=module=sibylla_service marketdata/v1/marketdata.proto
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/candles"
+	"sibylla_service/pkg/index"
+	"sibylla_service/pkg/marketdata/marketdatapb"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+	"sibylla_service/pkg/spread"
+)
+
+// DefaultCandleLimit is the number of closed candles returned when a request sets none.
+const DefaultCandleLimit = 100
+
+// streamBuffer is the bus subscription buffer of each streaming call. A
+// client that falls further behind misses updates.
+const streamBuffer = 1024
+
+// Server implements the MarketData gRPC service.
+type Server struct {
+	marketdatapb.UnimplementedMarketDataServer
+
+	redisClient *redisclient.RedisClient
+	candles     *candles.Builder
+	index       *index.Engine
+	bus         *bus.Bus
+}
+
+func NewServer(redisClient *redisclient.RedisClient, candleBuilder *candles.Builder, indexEngine *index.Engine, b *bus.Bus) *Server {
+	return &Server{
+		redisClient: redisClient,
+		candles:     candleBuilder,
+		index:       indexEngine,
+		bus:         b,
+	}
+}
+
+// GetLatestTrade returns the most recent stored trade of a market.
+func (s *Server) GetLatestTrade(ctx context.Context, req *marketdatapb.GetLatestTradeRequest) (*marketdatapb.GetLatestTradeResponse, error) {
+	if req.GetExchange() == "" || req.GetPair() == "" {
+		return nil, status.Error(codes.InvalidArgument, "exchange and pair are required")
+	}
+
+	entries, err := s.redisClient.GetStreamRange(models.TradeKey(req.GetExchange(), req.GetPair()), "+", "-", 1)
+	if err != nil {
+		log.Printf("Could not read latest trade of %s %s: %v", req.GetExchange(), req.GetPair(), err)
+		return nil, status.Error(codes.Internal, "failed to retrieve trade")
+	}
+	if len(entries) == 0 {
+		return nil, status.Errorf(codes.NotFound, "no trades for %s %s", req.GetExchange(), req.GetPair())
+	}
+
+	t, err := models.DecodeTrade(entries[0].Value)
+	if err != nil {
+		log.Printf("Failed to decode trade data for %s %s: %v", req.GetExchange(), req.GetPair(), err)
+		return nil, status.Error(codes.Internal, "failed to decode trade")
+	}
+	return &marketdatapb.GetLatestTradeResponse{Trade: TradeToProto(t)}, nil
+}
+
+// GetCandles returns closed candles, oldest first, and the one in progress.
+func (s *Server) GetCandles(ctx context.Context, req *marketdatapb.GetCandlesRequest) (*marketdatapb.GetCandlesResponse, error) {
+	if req.GetExchange() == "" || req.GetPair() == "" {
+		return nil, status.Error(codes.InvalidArgument, "exchange and pair are required")
+	}
+	intervalName := req.GetInterval()
+	if intervalName == "" {
+		intervalName = "1m"
+	}
+	interval, err := candles.ParseInterval(intervalName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	limit := req.GetLimit()
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = DefaultCandleLimit
+	}
+
+	stored, err := s.redisClient.GetSortedSet(candles.Key(req.GetExchange(), req.GetPair(), interval.Name), limit)
+	if err != nil {
+		log.Printf("Could not read candles of %s %s: %v", req.GetExchange(), req.GetPair(), err)
+		return nil, status.Error(codes.Internal, "failed to retrieve candles")
+	}
+
+	// Stored newest first
+	response := &marketdatapb.GetCandlesResponse{Candles: make([]*marketdatapb.Candle, 0, len(stored))}
+	for i := len(stored) - 1; i >= 0; i-- {
+		var c candles.Candle
+		if err := json.Unmarshal([]byte(stored[i]), &c); err != nil {
+			log.Printf("Failed to unmarshal candle for %s %s: %v", req.GetExchange(), req.GetPair(), err)
+			continue
+		}
+		response.Candles = append(response.Candles, candleToProto(c))
+	}
+	if current, ok := s.candles.Current(req.GetExchange(), req.GetPair(), interval.Name); ok {
+		response.Current = candleToProto(current)
+	}
+	return response, nil
+}
+
+// GetIndexPrice returns the composite price of a pair across venues.
+func (s *Server) GetIndexPrice(ctx context.Context, req *marketdatapb.GetIndexPriceRequest) (*marketdatapb.GetIndexPriceResponse, error) {
+	if req.GetPair() == "" {
+		return nil, status.Error(codes.InvalidArgument, "pair is required")
+	}
+	price, ok := s.index.Current(req.GetPair())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no index for pair %s", req.GetPair())
+	}
+	return &marketdatapb.GetIndexPriceResponse{Index: indexToProto(price)}, nil
+}
+
+// ListInstruments returns every market with stored trades, sorted by exchange and pair.
+func (s *Server) ListInstruments(ctx context.Context, req *marketdatapb.ListInstrumentsRequest) (*marketdatapb.ListInstrumentsResponse, error) {
+	exchange := req.GetExchange()
+	if exchange == "" {
+		exchange = "*"
+	} else if !validKeyPart(exchange) {
+		return nil, status.Error(codes.InvalidArgument, "exchange may only contain letters, digits, '.', '_' and '-'")
+	}
+
+	keys, err := s.redisClient.ScanKeys(models.TradeKey(exchange, "*"))
+	if err != nil {
+		log.Printf("Could not list trade keys: %v", err)
+		return nil, status.Error(codes.Internal, "failed to retrieve instruments")
+	}
+	sort.Strings(keys)
+
+	response := &marketdatapb.ListInstrumentsResponse{Instruments: make([]*marketdatapb.Instrument, 0, len(keys))}
+	for i, key := range keys {
+		parts := strings.SplitN(key, ":", 3)
+		// SCAN may return a key twice
+		if len(parts) != 3 || i > 0 && keys[i-1] == key {
+			continue
+		}
+		response.Instruments = append(response.Instruments, &marketdatapb.Instrument{Exchange: parts[1], Pair: parts[2]})
+	}
+	return response, nil
+}
+
+// StreamTrades sends trades from the bus until the client cancels.
+func (s *Server) StreamTrades(req *marketdatapb.StreamTradesRequest, stream grpc.ServerStreamingServer[marketdatapb.Trade]) error {
+	exchanges, pairs := set(req.GetExchanges()), set(req.GetPairs())
+	return s.forward(stream.Context(), bus.TopicTrades, func(payload interface{}) error {
+		t := payload.(models.Trade)
+		if !match(pairs, t.Pair) || !match(exchanges, t.Exchange) {
+			return nil
+		}
+		return stream.Send(TradeToProto(t))
+	})
+}
+
+// StreamSpreads sends spreads from the bus until the client cancels.
+func (s *Server) StreamSpreads(req *marketdatapb.StreamSpreadsRequest, stream grpc.ServerStreamingServer[marketdatapb.Spread]) error {
+	// Spreads are keyed by market, where pegged quotes are equivalent
+	exchanges, pairs := set(req.GetExchanges()), make(map[string]bool)
+	for _, pair := range req.GetPairs() {
+		pairs[models.CanonicalPair(pair)] = true
+	}
+	return s.forward(stream.Context(), bus.TopicSpreads, func(payload interface{}) error {
+		sp := payload.(spread.Spread)
+		if !match(pairs, sp.Pair) || !(match(exchanges, sp.ExchangeA) || match(exchanges, sp.ExchangeB)) {
+			return nil
+		}
+		return stream.Send(spreadToProto(sp))
+	})
+}
+
+// forward passes a topic's payloads to send until the context ends or send fails.
+func (s *Server) forward(ctx context.Context, topic string, send func(payload interface{}) error) error {
+	sub := s.bus.Subscribe(topic, streamBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "stream closed")
+			}
+			if err := send(msg.Payload); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func set(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
+
+// match reports whether a value passes a filter; an empty filter matches everything.
+func match(filter map[string]bool, value string) bool {
+	return len(filter) == 0 || filter[value]
+}
+
+// validKeyPart keeps filters free of Redis pattern characters, as in the trades API.
+func validKeyPart(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
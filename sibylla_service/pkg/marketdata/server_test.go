@@ -0,0 +1,238 @@
+package marketdata
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/candles"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/marketdata/marketdatapb"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+type testService struct {
+	client      marketdatapb.MarketDataClient
+	redisClient *redisclient.RedisClient
+	candles     *candles.Builder
+	bus         *bus.Bus
+}
+
+// newTestService serves a Server over an in-memory connection.
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	s := &testService{
+		redisClient: redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0),
+		bus:         bus.New(),
+	}
+	s.candles = candles.NewBuilder(s.redisClient, nil, []candles.Interval{{Name: "1m", Duration: time.Minute}}, exchangeconfig.CandleConfig{})
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	marketdatapb.RegisterMarketDataServer(grpcServer, NewServer(s.redisClient, s.candles, nil, s.bus))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s.client = marketdatapb.NewMarketDataClient(conn)
+	return s
+}
+
+// store saves trades as the exchange clients do, keyed by trade time.
+func (s *testService) store(t *testing.T, trades ...models.Trade) {
+	t.Helper()
+	for _, tr := range trades {
+		if err := s.redisClient.AppendToStreamAt(models.TradeKey(tr.Exchange, tr.Pair), tr.Timestamp, tr, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestGetLatestTrade(t *testing.T) {
+	s := newTestService(t)
+	ctx := testContext(t)
+	now := time.Now().UnixMilli()
+	s.store(t,
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 1, Timestamp: now - 2000},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101, Quantity: 2, Timestamp: now - 1000, IsBuyerMaker: true},
+		models.Trade{Exchange: "binance", Pair: "ETHUSDT", Price: 5, Quantity: 3, Timestamp: now},
+	)
+
+	resp, err := s.client.GetLatestTrade(ctx, &marketdatapb.GetLatestTradeRequest{Exchange: "binance", Pair: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101, Quantity: 2, Timestamp: now - 1000, IsBuyerMaker: true}
+	if got := TradeFromProto(resp.GetTrade()); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	_, err = s.client.GetLatestTrade(ctx, &marketdatapb.GetLatestTradeRequest{Exchange: "kraken", Pair: "BTCUSD"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown market got %v, want NotFound", err)
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+	s := newTestService(t)
+	ctx := testContext(t)
+	start := time.Now().Truncate(time.Minute).Add(-3 * time.Minute).UnixMilli()
+	for i, price := range []float64{100, 101, 102, 103} {
+		s.candles.AddTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: price, Quantity: 1, Timestamp: start + int64(i)*time.Minute.Milliseconds()})
+	}
+
+	resp, err := s.client.GetCandles(ctx, &marketdatapb.GetCandlesRequest{Exchange: "binance", Pair: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetCandles()) != 3 {
+		t.Fatalf("got %d closed candles, want 3", len(resp.GetCandles()))
+	}
+	for i, c := range resp.GetCandles() {
+		if c.GetOpenTime() != start+int64(i)*time.Minute.Milliseconds() || c.GetClose() != float64(100+i) || !c.GetClosed() {
+			t.Errorf("candle %d is %+v", i, c)
+		}
+	}
+	if c := resp.GetCurrent(); c.GetClose() != 103 || c.GetClosed() {
+		t.Errorf("current candle is %+v", c)
+	}
+
+	// The limit keeps the newest
+	resp, err = s.client.GetCandles(ctx, &marketdatapb.GetCandlesRequest{Exchange: "binance", Pair: "BTCUSDT", Interval: "1m", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := resp.GetCandles(); len(c) != 2 || c[0].GetClose() != 101 || c[1].GetClose() != 102 {
+		t.Errorf("limited to 2 got %+v", c)
+	}
+}
+
+func TestStreamTradesFilters(t *testing.T) {
+	s := newTestService(t)
+	ctx, cancel := context.WithCancel(testContext(t))
+	defer cancel()
+
+	stream, err := s.client.StreamTrades(ctx, &marketdatapb.StreamTradesRequest{Exchanges: []string{"binance"}, Pairs: []string{"BTCUSDT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server subscribes once the call reaches it, so publish until trades arrive
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			for _, tr := range []models.Trade{
+				{Exchange: "kraken", Pair: "BTCUSDT", Price: 1},
+				{Exchange: "binance", Pair: "ETHUSDT", Price: 2},
+				{Exchange: "binance", Pair: "BTCUSDT", Price: 3},
+			} {
+				s.bus.Publish(bus.TopicTrades, tr)
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		tr, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.GetExchange() != "binance" || tr.GetPair() != "BTCUSDT" || tr.GetPrice() != 3 {
+			t.Fatalf("streamed %+v outside the filter", tr)
+		}
+	}
+}
+
+func TestListInstruments(t *testing.T) {
+	s := newTestService(t)
+	ctx := testContext(t)
+	s.store(t,
+		models.Trade{Exchange: "kraken", Pair: "BTCUSD", Timestamp: 1},
+		models.Trade{Exchange: "binance", Pair: "ETHUSDT", Timestamp: 1},
+		models.Trade{Exchange: "binance", Pair: "BTCUSDT", Timestamp: 1},
+	)
+
+	resp, err := s.client.ListInstruments(ctx, &marketdatapb.ListInstrumentsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"binance:BTCUSDT", "binance:ETHUSDT", "kraken:BTCUSD"}
+	var got []string
+	for _, i := range resp.GetInstruments() {
+		got = append(got, i.GetExchange()+":"+i.GetPair())
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	resp, err = s.client.ListInstruments(ctx, &marketdatapb.ListInstrumentsRequest{Exchange: "kraken"})
+	if err != nil || len(resp.GetInstruments()) != 1 || resp.GetInstruments()[0].GetPair() != "BTCUSD" {
+		t.Errorf("kraken instruments got %v, %v", resp.GetInstruments(), err)
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	s := newTestService(t)
+	ctx := testContext(t)
+
+	calls := map[string]func() error{
+		"latest trade without pair": func() error {
+			_, err := s.client.GetLatestTrade(ctx, &marketdatapb.GetLatestTradeRequest{Exchange: "binance"})
+			return err
+		},
+		"candles without exchange": func() error {
+			_, err := s.client.GetCandles(ctx, &marketdatapb.GetCandlesRequest{Pair: "BTCUSDT"})
+			return err
+		},
+		"candles of unknown interval": func() error {
+			_, err := s.client.GetCandles(ctx, &marketdatapb.GetCandlesRequest{Exchange: "binance", Pair: "BTCUSDT", Interval: "7m"})
+			return err
+		},
+		"negative candle limit": func() error {
+			_, err := s.client.GetCandles(ctx, &marketdatapb.GetCandlesRequest{Exchange: "binance", Pair: "BTCUSDT", Limit: -1})
+			return err
+		},
+		"index without pair": func() error {
+			_, err := s.client.GetIndexPrice(ctx, &marketdatapb.GetIndexPriceRequest{})
+			return err
+		},
+		"instruments of a pattern": func() error {
+			_, err := s.client.ListInstruments(ctx, &marketdatapb.ListInstrumentsRequest{Exchange: "*"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", name, err)
+		}
+	}
+}
@@ -1,12 +1,75 @@
 package models
 
+import "sort"
+
+// Asset is a node in the asset graph. RelatedAssets are the assets it can be
+// converted to through a market; Equivalents are assets it is pegged or
+// wrapped 1:1 against, which may be assumed at par when no market is live.
 type Asset struct {
 	Symbol        string   `json:"symbol"`
 	RelatedAssets []string `json:"related_assets"`
+	Equivalents   []string `json:"equivalents,omitempty"`
 }
 
 var assetsMap = map[string]Asset{
-	"USDT": {Symbol: "USDT", RelatedAssets: []string{"USD", "BTC", "ETH"}},
-	"BTC":  {Symbol: "BTC", RelatedAssets: []string{"WBTC", "USDT"}},
-	"WBTC": {Symbol: "WBTC", RelatedAssets: []string{"BTC"}},
+	"USD":  {Symbol: "USD", RelatedAssets: []string{"USDT", "BTC", "ETH"}},
+	"USDT": {Symbol: "USDT", RelatedAssets: []string{"USD", "BTC", "ETH", "WBTC"}, Equivalents: []string{"USD"}},
+	"BTC":  {Symbol: "BTC", RelatedAssets: []string{"WBTC", "USDT", "USD", "ETH"}},
+	"WBTC": {Symbol: "WBTC", RelatedAssets: []string{"BTC", "USDT"}, Equivalents: []string{"BTC"}},
+	"ETH":  {Symbol: "ETH", RelatedAssets: []string{"USDT", "USD", "BTC"}},
+}
+
+// LookupAsset returns an asset from the asset graph.
+func LookupAsset(symbol string) (Asset, bool) {
+	asset, ok := assetsMap[symbol]
+	return asset, ok
+}
+
+// AssetSymbols returns every asset in the graph, sorted.
+func AssetSymbols() []string {
+	symbols := make([]string, 0, len(assetsMap))
+	for symbol := range assetsMap {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// AreEquivalent reports whether either asset declares the other as a 1:1 equivalent.
+func AreEquivalent(a, b string) bool {
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		for _, e := range assetsMap[pair[0]].Equivalents {
+			if e == pair[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// quoteAssets are the quote currencies recognised when splitting a pair, longest first
+var quoteAssets = []string{"USDT", "USDC", "BUSD", "USD", "DAI", "EUR", "BTC", "ETH"}
+
+// SplitPair splits a bespoke pair such as "BTCUSDT" into its base and quote assets.
+func SplitPair(pair string) (base, quote string, ok bool) {
+	for _, q := range quoteAssets {
+		if len(pair) > len(q) && pair[len(pair)-len(q):] == q {
+			return pair[:len(pair)-len(q)], q, true
+		}
+	}
+	return "", "", false
+}
+
+// CanonicalPair names the market a pair prices, mapping a quote asset pegged
+// 1:1 to another onto that asset, so "BTCUSDT" and "BTCUSD" are both "BTCUSD".
+// Pairs that can't be split are returned as is.
+func CanonicalPair(pair string) string {
+	base, quote, ok := SplitPair(pair)
+	if !ok {
+		return pair
+	}
+	if asset, ok := assetsMap[quote]; ok && len(asset.Equivalents) > 0 {
+		quote = asset.Equivalents[0]
+	}
+	return base + quote
 }
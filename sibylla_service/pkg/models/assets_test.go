@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestCanonicalPair(t *testing.T) {
+	tests := map[string]string{
+		"BTCUSDT": "BTCUSD",
+		"BTCUSD":  "BTCUSD",
+		"ETHUSDT": "ETHUSD",
+		"ETHBTC":  "ETHBTC",
+		"WBTCBTC": "WBTCBTC", // only the quote is mapped
+		"XYZ":     "XYZ",
+	}
+	for pair, want := range tests {
+		if got := CanonicalPair(pair); got != want {
+			t.Errorf("CanonicalPair(%s) = %s, want %s", pair, got, want)
+		}
+	}
+}
@@ -1,22 +1,19 @@
 package models
 
-import (
-	"encoding/json"
-)
-
 // Trade struct definition
 type Trade struct {
 	Exchange     string
 	Pair         string
 	Price        float64
 	Quantity     float64
-	Timestamp    int64
+	Timestamp    int64 // Exchange trade time, Unix milliseconds
 	IsBuyerMaker bool
+	ReceivedAt   int64 // Local receive time, Unix milliseconds; zero if unknown
 }
 
-// Implement the encoding.BinaryMarshaler interface
-func (t Trade) MarshalBinary() ([]byte, error) {
-	return json.Marshal(t)
+// TradeKey is the store key holding trades for an exchange and pair.
+func TradeKey(exchange, pair string) string {
+	return "trades:" + exchange + ":" + pair
 }
 
 // Binance incoming trade data
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Stored trades start with a schema version byte followed by the encoded body.
+// Entries written before versioning are plain JSON objects and start with '{'.
+const (
+	TradeSchemaV1      byte = 1 // MessagePack array, see tradeV1
+	TradeSchemaV2      byte = 2 // tradeV1 plus the receive time, see tradeV2
+	TradeSchemaVersion      = TradeSchemaV2
+
+	legacyJSONPrefix byte = '{'
+)
+
+// ErrUnknownTradeSchema is returned when a stored trade has an unrecognised version byte.
+var ErrUnknownTradeSchema = errors.New("unknown trade schema version")
+
+// tradeV1 is the compact positional layout of schema version 1.
+// A layout is frozen once released: the decoder rejects arrays whose length
+// differs from the struct, so new fields need a new version.
+type tradeV1 struct {
+	_msgpack struct{} `msgpack:",as_array"`
+
+	Exchange     string
+	Pair         string
+	Price        float64
+	Quantity     float64
+	Timestamp    int64
+	IsBuyerMaker bool
+}
+
+// tradeV2 is the layout of schema version 2.
+type tradeV2 struct {
+	_msgpack struct{} `msgpack:",as_array"`
+
+	Exchange     string
+	Pair         string
+	Price        float64
+	Quantity     float64
+	Timestamp    int64
+	IsBuyerMaker bool
+	ReceivedAt   int64
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the current schema version.
+func (t Trade) MarshalBinary() ([]byte, error) {
+	body, err := msgpack.Marshal(&tradeV2{
+		Exchange:     t.Exchange,
+		Pair:         t.Pair,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		Timestamp:    t.Timestamp,
+		IsBuyerMaker: t.IsBuyerMaker,
+		ReceivedAt:   t.ReceivedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{TradeSchemaVersion}, body...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It reads every schema
+// version as well as legacy JSON entries.
+func (t *Trade) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty trade data")
+	}
+
+	switch data[0] {
+	case legacyJSONPrefix:
+		return json.Unmarshal(data, t)
+	case TradeSchemaV1:
+		var v tradeV1
+		if err := msgpack.Unmarshal(data[1:], &v); err != nil {
+			return fmt.Errorf("decode trade v1: %w", err)
+		}
+		*t = Trade{
+			Exchange:     v.Exchange,
+			Pair:         v.Pair,
+			Price:        v.Price,
+			Quantity:     v.Quantity,
+			Timestamp:    v.Timestamp,
+			IsBuyerMaker: v.IsBuyerMaker,
+		}
+		return nil
+	case TradeSchemaV2:
+		var v tradeV2
+		if err := msgpack.Unmarshal(data[1:], &v); err != nil {
+			return fmt.Errorf("decode trade v2: %w", err)
+		}
+		*t = Trade{
+			Exchange:     v.Exchange,
+			Pair:         v.Pair,
+			Price:        v.Price,
+			Quantity:     v.Quantity,
+			Timestamp:    v.Timestamp,
+			IsBuyerMaker: v.IsBuyerMaker,
+			ReceivedAt:   v.ReceivedAt,
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownTradeSchema, data[0])
+	}
+}
+
+// DecodeTrade decodes a trade as stored in Redis.
+func DecodeTrade(data string) (Trade, error) {
+	var t Trade
+	err := t.UnmarshalBinary([]byte(data))
+	return t, err
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var sample = Trade{
+	Exchange:     "binance",
+	Pair:         "BTCUSDT",
+	Price:        67234.51,
+	Quantity:     0.00231,
+	Timestamp:    1729345815123,
+	IsBuyerMaker: true,
+	ReceivedAt:   1729345815187,
+}
+
+func TestTradeRoundTrip(t *testing.T) {
+	data, err := sample.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != TradeSchemaVersion {
+		t.Fatalf("encoded with version %d, want %d", data[0], TradeSchemaVersion)
+	}
+	got, err := DecodeTrade(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sample {
+		t.Fatalf("round trip gave %+v, want %+v", got, sample)
+	}
+}
+
+func TestDecodeTradeV1(t *testing.T) {
+	body, err := msgpack.Marshal(&tradeV1{
+		Exchange:     sample.Exchange,
+		Pair:         sample.Pair,
+		Price:        sample.Price,
+		Quantity:     sample.Quantity,
+		Timestamp:    sample.Timestamp,
+		IsBuyerMaker: sample.IsBuyerMaker,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeTrade(string(append([]byte{TradeSchemaV1}, body...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sample
+	want.ReceivedAt = 0 // not in v1
+	if got != want {
+		t.Fatalf("v1 decoded to %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeTradeLegacyJSON(t *testing.T) {
+	legacy, err := json.Marshal(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeTrade(string(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sample {
+		t.Fatalf("legacy JSON decoded to %+v, want %+v", got, sample)
+	}
+}
+
+func TestDecodeTradeRejectsBadData(t *testing.T) {
+	if _, err := DecodeTrade(""); err == nil {
+		t.Error("empty data decoded without error")
+	}
+	if _, err := DecodeTrade(string([]byte{99, 1, 2})); !errors.Is(err, ErrUnknownTradeSchema) {
+		t.Errorf("unknown version returned %v, want ErrUnknownTradeSchema", err)
+	}
+	// A layout is frozen: a v2 body under the v1 byte must not decode
+	body, _ := msgpack.Marshal(&tradeV2{Exchange: "binance"})
+	if _, err := DecodeTrade(string(append([]byte{TradeSchemaV1}, body...))); err == nil {
+		t.Error("v2 body decoded as v1")
+	}
+}
+
+// The encode and decode benchmarks report the payload size as bytes/trade.
+// That is the value Redis stores, not its memory footprint, which adds
+// per-entry stream overhead; BenchmarkRedisMemory measures that against a
+// real server.
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	b.ReportAllocs()
+	var data []byte
+	for i := 0; i < b.N; i++ {
+		data, _ = json.Marshal(sample)
+	}
+	b.ReportMetric(float64(len(data)), "bytes/trade")
+}
+
+func BenchmarkEncodeBinary(b *testing.B) {
+	b.ReportAllocs()
+	var data []byte
+	for i := 0; i < b.N; i++ {
+		data, _ = sample.MarshalBinary()
+	}
+	b.ReportMetric(float64(len(data)), "bytes/trade")
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	legacy, _ := json.Marshal(sample)
+	data := string(legacy)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecodeTrade(data)
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	compact, _ := sample.MarshalBinary()
+	data := string(compact)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecodeTrade(data)
+	}
+}
+
+// BenchmarkRedisMemory stores trades in streams with each encoding and reports
+// Redis' MEMORY USAGE per trade. It needs a scratch Redis, e.g.
+//
+//	BENCH_REDIS_ADDR=localhost:6379 go test ./pkg/models -run - -bench RedisMemory
+//
+// and writes to keys under bench:, which it deletes afterwards.
+func BenchmarkRedisMemory(b *testing.B) {
+	addr := os.Getenv("BENCH_REDIS_ADDR")
+	if addr == "" {
+		b.Skip("BENCH_REDIS_ADDR is not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	const trades = 10000
+	encodings := map[string]func(Trade) ([]byte, error){
+		"json":   func(t Trade) ([]byte, error) { return json.Marshal(t) },
+		"binary": func(t Trade) ([]byte, error) { return t.MarshalBinary() },
+	}
+	for name, encode := range encodings {
+		b.Run(name, func(b *testing.B) {
+			key := "bench:trades:" + name
+			defer client.Del(ctx, key)
+
+			for n := 0; n < b.N; n++ {
+				client.Del(ctx, key)
+				pipe := client.Pipeline()
+				for i := 0; i < trades; i++ {
+					t := sample
+					t.Timestamp += int64(i)
+					t.Price += float64(i) / 100
+					data, err := encode(t)
+					if err != nil {
+						b.Fatal(err)
+					}
+					pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, ID: fmt.Sprintf("%d-0", t.Timestamp), Values: map[string]interface{}{"data": data}})
+				}
+				if _, err := pipe.Exec(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			usage, err := client.MemoryUsage(ctx, key, 0).Result()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(usage)/trades, "redis-bytes/trade")
+		})
+	}
+}
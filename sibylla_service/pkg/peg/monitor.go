@@ -0,0 +1,396 @@
+package peg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// EventsKey is the stream holding peg events.
+const EventsKey = "pegs:events"
+
+// ErrUnknownPeg is returned when observing a peg that isn't monitored.
+var ErrUnknownPeg = errors.New("peg is not monitored")
+
+// Event states
+const (
+	StateBreach    = "breach"
+	StateRecovered = "recovered"
+)
+
+// Directions of a deviation from the peg
+const (
+	DirectionPremium  = "premium"  // asset worth more than the reference
+	DirectionDiscount = "discount" // asset worth less than the reference
+)
+
+// Observation is one estimate of a peg ratio from a pair of markets.
+// Ratio is the value of one unit of the asset in the reference asset.
+type Observation struct {
+	Source string  `json:"source"` // e.g. "binance:BTCUSDT/kraken:BTCUSD"
+	Ratio  float64 `json:"ratio"`
+}
+
+// Stats summarise the deviation in bps over the stats window.
+type Stats struct {
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean_bps"`
+	StdDev  float64 `json:"stddev_bps"`
+	Min     float64 `json:"min_bps"`
+	Max     float64 `json:"max_bps"`
+}
+
+// Status is the current state of a peg.
+type Status struct {
+	Asset        string        `json:"asset"`
+	Reference    string        `json:"reference"`
+	Ratio        float64       `json:"ratio"`         // median of the observations
+	DeviationBps float64       `json:"deviation_bps"` // (Ratio - 1) in bps
+	Direction    string        `json:"direction"`
+	Stats        Stats         `json:"stats"`
+	Observations []Observation `json:"observations"`
+	Breaches     []string      `json:"breaches"` // thresholds currently breached
+	Timestamp    int64         `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (s Status) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Event is raised when a peg breaches a threshold for its sustain period and
+// again when it recovers.
+type Event struct {
+	Asset        string  `json:"asset"`
+	Reference    string  `json:"reference"`
+	Threshold    string  `json:"threshold"`
+	State        string  `json:"state"`
+	Direction    string  `json:"direction"`
+	Ratio        float64 `json:"ratio"`
+	DeviationBps float64 `json:"deviation_bps"`
+	Since        int64   `json:"since"` // when the deviation first crossed the threshold
+	Timestamp    int64   `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (e Event) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Key is the store key holding the time series of a peg.
+func Key(asset, reference string) string {
+	return "pegs:" + asset + ":" + reference
+}
+
+type thresholdState struct {
+	exceededSince time.Time
+	raised        bool
+}
+
+type sample struct {
+	at  time.Time
+	bps float64
+}
+
+type external struct {
+	observation Observation
+	at          time.Time
+}
+
+type pegState struct {
+	config     exchangeconfig.PegConfig
+	status     Status
+	thresholds []thresholdState
+	samples    []sample            // deviations inside the stats window
+	external   map[string]external // by source
+}
+
+// Monitor infers the rate between pegged, wrapped or liquid-staking assets and
+// their reference from the markets already watched, keeps rolling statistics,
+// and raises premium/discount events when it drifts from 1:1.
+type Monitor struct {
+	mu          sync.RWMutex
+	config      exchangeconfig.PegMonitorConfig
+	tracker     *market.Tracker
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	pegs        []*pegState
+}
+
+func NewMonitor(tracker *market.Tracker, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.PegMonitorConfig) *Monitor {
+	config = config.WithDefaults()
+	m := &Monitor{
+		config:      config,
+		tracker:     tracker,
+		redisClient: redisClient,
+		bus:         b,
+	}
+	for _, p := range config.Pegs {
+		m.pegs = append(m.pegs, &pegState{
+			config:     p,
+			status:     Status{Asset: p.Asset, Reference: p.Reference},
+			thresholds: make([]thresholdState, len(p.Thresholds)),
+			external:   make(map[string]external),
+		})
+	}
+	return m
+}
+
+// Run evaluates every peg on the configured interval. It blocks, call it in a goroutine.
+func (m *Monitor) Run() {
+	ticker := time.NewTicker(m.config.Interval.Duration)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, p := range m.pegs {
+			m.evaluate(p, now)
+		}
+	}
+}
+
+// Observe feeds an estimate from outside the trade stream, such as an on-chain
+// pool, into a peg. It counts until it is older than the staleness limit.
+func (m *Monitor) Observe(asset, reference, source string, ratio float64) error {
+	if source == "" || ratio <= 0 {
+		return fmt.Errorf("an observation needs a source and a positive ratio")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.pegs {
+		if p.config.Asset == asset && p.config.Reference == reference {
+			p.external[source] = external{observation: Observation{Source: source, Ratio: ratio}, at: time.Now()}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrUnknownPeg, asset, reference)
+}
+
+func (m *Monitor) evaluate(p *pegState, now time.Time) {
+	observations := m.observe(p.config.Asset, p.config.Reference, now)
+
+	m.mu.Lock()
+	for source, e := range p.external {
+		if now.Sub(e.at) > m.config.MaxStaleness.Duration {
+			delete(p.external, source)
+			continue
+		}
+		observations = append(observations, e.observation)
+	}
+	m.mu.Unlock()
+	if len(observations) == 0 {
+		return
+	}
+
+	ratios := make([]float64, len(observations))
+	for i, o := range observations {
+		ratios[i] = o.Ratio
+	}
+	ratio := median(ratios)
+	status := Status{
+		Asset:        p.config.Asset,
+		Reference:    p.config.Reference,
+		Ratio:        ratio,
+		DeviationBps: (ratio - 1) * 10000,
+		Direction:    direction(ratio),
+		Observations: observations,
+		Timestamp:    now.UnixMilli(),
+	}
+
+	var events []Event
+	m.mu.Lock()
+	p.addSample(now, status.DeviationBps, m.config.StatsWindow.Duration)
+	status.Stats = p.stats()
+
+	for i, threshold := range p.config.Thresholds {
+		state := &p.thresholds[i]
+		matches := threshold.Direction == "" || threshold.Direction == status.Direction
+		if !matches || math.Abs(status.DeviationBps) <= threshold.Bps {
+			if state.raised {
+				events = append(events, newEvent(status, threshold.Name, StateRecovered, state.exceededSince, now))
+			}
+			*state = thresholdState{}
+			continue
+		}
+
+		if state.exceededSince.IsZero() {
+			state.exceededSince = now
+		}
+		if !state.raised && now.Sub(state.exceededSince) >= threshold.Sustain.Duration {
+			state.raised = true
+			events = append(events, newEvent(status, threshold.Name, StateBreach, state.exceededSince, now))
+		}
+		if state.raised {
+			status.Breaches = append(status.Breaches, threshold.Name)
+		}
+	}
+	p.status = status
+	m.mu.Unlock()
+
+	if m.bus != nil {
+		m.bus.Publish(bus.TopicPegs, status)
+	}
+	for _, e := range events {
+		log.Printf("Peg %s/%s %s %s at %.1f bps %s", e.Asset, e.Reference, e.Threshold, e.State, e.DeviationBps, e.Direction)
+		if m.bus != nil {
+			m.bus.Publish(bus.TopicPegs, e)
+		}
+		m.store(EventsKey, e)
+	}
+	m.store(Key(status.Asset, status.Reference), status)
+}
+
+func newEvent(status Status, threshold, state string, since, now time.Time) Event {
+	return Event{
+		Asset:        status.Asset,
+		Reference:    status.Reference,
+		Threshold:    threshold,
+		State:        state,
+		Direction:    status.Direction,
+		Ratio:        status.Ratio,
+		DeviationBps: status.DeviationBps,
+		Since:        since.UnixMilli(),
+		Timestamp:    now.UnixMilli(),
+	}
+}
+
+func (m *Monitor) store(key string, value interface{}) {
+	if m.redisClient == nil {
+		return
+	}
+	if err := m.redisClient.AppendToStream(key, value, m.config.MaxStored, 0); err != nil {
+		log.Printf("Could not store peg data %s: %v", key, err)
+	}
+}
+
+// observe collects every estimate of the asset/reference ratio from live markets
+// on any combination of venues:
+//   - a direct asset/reference pair (USDTUSD, WBTCBTC)
+//   - the same base quoted in both assets (BTCUSD / BTCUSDT implies USDT/USD)
+//   - both assets quoted in the same currency (WBTCUSDT / BTCUSDT implies WBTC/BTC)
+func (m *Monitor) observe(asset, reference string, now time.Time) []Observation {
+	var observations []Observation
+
+	for _, q := range m.live(asset+reference, now) {
+		observations = append(observations, Observation{Source: source(q), Ratio: q.Price})
+	}
+	for _, q := range m.live(reference+asset, now) {
+		observations = append(observations, Observation{Source: source(q), Ratio: 1 / q.Price})
+	}
+
+	for _, pair := range m.tracker.Pairs() {
+		base, quote, ok := models.SplitPair(pair)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case quote == asset && base != reference:
+			for _, inAsset := range m.live(pair, now) {
+				for _, inReference := range m.live(base+reference, now) {
+					observations = append(observations, Observation{
+						Source: source(inReference) + "/" + source(inAsset),
+						Ratio:  inReference.Price / inAsset.Price,
+					})
+				}
+			}
+		case base == asset && quote != reference:
+			for _, assetQuote := range m.live(pair, now) {
+				for _, referenceQuote := range m.live(reference+quote, now) {
+					observations = append(observations, Observation{
+						Source: source(assetQuote) + "/" + source(referenceQuote),
+						Ratio:  assetQuote.Price / referenceQuote.Price,
+					})
+				}
+			}
+		}
+	}
+	return observations
+}
+
+func direction(ratio float64) string {
+	if ratio < 1 {
+		return DirectionDiscount
+	}
+	return DirectionPremium
+}
+
+func (p *pegState) addSample(now time.Time, bps float64, window time.Duration) {
+	p.samples = append(p.samples, sample{at: now, bps: bps})
+	cutoff := now.Add(-window)
+	n := 0
+	for n < len(p.samples) && p.samples[n].at.Before(cutoff) {
+		n++
+	}
+	p.samples = p.samples[n:]
+}
+
+func (p *pegState) stats() Stats {
+	stats := Stats{Samples: len(p.samples)}
+	if len(p.samples) == 0 {
+		return stats
+	}
+
+	stats.Min, stats.Max = p.samples[0].bps, p.samples[0].bps
+	for _, s := range p.samples {
+		stats.Mean += s.bps
+		stats.Min = math.Min(stats.Min, s.bps)
+		stats.Max = math.Max(stats.Max, s.bps)
+	}
+	stats.Mean /= float64(len(p.samples))
+
+	for _, s := range p.samples {
+		stats.StdDev += (s.bps - stats.Mean) * (s.bps - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(stats.StdDev / float64(len(p.samples)))
+	return stats
+}
+
+// live returns the venues quoting a pair that are not stale.
+func (m *Monitor) live(pair string, now time.Time) []market.Quote {
+	var quotes []market.Quote
+	for _, q := range m.tracker.Venues(pair) {
+		if q.Age(now) <= m.config.MaxStaleness.Duration && q.Price > 0 {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes
+}
+
+func source(q market.Quote) string {
+	return q.Exchange + ":" + q.Pair
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// Statuses returns the current state of every monitored peg.
+func (m *Monitor) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(m.pegs))
+	for _, p := range m.pegs {
+		statuses = append(statuses, p.status)
+	}
+	return statuses
+}
@@ -0,0 +1,188 @@
+package peg
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+var usdt = exchangeconfig.PegConfig{
+	Asset:     "USDT",
+	Reference: "USD",
+	Thresholds: []exchangeconfig.PegThreshold{
+		{Name: "warning", Bps: 50, Sustain: exchangeconfig.Duration{Duration: 2 * time.Second}},
+	},
+}
+
+func storedEvents(t *testing.T, redisClient *redisclient.RedisClient) []Event {
+	t.Helper()
+	stored, err := redisClient.GetStream(EventsKey, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]Event, len(stored))
+	for i, s := range stored {
+		if err := json.Unmarshal([]byte(s), &events[len(stored)-1-i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return events
+}
+
+func TestImpliedRateFromCrossPairs(t *testing.T) {
+	tracker := market.NewTracker()
+	m := NewMonitor(tracker, nil, nil, exchangeconfig.PegMonitorConfig{Pegs: []exchangeconfig.PegConfig{usdt}})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 100})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "ETHUSD", Price: 10})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "ETHUSDT", Price: 10.1})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "USDTUSD", Price: 0.99})
+
+	observations := m.observe("USDT", "USD", time.Now())
+	if len(observations) != 3 {
+		t.Fatalf("got %d observations, want BTC, ETH and the direct market: %+v", len(observations), observations)
+	}
+	for _, o := range observations {
+		if math.Abs(o.Ratio-0.99) > 1e-4 {
+			t.Errorf("observation %+v, want a ratio near 0.99", o)
+		}
+	}
+}
+
+func TestSustainedDeviationRaisesAndRecovers(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	m := NewMonitor(tracker, redisClient, nil, exchangeconfig.PegMonitorConfig{Pegs: []exchangeconfig.PegConfig{usdt}})
+	p := m.pegs[0]
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 100})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101})
+
+	start := time.Now()
+	m.evaluate(p, start)
+	if status := m.Statuses()[0]; status.Direction != DirectionDiscount || math.Abs(status.DeviationBps+99.01) > 0.01 || len(status.Breaches) != 0 {
+		t.Errorf("unexpected status before the sustain period %+v", status)
+	}
+	if events := storedEvents(t, redisClient); len(events) != 0 {
+		t.Errorf("raised before the sustain period: %+v", events)
+	}
+
+	m.evaluate(p, start.Add(3*time.Second))
+	events := storedEvents(t, redisClient)
+	if len(events) != 1 || events[0].State != StateBreach || events[0].Threshold != "warning" || events[0].Since != start.UnixMilli() {
+		t.Fatalf("unexpected events after the sustain period %+v", events)
+	}
+	if status := m.Statuses()[0]; len(status.Breaches) != 1 || status.Stats.Samples != 2 {
+		t.Errorf("unexpected status after the sustain period %+v", status)
+	}
+
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100.1})
+	m.evaluate(p, start.Add(4*time.Second))
+	events = storedEvents(t, redisClient)
+	if len(events) != 2 || events[1].State != StateRecovered {
+		t.Errorf("unexpected events after recovering %+v", events)
+	}
+}
+
+func TestBriefDeviationDoesNotRaise(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	m := NewMonitor(tracker, redisClient, nil, exchangeconfig.PegMonitorConfig{Pegs: []exchangeconfig.PegConfig{usdt}})
+	p := m.pegs[0]
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 100})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101})
+
+	start := time.Now()
+	m.evaluate(p, start)
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100})
+	m.evaluate(p, start.Add(time.Second))
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101})
+	m.evaluate(p, start.Add(2500*time.Millisecond))
+
+	// The deviation restarted at 2.5s, so it hasn't been sustained for 2s yet
+	if events := storedEvents(t, redisClient); len(events) != 0 {
+		t.Errorf("raised on an interrupted deviation: %+v", events)
+	}
+}
+
+func TestWrappedAssetDirectionalThresholds(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	tracker := market.NewTracker()
+	wbtc := exchangeconfig.PegConfig{
+		Asset:     "WBTC",
+		Reference: "BTC",
+		Thresholds: []exchangeconfig.PegThreshold{
+			{Name: "discount", Bps: 50, Direction: DirectionDiscount},
+			{Name: "premium", Bps: 50, Direction: DirectionPremium},
+		},
+	}
+	m := NewMonitor(tracker, redisClient, nil, exchangeconfig.PegMonitorConfig{Pegs: []exchangeconfig.PegConfig{wbtc}})
+	p := m.pegs[0]
+
+	// WBTC quoted in the same currency as BTC on two venues, and directly
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "WBTCUSDT", Price: 99})
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "WBTCBTC", Price: 0.99})
+
+	now := time.Now()
+	m.evaluate(p, now)
+	status := m.Statuses()[0]
+	if len(status.Observations) != 2 || math.Abs(status.Ratio-0.99) > 1e-9 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if len(status.Breaches) != 1 || status.Breaches[0] != "discount" {
+		t.Errorf("breaches = %v, want only discount", status.Breaches)
+	}
+
+	tracker.Update(models.Trade{Exchange: "binance", Pair: "WBTCUSDT", Price: 101})
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "WBTCBTC", Price: 1.01})
+	m.evaluate(p, now.Add(time.Second))
+	status = m.Statuses()[0]
+	if len(status.Breaches) != 1 || status.Breaches[0] != "premium" {
+		t.Errorf("breaches = %v, want only premium", status.Breaches)
+	}
+	if status.Stats.Samples != 2 || math.Abs(status.Stats.Mean) > 1e-6 || math.Abs(status.Stats.StdDev-100) > 1e-6 {
+		t.Errorf("unexpected stats %+v", status.Stats)
+	}
+
+	var states []string
+	for _, e := range storedEvents(t, redisClient) {
+		states = append(states, e.Threshold+" "+e.State)
+	}
+	want := []string{"discount breach", "discount recovered", "premium breach"}
+	if len(states) != len(want) || states[0] != want[0] || states[1] != want[1] || states[2] != want[2] {
+		t.Errorf("events = %v, want %v", states, want)
+	}
+}
+
+func TestExternalObservation(t *testing.T) {
+	m := NewMonitor(market.NewTracker(), nil, nil, exchangeconfig.PegMonitorConfig{Pegs: []exchangeconfig.PegConfig{usdt}})
+	if err := m.Observe("USDC", "USD", "curve:3pool", 0.99); !errors.Is(err, ErrUnknownPeg) {
+		t.Errorf("Observe on an unmonitored peg = %v, want ErrUnknownPeg", err)
+	}
+	if err := m.Observe("USDT", "USD", "curve:3pool", 0.98); err != nil {
+		t.Fatal(err)
+	}
+
+	// No market quotes the peg, the pool alone prices it
+	now := time.Now()
+	m.evaluate(m.pegs[0], now)
+	status := m.Statuses()[0]
+	if status.Ratio != 0.98 || len(status.Observations) != 1 || status.Observations[0].Source != "curve:3pool" {
+		t.Errorf("unexpected status from the external observation %+v", status)
+	}
+
+	// Once stale it no longer counts
+	m.evaluate(m.pegs[0], now.Add(m.config.MaxStaleness.Duration+time.Second))
+	if _, ok := m.pegs[0].external["curve:3pool"]; ok {
+		t.Error("stale external observation kept")
+	}
+}
@@ -0,0 +1,92 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"sibylla_service/pkg/models"
+)
+
+// maxLine bounds a single recorded trade.
+const maxLine = 64 * 1024
+
+// Writer appends trades to a recording, one JSON object per line, so it can
+// be replayed through the analytics offline.
+type Writer struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+}
+
+// Create opens a recording for appending, creating it if needed.
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	return &Writer{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// Record appends a trade. Writes are buffered until Flush or Close.
+func (w *Writer) Record(t models.Trade) error {
+	line, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.buf.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write recording: %w", err)
+	}
+	return nil
+}
+
+// Flush writes buffered trades to the file.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Flush()
+}
+
+// Close flushes and closes the recording.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Replay calls fn with every trade of a recording in the order recorded,
+// stopping at the first error fn returns.
+func Replay(path string, fn func(models.Trade) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open recording: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), maxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var t models.Trade
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return fmt.Errorf("recording line %d: %w", line, err)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
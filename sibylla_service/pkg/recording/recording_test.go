@@ -0,0 +1,68 @@
+package recording
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sibylla_service/pkg/models"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.jsonl")
+	trades := []models.Trade{
+		{Exchange: "binance", Pair: "BTCUSDT", Price: 100.5, Quantity: 0.25, Timestamp: 1000, ReceivedAt: 1003},
+		{Exchange: "kraken", Pair: "BTCUSD", Price: 100.25, Quantity: 1, IsBuyerMaker: true, Timestamp: 1001, ReceivedAt: 1010},
+	}
+
+	// Two sessions appending to the same recording
+	for _, batch := range [][]models.Trade{trades[:1], trades[1:]} {
+		w, err := Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range batch {
+			if err := w.Record(tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var replayed []models.Trade
+	if err := Replay(path, func(tr models.Trade) error {
+		replayed = append(replayed, tr)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, trades) {
+		t.Errorf("replayed %+v, want %+v", replayed, trades)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := Replay(path, func(models.Trade) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Replay = %v after %d calls, want fn's error after the first", err, calls)
+	}
+}
+
+func TestReplayReportsBadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.jsonl")
+	if err := os.WriteFile(path, []byte("{\"exchange\":\"binance\"}\n\n{oops\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Replay(path, func(models.Trade) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Replay = %v, want an error on line 3", err)
+	}
+}
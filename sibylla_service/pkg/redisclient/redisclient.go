@@ -3,8 +3,16 @@ package redisclient
 import (
 	"context"
 	"crypto/tls"
+	"encoding"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sibylla_service/pkg/spool"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,8 +20,21 @@ import (
 
 var ctx = context.Background()
 
+// streamField is the field name values are stored under in stream entries.
+const streamField = "data"
+
+// scanCount is the number of keys ScanKeys asks Redis to look at per call.
+const scanCount = 1000
+
 type RedisClient struct {
 	client *redis.Client
+	spool  *spool.Spool
+	// down is set while Redis is known to be unreachable, so spooled writes
+	// don't each wait for a connection attempt to fail
+	down atomic.Bool
+
+	mu          sync.Mutex
+	onAvailable []func() // run once Redis is back, before the spool replays
 }
 
 func NewRedisClient(addr, password string, db int) *RedisClient {
@@ -32,13 +53,15 @@ func NewRedisClient(addr, password string, db int) *RedisClient {
 		TLSConfig: tlsConfig,
 	})
 
-	// Test the connection
-	_, err := rdb.Ping(ctx).Result()
-	if err != nil {
-		log.Fatalf("Could not connect to Redis: %v", err)
-	}
+	r := &RedisClient{client: rdb}
 
-	return &RedisClient{client: rdb}
+	// Test the connection. Without Redis the service starts anyway, writes
+	// go to the spool once enabled and are replayed when Redis answers.
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Printf("Could not connect to Redis, starting without it: %v", err)
+		r.down.Store(true)
+	}
+	return r
 }
 
 func (r *RedisClient) Set(key string, value interface{}, expiration time.Duration) error {
@@ -72,39 +95,406 @@ func (r *RedisClient) Del(key string) error {
 	return nil
 }
 
-// PushToList adds a value to the beginning of a Redis list and trims it to the specified length.
-func (r *RedisClient) PushToList(key string, value interface{}, maxLength int64) error {
-	err := r.client.LPush(ctx, key, value).Err()
+// EnableSpool buffers stream writes to the given spool while Redis is unavailable,
+// including when it was down at startup, and replays them in order once it is
+// reachable again.
+func (r *RedisClient) EnableSpool(s *spool.Spool, replayInterval time.Duration) {
+	r.spool = s
+	go r.replaySpool(replayInterval)
+}
+
+// AppendToStream adds a value to a Redis stream and trims it to at most maxCount
+// entries and entries no older than maxAge. A zero bound is not applied.
+// If a spool is enabled, the write is buffered on disk when Redis fails, and while
+// earlier writes are still waiting to be replayed so ordering is preserved.
+func (r *RedisClient) AppendToStream(key string, value interface{}, maxCount int64, maxAge time.Duration) error {
+	return r.AppendToStreamAt(key, 0, value, maxCount, maxAge)
+}
+
+// AppendToStreamAt is AppendToStream for values with a time of their own, such
+// as trades. The entry ID is that time in Unix milliseconds, so range reads,
+// depth and age trimming work on it rather than on when the value was stored,
+// which matters for spooled writes replayed late. A value older than the
+// newest entry is stored right after it, as stream IDs must increase. A zero
+// time lets Redis assign the ID.
+func (r *RedisClient) AppendToStreamAt(key string, at int64, value interface{}, maxCount int64, maxAge time.Duration) error {
+	if r.spool == nil {
+		return r.appendToStream(key, at, value, maxCount, maxAge)
+	}
+
+	if !r.down.Load() && !r.spool.Pending() {
+		err := r.appendToStream(key, at, value, maxCount, maxAge)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Redis write failed, spooling to disk: %v", err)
+		r.down.Store(true)
+	}
+
+	data, err := encodeValue(value)
 	if err != nil {
-		log.Printf("Could not push value to list %s: %v", key, err)
 		return err
 	}
+	return r.spool.Append(spool.Entry{Key: key, At: at, Value: data, MaxLength: maxCount, MaxAge: maxAge})
+}
+
+// maxIDAttempts bounds retries when another writer moves the newest entry past a late value.
+const maxIDAttempts = 3
+
+func (r *RedisClient) appendToStream(key string, at int64, value interface{}, maxCount int64, maxAge time.Duration) error {
+	id := "*"
+	if at > 0 {
+		// Redis assigns the sequence, so values at the same time never collide
+		id = strconv.FormatInt(at, 10) + "-*"
+	}
 
-	// Trim the list to the specified max length
-	err = r.client.LTrim(ctx, key, 0, maxLength-1).Err()
+	var err error
+	for attempt := 1; ; attempt++ {
+		_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: key,
+				ID:     id,
+				MaxLen: maxCount,
+				Values: map[string]interface{}{streamField: value},
+			})
+			if maxAge > 0 {
+				minID := time.Now().Add(-maxAge).UnixMilli()
+				pipe.XTrimMinID(ctx, key, strconv.FormatInt(minID, 10))
+			}
+			return nil
+		})
+		if err == nil || id == "*" || attempt == maxIDAttempts || !isIDTooSmall(err) {
+			break
+		}
+		// Only a value older than the newest entry gets here
+		if id, err = r.nextStreamID(key, at); err != nil {
+			break
+		}
+	}
 	if err != nil {
-		log.Printf("Could not trim list %s: %v", key, err)
+		log.Printf("Could not append to stream %s: %v", key, err)
 		return err
 	}
 	return nil
 }
 
-// GetList retrieves the latest items from a Redis list up to the specified max length.
-func (r *RedisClient) GetList(key string, maxLength int64) ([]string, error) {
-	vals, err := r.client.LRange(ctx, key, 0, maxLength-1).Result()
+// isIDTooSmall reports whether XADD refused an ID not above the stream's newest entry.
+func isIDTooSmall(err error) bool {
+	return strings.Contains(err.Error(), "equal or smaller than the target stream top item")
+}
+
+// nextStreamID returns an ID for a value at at (Unix ms) that goes after the
+// stream's newest entry: at that entry's time if it is later, with Redis
+// assigning the sequence.
+func (r *RedisClient) nextStreamID(key string, at int64) (string, error) {
+	top, err := r.client.XRevRangeN(ctx, key, "+", "-", 1).Result()
 	if err != nil {
-		log.Printf("Could not get list %s: %v", key, err)
+		return "", err
+	}
+	if len(top) > 0 {
+		if ms, _, ok := parseStreamID(top[0].ID); ok && ms > at {
+			at = ms
+		}
+	}
+	return strconv.FormatInt(at, 10) + "-*", nil
+}
+
+// GetStream retrieves up to count of the newest values in a Redis stream, newest first.
+func (r *RedisClient) GetStream(key string, count int64) ([]string, error) {
+	msgs, err := r.client.XRevRangeN(ctx, key, "+", "-", count).Result()
+	if err != nil {
+		log.Printf("Could not read stream %s: %v", key, err)
 		return nil, err
 	}
+
+	vals := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if v, ok := msg.Values[streamField].(string); ok {
+			vals = append(vals, v)
+		}
+	}
 	return vals, nil
 }
 
-// Keys retrieves all keys matching the given pattern.
-func (r *RedisClient) Keys(pattern string) ([]string, error) {
-	keys, err := r.client.Keys(ctx, pattern).Result()
+// StreamEntry is a value read from a stream with its entry ID ("<ms>-<seq>").
+type StreamEntry struct {
+	ID    string
+	Value string
+}
+
+// GetStreamRange retrieves up to count values of a stream with IDs between
+// oldest and newest, newest first. Bounds are entry IDs or Unix milliseconds,
+// "-" and "+" leave them open and a "(" prefix makes them exclusive.
+func (r *RedisClient) GetStreamRange(key, newest, oldest string, count int64) ([]StreamEntry, error) {
+	msgs, err := r.client.XRevRangeN(ctx, key, newest, oldest, count).Result()
+	if err != nil {
+		log.Printf("Could not read stream %s: %v", key, err)
+		return nil, err
+	}
+
+	entries := make([]StreamEntry, 0, len(msgs))
+	for _, msg := range msgs {
+		if v, ok := msg.Values[streamField].(string); ok {
+			entries = append(entries, StreamEntry{ID: msg.ID, Value: v})
+		}
+	}
+	return entries, nil
+}
+
+// StreamDepth describes how much history a stream currently holds.
+type StreamDepth struct {
+	Count  int64
+	Oldest time.Time
+	Newest time.Time
+}
+
+// GetStreamDepth returns the number of entries in a stream and the time span they cover.
+func (r *RedisClient) GetStreamDepth(key string) (StreamDepth, error) {
+	var depth StreamDepth
+
+	count, err := r.client.XLen(ctx, key).Result()
+	if err != nil {
+		log.Printf("Could not get length of stream %s: %v", key, err)
+		return depth, err
+	}
+	depth.Count = count
+	if count == 0 {
+		return depth, nil
+	}
+
+	oldest, err := r.client.XRangeN(ctx, key, "-", "+", 1).Result()
 	if err != nil {
-		log.Printf("Could not retrieve keys with pattern %s: %v", pattern, err)
+		return depth, err
+	}
+	newest, err := r.client.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	if err != nil {
+		return depth, err
+	}
+	if len(oldest) > 0 {
+		depth.Oldest = streamIDTime(oldest[0].ID)
+	}
+	if len(newest) > 0 {
+		depth.Newest = streamIDTime(newest[0].ID)
+	}
+	return depth, nil
+}
+
+// ScanKeys retrieves all keys matching the given pattern with SCAN, which
+// unlike KEYS doesn't block Redis while it walks the keyspace. A key may be
+// returned more than once.
+func (r *RedisClient) ScanKeys(pattern string) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Could not scan keys with pattern %s: %v", pattern, err)
 		return nil, err
 	}
 	return keys, nil
 }
+
+// OnAvailable runs fn once Redis is reachable: right away if it is, otherwise
+// when the spool replay finds it back, before any write reaches it. Use it for
+// startup work such as migrations. Waiting for Redis needs EnableSpool.
+func (r *RedisClient) OnAvailable(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.down.Load() {
+		fn()
+		return
+	}
+	r.onAvailable = append(r.onAvailable, fn)
+}
+
+func (r *RedisClient) runOnAvailable() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fn := range r.onAvailable {
+		fn()
+	}
+	r.onAvailable = nil
+}
+
+// ConvertListToStream replaces a list written newest first, as trades once
+// were, with a stream of its values oldest first. at gives each value's entry
+// time, see AppendToStreamAt. Keys that aren't lists are left alone. It
+// returns the number of values converted.
+func (r *RedisClient) ConvertListToStream(key string, at func(value string) int64) (int, error) {
+	keyType, err := r.client.Type(ctx, key).Result()
+	if err != nil || keyType != "list" {
+		return 0, err
+	}
+	values, err := r.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Build the stream aside and swap it in, so a failure leaves the list intact
+	tmp := key + ":converting"
+	if err := r.client.Del(ctx, tmp).Err(); err != nil {
+		return 0, err
+	}
+	for i := len(values) - 1; i >= 0; i-- {
+		if err := r.appendToStream(tmp, at(values[i]), values[i], 0, 0); err != nil {
+			return 0, err
+		}
+	}
+	if len(values) == 0 {
+		return 0, r.client.Del(ctx, key).Err()
+	}
+	if err := r.client.Rename(ctx, tmp, key).Err(); err != nil {
+		return 0, err
+	}
+	return len(values), nil
+}
+
+// replaySpool periodically drains the spool back into Redis.
+func (r *RedisClient) replaySpool(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !r.spool.Pending() && !r.down.Load() {
+			continue
+		}
+		if err := r.client.Ping(ctx).Err(); err != nil {
+			continue
+		}
+		if r.down.Load() {
+			// Startup work waits for Redis and must precede every write
+			r.runOnAvailable()
+			r.down.Store(false)
+			log.Printf("Redis is reachable again")
+		}
+
+		n, err := r.spool.Replay(func(e spool.Entry) error {
+			err := r.appendToStream(e.Key, e.At, e.Value, e.MaxLength, e.MaxAge)
+			if isRejected(err) {
+				return fmt.Errorf("%w: %v", spool.ErrRejected, err)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("Spool replay stopped after %d entries: %v", n, err)
+		} else if n > 0 {
+			log.Printf("Replayed %d spooled writes to Redis", n)
+		}
+	}
+}
+
+// transientReplies are error replies Redis gives while it can't serve a write
+// for now, as opposed to rejecting the write itself.
+var transientReplies = []string{"LOADING", "BUSY", "READONLY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN", "OOM"}
+
+// isRejected reports whether Redis refused a write outright, such as with
+// WRONGTYPE, so retrying it can't succeed.
+func isRejected(err error) bool {
+	var reply redis.Error
+	if !errors.As(err, &reply) || err == redis.Nil {
+		return false
+	}
+	for _, prefix := range transientReplies {
+		if strings.HasPrefix(reply.Error(), prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeValue converts a value into the bytes Redis would store for it.
+func encodeValue(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("cannot spool value of type %T", value)
+	}
+}
+
+// streamIDTime extracts the millisecond timestamp from a stream entry ID ("<ms>-<seq>").
+func streamIDTime(id string) time.Time {
+	ms, _, ok := parseStreamID(id)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func parseStreamID(id string) (ms, seq int64, ok bool) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, err := strconv.ParseInt(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if seqPart != "" {
+		if seq, err = strconv.ParseInt(seqPart, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return ms, seq, true
+}
+
+// ReplaceInSortedSet stores value at score, replacing any existing member with the
+// same score, and keeps at most maxCount members with the highest scores.
+func (r *RedisClient) ReplaceInSortedSet(key string, score float64, value interface{}, maxCount int64) error {
+	scoreStr := strconv.FormatFloat(score, 'f', -1, 64)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, scoreStr, scoreStr)
+		pipe.ZAdd(ctx, key, &redis.Z{Score: score, Member: value})
+		if maxCount > 0 {
+			pipe.ZRemRangeByRank(ctx, key, 0, -maxCount-1)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not write to sorted set %s: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// GetSortedSet retrieves up to count members with the highest scores, highest first.
+func (r *RedisClient) GetSortedSet(key string, count int64) ([]string, error) {
+	vals, err := r.client.ZRevRange(ctx, key, 0, count-1).Result()
+	if err != nil {
+		log.Printf("Could not get sorted set %s: %v", key, err)
+		return nil, err
+	}
+	return vals, nil
+}
+
+// SetInHash stores value under field of a hash.
+func (r *RedisClient) SetInHash(key, field string, value interface{}) error {
+	err := r.client.HSet(ctx, key, field, value).Err()
+	if err != nil {
+		log.Printf("Could not set %s in hash %s: %v", field, key, err)
+		return err
+	}
+	return nil
+}
+
+// GetHash retrieves every field of a hash.
+func (r *RedisClient) GetHash(key string) (map[string]string, error) {
+	vals, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		log.Printf("Could not get hash %s: %v", key, err)
+		return nil, err
+	}
+	return vals, nil
+}
+
+// DeleteFromHash removes a field from a hash.
+func (r *RedisClient) DeleteFromHash(key, field string) error {
+	err := r.client.HDel(ctx, key, field).Err()
+	if err != nil {
+		log.Printf("Could not delete %s from hash %s: %v", field, key, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package redisclient
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"sibylla_service/pkg/spool"
+)
+
+// freeAddr returns a local address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestStartsWithoutRedisAndReplaysSpool(t *testing.T) {
+	addr := freeAddr(t)
+	r := NewRedisClient(addr, "", 0)
+	if !r.down.Load() {
+		t.Fatal("client should start in spool mode when Redis is unreachable")
+	}
+
+	s, err := spool.Open(filepath.Join(t.TempDir(), "trades.spool"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// Startup work waits for Redis and runs before the spool is replayed
+	ranBeforeReplay := make(chan bool, 1)
+	r.OnAvailable(func() { ranBeforeReplay <- s.Pending() })
+	r.EnableSpool(s, 10*time.Millisecond)
+
+	if err := r.AppendToStream("trades:binance:BTCUSDT", "first", 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Pending() {
+		t.Fatal("write should have been spooled")
+	}
+
+	m := miniredis.NewMiniRedis()
+	if err := m.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Pending() || r.down.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("spool was not replayed once Redis came up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case pending := <-ranBeforeReplay:
+		if !pending {
+			t.Error("OnAvailable ran after the spool was replayed")
+		}
+	default:
+		t.Error("OnAvailable did not run once Redis came up")
+	}
+
+	if err := r.AppendToStream("trades:binance:BTCUSDT", "second", 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	values, err := r.GetStream("trades:binance:BTCUSDT", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != "second" || values[1] != "first" {
+		t.Fatalf("stream holds %v, want [second first]", values)
+	}
+}
+
+func TestReplaySetsAsideRejectedWrites(t *testing.T) {
+	m := miniredis.RunT(t)
+	r := NewRedisClient(m.Addr(), "", 0)
+	s, err := spool.Open(filepath.Join(t.TempDir(), "trades.spool"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A key of the wrong type fails every replay, the writes after it must still land
+	m.Set("trades:binance:BTCUSDT", "not a stream")
+	for _, key := range []string{"trades:binance:BTCUSDT", "trades:kraken:BTCUSD"} {
+		if err := s.Append(spool.Entry{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.EnableSpool(s, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Pending() {
+		if time.Now().After(deadline) {
+			t.Fatal("spool replay is stuck on a rejected write")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if values, err := r.GetStream("trades:kraken:BTCUSD", 10); err != nil || len(values) != 1 {
+		t.Fatalf("write after the rejected one got %v, %v", values, err)
+	}
+}
+
+func newTestClient(t *testing.T) *RedisClient {
+	t.Helper()
+	return NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+}
+
+// streamIDs returns the IDs of a stream's entries, newest first.
+func streamIDs(t *testing.T, r *RedisClient, key string) []string {
+	t.Helper()
+	msgs, err := r.client.XRevRange(ctx, key, "+", "-").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestAppendToStreamAtKeysEntriesByTime(t *testing.T) {
+	r := newTestClient(t)
+	key := "trades:binance:BTCUSDT"
+	now := time.Now().UnixMilli()
+
+	for _, at := range []int64{now - 2000, now - 1000, now - 1500, now - 1000} {
+		if err := r.AppendToStreamAt(key, at, strconv.FormatInt(at, 10), 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := streamIDs(t, r, key)
+	// Newest first; the late trade and the repeated time go right after the newest entry
+	want := []string{
+		fmt.Sprintf("%d-2", now-1000),
+		fmt.Sprintf("%d-1", now-1000),
+		fmt.Sprintf("%d-0", now-1000),
+		fmt.Sprintf("%d-0", now-2000),
+	}
+	if len(ids) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(ids), len(want))
+	}
+	for i, id := range ids {
+		if id != want[i] {
+			t.Errorf("entry %d has ID %s, want %s", i, id, want[i])
+		}
+	}
+
+	depth, err := r.GetStreamDepth(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth.Oldest.UnixMilli() != now-2000 || depth.Newest.UnixMilli() != now-1000 {
+		t.Errorf("depth spans %v to %v, want the trade times", depth.Oldest, depth.Newest)
+	}
+}
+
+func TestConcurrentAppendsAtTheSameTimeAreAllKept(t *testing.T) {
+	r := newTestClient(t)
+	key := "trades:binance:BTCUSDT"
+	now := time.Now().UnixMilli()
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				// Every writer hits the same millisecond, some of them late
+				at := now - int64(w%2)*1000
+				errs <- r.AppendToStreamAt(key, at, fmt.Sprintf("%d-%d", w, i), 0, 0)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := streamIDs(t, r, key); len(ids) != writers*perWriter {
+		t.Fatalf("stream holds %d entries, want %d", len(ids), writers*perWriter)
+	}
+}
+
+func TestAppendToStreamAtTrimsByEntryTime(t *testing.T) {
+	r := newTestClient(t)
+	key := "trades:kraken:BTCUSD"
+	now := time.Now()
+
+	old := now.Add(-2 * time.Hour).UnixMilli()
+	recent := now.Add(-time.Minute).UnixMilli()
+	if err := r.AppendToStreamAt(key, old, "old", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AppendToStreamAt(key, recent, "recent", 0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := r.GetStream(key, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values[0] != "recent" {
+		t.Fatalf("stream holds %v, want only the trade within the max age", values)
+	}
+}
+
+func TestConvertListToStream(t *testing.T) {
+	m := miniredis.RunT(t)
+	r := NewRedisClient(m.Addr(), "", 0)
+	key := "trades:binance:ETHUSDT"
+
+	// Lists were pushed newest first
+	for _, v := range []string{"1000", "2000", "3000"} {
+		m.Lpush(key, v)
+	}
+	n, err := r.ConvertListToStream(key, func(v string) int64 {
+		at, _ := strconv.ParseInt(v, 10, 64)
+		return at
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("converted %d values, want 3", n)
+	}
+
+	ids := streamIDs(t, r, key)
+	for i, want := range []string{"3000-0", "2000-0", "1000-0"} {
+		if i >= len(ids) || ids[i] != want {
+			t.Errorf("entries have IDs %v, want %s at %d", ids, want, i)
+		}
+	}
+
+	// Streams are left alone
+	if n, err := r.ConvertListToStream(key, nil); err != nil || n != 0 {
+		t.Fatalf("converting a stream returned %d, %v", n, err)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	r := newTestClient(t)
+	for i := 0; i < 2500; i++ {
+		if err := r.Set("trades:binance:P"+strconv.Itoa(i), "1", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Set("candles:binance:P0:1m", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// More keys than one SCAN call looks at
+	keys, err := r.ScanKeys("trades:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		seen[key] = true
+	}
+	if len(seen) != 2500 || !seen["trades:binance:P2499"] {
+		t.Errorf("scanned %d distinct keys, want 2500", len(seen))
+	}
+}
@@ -0,0 +1,312 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/metrics"
+)
+
+// ErrFull is returned by Append when the spool has reached its size cap.
+var ErrFull = errors.New("spool is full")
+
+// ErrRejected is wrapped by a Replay callback's error when the store will never
+// accept the entry, so retrying it would hold up the rest of the spool.
+var ErrRejected = errors.New("entry rejected")
+
+// Entry is a single pending write to the store.
+type Entry struct {
+	Key       string        `json:"key"`
+	At        int64         `json:"at,omitempty"` // entry time in Unix ms, zero for Redis to assign
+	Value     []byte        `json:"value"`
+	MaxLength int64         `json:"max_length"`
+	MaxAge    time.Duration `json:"max_age,omitempty"`
+}
+
+// Spool is an append-only file of entries that could not be written to Redis.
+// Entries are replayed in the order they were appended. The replay position is
+// kept in a sidecar ".offset" file so a restart does not replay entries twice.
+type Spool struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64 // bytes written to the spool file
+	offset   int64 // bytes already replayed
+	maxBytes int64
+	full     bool
+	fulls    int64 // times the cap was hit
+	dropped  int64
+	rejected int64 // entries moved to the rejected file
+
+	// OnFull is called once each time the spool hits its size cap.
+	OnFull func(size int64)
+}
+
+// Open opens (or creates) the spool file at path. maxBytes caps the size of
+// the file; zero means no cap.
+func Open(path string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open spool file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat spool file: %w", err)
+	}
+
+	s := &Spool{
+		path:     path,
+		file:     file,
+		size:     info.Size(),
+		maxBytes: maxBytes,
+	}
+	s.offset = s.readOffset()
+	if s.offset > s.size {
+		s.offset = 0
+	}
+
+	if s.Pending() {
+		log.Printf("Spool %s has %d bytes pending replay", path, s.size-s.offset)
+	}
+	return s, nil
+}
+
+// Append writes an entry to the end of the spool.
+func (s *Spool) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal spool entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.size+int64(len(line)) > s.maxBytes {
+		s.dropped++
+		if !s.full {
+			s.full = true
+			s.fulls++
+			log.Printf("ALERT: spool %s reached its cap of %d bytes, dropping writes", s.path, s.maxBytes)
+			if s.OnFull != nil {
+				s.OnFull(s.size)
+			}
+		}
+		return ErrFull
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write spool entry: %w", err)
+	}
+	return nil
+}
+
+// Pending reports whether there are entries waiting to be replayed.
+func (s *Spool) Pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size > s.offset
+}
+
+// Size returns the number of bytes waiting to be replayed.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.offset
+}
+
+// Dropped returns how many entries were rejected because the spool was full.
+func (s *Spool) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Replay calls fn for every pending entry in order. It stops at the first error
+// returned by fn, leaving that entry and everything after it in the spool,
+// unless the error wraps ErrRejected. Rejected entries and lines that don't
+// decode are moved to a ".rejected" file beside the spool for inspection and
+// the replay carries on. Entries appended while a replay is running are picked
+// up by the same replay.
+func (s *Spool) Replay(fn func(Entry) error) (int, error) {
+	reader, err := os.Open(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("open spool for replay: %w", err)
+	}
+	defer reader.Close()
+
+	s.mu.Lock()
+	offset := s.offset
+	s.mu.Unlock()
+
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek spool: %w", err)
+	}
+
+	replayed := 0
+	buf := bufio.NewReader(reader)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial line is an append in progress, leave it for the next replay
+			break
+		}
+		if err != nil {
+			s.commit(offset)
+			return replayed, fmt.Errorf("read spool: %w", err)
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			log.Printf("Skipping corrupt spool entry at offset %d: %v", offset, err)
+			s.reject(line)
+			offset += int64(len(line))
+			continue
+		}
+
+		if err := fn(e); errors.Is(err, ErrRejected) {
+			log.Printf("Skipping spool entry for %s at offset %d: %v", e.Key, offset, err)
+			s.reject(line)
+			offset += int64(len(line))
+			continue
+		} else if err != nil {
+			s.commit(offset)
+			return replayed, err
+		}
+		offset += int64(len(line))
+		replayed++
+
+		// Persist progress periodically so a crash only replays a small tail
+		if replayed%1000 == 0 {
+			s.commit(offset)
+		}
+	}
+
+	s.commit(offset)
+	return replayed, nil
+}
+
+// reject appends a spool line to the rejected file.
+func (s *Spool) reject(line []byte) {
+	s.mu.Lock()
+	s.rejected++
+	s.mu.Unlock()
+
+	f, err := os.OpenFile(s.rejectedPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Printf("Could not open rejected spool file: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(line); err != nil {
+		log.Printf("Could not keep rejected spool entry: %v", err)
+	}
+}
+
+// commit records the replay position and truncates the spool once it is drained.
+func (s *Spool) commit(offset int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset = offset
+	if s.offset < s.size {
+		s.writeOffset()
+		return
+	}
+
+	if err := s.file.Truncate(0); err != nil {
+		log.Printf("Could not truncate spool %s: %v", s.path, err)
+		s.writeOffset()
+		return
+	}
+	s.size = 0
+	s.offset = 0
+	s.full = false
+	os.Remove(s.offsetPath())
+}
+
+// Close closes the spool file. Pending entries stay on disk.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *Spool) offsetPath() string {
+	return s.path + ".offset"
+}
+
+func (s *Spool) rejectedPath() string {
+	return s.path + ".rejected"
+}
+
+func (s *Spool) readOffset() int64 {
+	data, err := os.ReadFile(s.offsetPath())
+	if err != nil {
+		return 0
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid spool offset file: %v", err)
+		return 0
+	}
+	return offset
+}
+
+func (s *Spool) writeOffset() {
+	err := os.WriteFile(s.offsetPath(), []byte(strconv.FormatInt(s.offset, 10)), 0o644)
+	if err != nil {
+		log.Printf("Could not persist spool offset: %v", err)
+	}
+}
+
+// Metrics reports the spool's backlog and the writes it lost or set aside.
+func (s *Spool) Metrics() []metrics.Family {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	full := 0.0
+	if s.full {
+		full = 1
+	}
+	return []metrics.Family{
+		{
+			Name: "sibylla_spool_pending_bytes", Help: "Bytes of spooled writes waiting for Redis.", Type: metrics.Gauge,
+			Samples: []metrics.Sample{{Value: float64(s.size - s.offset)}},
+		},
+		{
+			Name: "sibylla_spool_full", Help: "Whether the spool is at its size cap and dropping writes.", Type: metrics.Gauge,
+			Samples: []metrics.Sample{{Value: full}},
+		},
+		{
+			Name: "sibylla_spool_full_total", Help: "Times the spool reached its size cap.", Type: metrics.Counter,
+			Samples: []metrics.Sample{{Value: float64(s.fulls)}},
+		},
+		{
+			Name: "sibylla_spool_dropped_total", Help: "Writes dropped because the spool was full.", Type: metrics.Counter,
+			Samples: []metrics.Sample{{Value: float64(s.dropped)}},
+		},
+		{
+			Name: "sibylla_spool_rejected_total", Help: "Spooled writes set aside because Redis rejected them or they were corrupt.", Type: metrics.Counter,
+			Samples: []metrics.Sample{{Value: float64(s.rejected)}},
+		},
+	}
+}
@@ -0,0 +1,201 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openSpool(t *testing.T, maxBytes int64) (*Spool, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trades.spool")
+	s, err := Open(path, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func appendKeys(t *testing.T, s *Spool, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := s.Append(Entry{Key: key, Value: []byte("v-" + key), MaxLength: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func replayKeys(t *testing.T, s *Spool, failAt string) ([]string, error) {
+	t.Helper()
+	var keys []string
+	_, err := s.Replay(func(e Entry) error {
+		if e.Key == failAt {
+			return errors.New("redis down")
+		}
+		keys = append(keys, e.Key)
+		return nil
+	})
+	return keys, err
+}
+
+func TestReplayResumesFromOffsetAfterRestart(t *testing.T) {
+	s, path := openSpool(t, 0)
+	appendKeys(t, s, "a", "b", "c")
+
+	keys, err := replayKeys(t, s, "b")
+	if err == nil {
+		t.Fatal("replay should stop at the failing entry")
+	}
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("replayed %v, want [a]", keys)
+	}
+	if _, err := os.Stat(path + ".offset"); err != nil {
+		t.Fatalf("offset was not persisted: %v", err)
+	}
+	s.Close()
+
+	reopened, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if !reopened.Pending() {
+		t.Fatal("reopened spool should have pending entries")
+	}
+	keys, err = replayKeys(t, reopened, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Fatalf("replayed %v after restart, want [b c]", keys)
+	}
+}
+
+func TestReplayTruncatesOnceDrained(t *testing.T) {
+	s, path := openSpool(t, 0)
+	appendKeys(t, s, "a", "b")
+	if _, err := replayKeys(t, s, "b"); err == nil {
+		t.Fatal("replay should stop at the failing entry")
+	}
+	if _, err := replayKeys(t, s, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Pending() || s.Size() != 0 {
+		t.Fatalf("drained spool still has %d bytes pending", s.Size())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("spool file is %d bytes after draining, want 0", info.Size())
+	}
+	if _, err := os.Stat(path + ".offset"); !os.IsNotExist(err) {
+		t.Fatalf("offset file should be removed after draining, stat: %v", err)
+	}
+
+	// Appends after a truncation replay from the start of the file
+	appendKeys(t, s, "c")
+	keys, err := replayKeys(t, s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "c" {
+		t.Fatalf("replayed %v, want [c]", keys)
+	}
+}
+
+func TestAppendStopsAtCap(t *testing.T) {
+	s, _ := openSpool(t, 0)
+	appendKeys(t, s, "a")
+	entrySize := s.Size()
+
+	capped, _ := openSpool(t, 2*entrySize)
+	fulls := 0
+	capped.OnFull = func(int64) { fulls++ }
+	appendKeys(t, capped, "a", "b")
+
+	for i := 0; i < 3; i++ {
+		if err := capped.Append(Entry{Key: "c", Value: []byte("v-c"), MaxLength: 10}); !errors.Is(err, ErrFull) {
+			t.Fatalf("append past the cap returned %v, want ErrFull", err)
+		}
+	}
+	if fulls != 1 {
+		t.Fatalf("OnFull called %d times, want once per time the cap is hit", fulls)
+	}
+	if capped.Dropped() != 3 {
+		t.Fatalf("dropped %d, want 3", capped.Dropped())
+	}
+
+	// Draining frees the spool and re-arms the alert
+	if _, err := replayKeys(t, capped, ""); err != nil {
+		t.Fatal(err)
+	}
+	appendKeys(t, capped, "d", "e")
+	if err := capped.Append(Entry{Key: "f", Value: []byte("v-f"), MaxLength: 10}); !errors.Is(err, ErrFull) {
+		t.Fatalf("append past the cap returned %v, want ErrFull", err)
+	}
+	if fulls != 2 {
+		t.Fatalf("OnFull called %d times, want 2 after the spool filled again", fulls)
+	}
+}
+
+func TestReplaySkipsCorruptEntries(t *testing.T) {
+	s, path := openSpool(t, 0)
+	appendKeys(t, s, "a")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+	s.size += int64(len("not json\n"))
+	appendKeys(t, s, "b")
+
+	keys, err := replayKeys(t, s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("replayed %v, want [a b]", keys)
+	}
+	if rejected, _ := os.ReadFile(path + ".rejected"); string(rejected) != "not json\n" {
+		t.Errorf("rejected file holds %q, want the corrupt line", rejected)
+	}
+}
+
+func TestReplaySetsAsideRejectedEntries(t *testing.T) {
+	s, path := openSpool(t, 0)
+	appendKeys(t, s, "a", "b", "c")
+
+	var keys []string
+	n, err := s.Replay(func(e Entry) error {
+		if e.Key == "b" {
+			return fmt.Errorf("%w: WRONGTYPE", ErrRejected)
+		}
+		keys = append(keys, e.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("replayed %d %v, want [a c]", n, keys)
+	}
+	if s.Pending() {
+		t.Error("a rejected entry held up the spool")
+	}
+	if rejected, _ := os.ReadFile(path + ".rejected"); !strings.Contains(string(rejected), `"key":"b"`) {
+		t.Errorf("rejected file holds %q, want entry b", rejected)
+	}
+	for _, f := range s.Metrics() {
+		if f.Name == "sibylla_spool_rejected_total" && f.Samples[0].Value != 1 {
+			t.Errorf("%s is %v, want 1", f.Name, f.Samples[0].Value)
+		}
+	}
+}
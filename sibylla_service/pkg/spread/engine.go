@@ -0,0 +1,213 @@
+package spread
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// Spread is the price difference for one market between two exchanges. Pair
+// is the market (see models.CanonicalPair) and PairA and PairB the pairs each
+// exchange quotes it in, so BTCUSDT on one venue meets BTCUSD on another.
+// ExchangeA sorts before ExchangeB, and Absolute is PriceA - PriceB.
+type Spread struct {
+	Pair      string  `json:"pair"`
+	PairA     string  `json:"pair_a"`
+	PairB     string  `json:"pair_b"`
+	ExchangeA string  `json:"exchange_a"`
+	ExchangeB string  `json:"exchange_b"`
+	PriceA    float64 `json:"price_a"`
+	PriceB    float64 `json:"price_b"`
+	Absolute  float64 `json:"absolute"`
+	Bps       float64 `json:"bps"` // Absolute relative to the mid of the two prices
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (s Spread) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Key is the store key holding the time series of a spread. pair is the canonical pair.
+func Key(pair, exchangeA, exchangeB string) string {
+	return "spreads:" + pair + ":" + exchangeA + ":" + exchangeB
+}
+
+func (s Spread) key() string {
+	return Key(s.Pair, s.ExchangeA, s.ExchangeB)
+}
+
+// Engine computes spreads for every pair of exchanges quoting the same market,
+// recomputing on each trade from the latest quotes in the tracker, or from
+// their rolling averages when the config asks for them.
+type Engine struct {
+	mu          sync.RWMutex
+	config      exchangeconfig.SpreadConfig
+	tracker     *market.Tracker
+	averages    *average.Calculator // nil uses last trade prices
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	current     map[string]Spread    // by key
+	lastStored  map[string]time.Time // by key
+}
+
+func NewEngine(tracker *market.Tracker, averages *average.Calculator, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.SpreadConfig) *Engine {
+	return &Engine{
+		config:      config.WithDefaults(),
+		tracker:     tracker,
+		averages:    averages,
+		redisClient: redisClient,
+		bus:         b,
+		current:     make(map[string]Spread),
+		lastStored:  make(map[string]time.Time),
+	}
+}
+
+// OnTrade recomputes the spreads between the trade's exchange and every other
+// exchange quoting the same market, in the trade's pair or one pegged to it.
+// The tracker must already hold the trade.
+func (e *Engine) OnTrade(t models.Trade) {
+	now := time.Now()
+	self, ok := e.tracker.Last(t.Exchange, t.Pair)
+	if !ok {
+		return
+	}
+
+	canonical := models.CanonicalPair(t.Pair)
+	for _, other := range e.tracker.Market(t.Pair) {
+		if other.Exchange == t.Exchange {
+			continue
+		}
+
+		key := Key(canonical, minString(t.Exchange, other.Exchange), maxString(t.Exchange, other.Exchange))
+		if other.Age(now) > e.config.MaxStaleness.Duration {
+			e.drop(key)
+			continue
+		}
+
+		a, b := self, other
+		if b.Exchange < a.Exchange {
+			a, b = b, a
+		}
+		a.Price = e.averages.Price(a, e.config.Price)
+		b.Price = e.averages.Price(b, e.config.Price)
+		e.update(compute(canonical, a, b, now), now)
+	}
+}
+
+func compute(pair string, a, b market.Quote, now time.Time) Spread {
+	s := Spread{
+		Pair:      pair,
+		PairA:     a.Pair,
+		PairB:     b.Pair,
+		ExchangeA: a.Exchange,
+		ExchangeB: b.Exchange,
+		PriceA:    a.Price,
+		PriceB:    b.Price,
+		Absolute:  a.Price - b.Price,
+		Timestamp: now.UnixMilli(),
+	}
+	if mid := (a.Price + b.Price) / 2; mid > 0 {
+		s.Bps = s.Absolute / mid * 10000
+	}
+	return s
+}
+
+func (e *Engine) update(s Spread, now time.Time) {
+	key := s.key()
+
+	e.mu.Lock()
+	e.current[key] = s
+	store := now.Sub(e.lastStored[key]) >= e.config.SampleInterval.Duration
+	if store {
+		e.lastStored[key] = now
+	}
+	e.mu.Unlock()
+
+	if e.bus != nil {
+		e.bus.Publish(bus.TopicSpreads, s)
+	}
+	if store && e.redisClient != nil {
+		if err := e.redisClient.AppendToStream(key, s, e.config.MaxStored, 0); err != nil {
+			log.Printf("Could not store spread %s: %v", key, err)
+		}
+	}
+}
+
+func (e *Engine) drop(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.current, key)
+}
+
+// Current returns the live spreads, optionally filtered by the market a pair
+// prices, sorted by key. Spreads whose quotes have gone stale are left out.
+func (e *Engine) Current(pair string) []Spread {
+	now := time.Now()
+	if pair != "" {
+		pair = models.CanonicalPair(pair)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	spreads := make([]Spread, 0, len(e.current))
+	for _, s := range e.current {
+		if pair != "" && s.Pair != pair {
+			continue
+		}
+		if e.isStale(s, now) {
+			continue
+		}
+		spreads = append(spreads, s)
+	}
+	sort.Slice(spreads, func(i, j int) bool { return spreads[i].key() < spreads[j].key() })
+	return spreads
+}
+
+// Widest returns the live spread with the largest absolute bps.
+func (e *Engine) Widest() (Spread, bool) {
+	var widest Spread
+	found := false
+	for _, s := range e.Current("") {
+		if !found || math.Abs(s.Bps) > math.Abs(widest.Bps) {
+			widest = s
+			found = true
+		}
+	}
+	return widest, found
+}
+
+func (e *Engine) isStale(s Spread, now time.Time) bool {
+	for _, leg := range [][2]string{{s.ExchangeA, s.PairA}, {s.ExchangeB, s.PairB}} {
+		q, ok := e.tracker.Last(leg[0], leg[1])
+		if !ok || q.Age(now) > e.config.MaxStaleness.Duration {
+			return true
+		}
+	}
+	return false
+}
+
+func minString(a, b string) string {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxString(a, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
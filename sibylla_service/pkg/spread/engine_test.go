@@ -0,0 +1,67 @@
+package spread
+
+import (
+	"testing"
+
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+)
+
+func newTestEngine() (*Engine, *market.Tracker) {
+	tracker := market.NewTracker()
+	return NewEngine(tracker, nil, nil, nil, exchangeconfig.SpreadConfig{}), tracker
+}
+
+func trade(tracker *market.Tracker, e *Engine, exchange, pair string, price float64) {
+	t := models.Trade{Exchange: exchange, Pair: pair, Price: price, Quantity: 1}
+	tracker.Update(t)
+	e.OnTrade(t)
+}
+
+func TestSpreadAcrossPeggedQuotes(t *testing.T) {
+	e, tracker := newTestEngine()
+	trade(tracker, e, "kraken", "BTCUSD", 100)
+	trade(tracker, e, "binance", "BTCUSDT", 101)
+
+	for _, pair := range []string{"BTCUSDT", "BTCUSD", ""} {
+		spreads := e.Current(pair)
+		if len(spreads) != 1 {
+			t.Fatalf("Current(%q) returned %d spreads, want 1", pair, len(spreads))
+		}
+		s := spreads[0]
+		if s.Pair != "BTCUSD" || s.ExchangeA != "binance" || s.PairA != "BTCUSDT" || s.ExchangeB != "kraken" || s.PairB != "BTCUSD" {
+			t.Errorf("unexpected spread %+v", s)
+		}
+		if s.Absolute != 1 || s.Bps < 99.5 || s.Bps > 99.6 {
+			t.Errorf("spread of 101 over 100 is %v (%v bps)", s.Absolute, s.Bps)
+		}
+	}
+	if spreads := e.Current("ETHUSDT"); len(spreads) != 0 {
+		t.Errorf("Current(ETHUSDT) = %+v, want none", spreads)
+	}
+}
+
+func TestSpreadIgnoresOtherMarkets(t *testing.T) {
+	e, tracker := newTestEngine()
+	trade(tracker, e, "kraken", "ETHUSD", 5)
+	trade(tracker, e, "binance", "BTCUSDT", 100)
+	trade(tracker, e, "binance", "ETHBTC", 0.05)
+
+	if spreads := e.Current(""); len(spreads) != 0 {
+		t.Errorf("got spreads between different markets: %+v", spreads)
+	}
+}
+
+func TestWidest(t *testing.T) {
+	e, tracker := newTestEngine()
+	trade(tracker, e, "kraken", "BTCUSD", 100)
+	trade(tracker, e, "binance", "BTCUSDT", 101)
+	trade(tracker, e, "kraken", "ETHUSD", 10)
+	trade(tracker, e, "binance", "ETHUSDT", 9)
+
+	widest, ok := e.Widest()
+	if !ok || widest.Pair != "ETHUSD" {
+		t.Errorf("Widest() = %+v, want the ETHUSD spread", widest)
+	}
+}
@@ -0,0 +1,501 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sibylla_service/pkg/models"
+)
+
+// maxRequestSize bounds a message from a client.
+const maxRequestSize = 4096
+
+// Client requests
+const (
+	OpSubscribe   = "subscribe"
+	OpUnsubscribe = "unsubscribe"
+	OpList        = "list"
+)
+
+// Server message types
+const (
+	TypeSubscribed    = "subscribed"
+	TypeUnsubscribed  = "unsubscribed"
+	TypeSubscriptions = "subscriptions"
+	TypeUpdate        = "update"
+	TypeSnapshot      = "snapshot"
+	TypeAggregate     = "aggregate"
+	TypeError         = "error"
+)
+
+// How a subscription caught up, reported in its acknowledgement
+const (
+	ResumeReplay   = "replay"   // buffered updates follow
+	ResumeSnapshot = "snapshot" // a snapshot message follows
+)
+
+// Request is a message from a client, e.g.
+//
+//	{"op": "subscribe", "channel": "trades", "exchanges": ["binance"], "pairs": ["BTCUSDT"]}
+//
+// Empty filters match everything. The server names the subscription unless
+// the client gives an ID, which unsubscribe refers to.
+//
+// A reconnecting client passes the epoch and the last seq it received on the
+// channel as since. The server replays the matching updates it still buffers,
+// or sends a snapshot of the latest state instead when the gap is too large
+// or the epoch has changed. Snapshot asks for a snapshot on a fresh subscribe.
+//
+// Mode throttles the subscription, see ModeTick, ModeLatest and ModeAggregate,
+// with IntervalMs between messages.
+type Request struct {
+	Op         string   `json:"op"`
+	ID         string   `json:"id,omitempty"`
+	Channel    string   `json:"channel,omitempty"`
+	Exchanges  []string `json:"exchanges,omitempty"`
+	Pairs      []string `json:"pairs,omitempty"`
+	Intervals  []string `json:"intervals,omitempty"` // candles only
+	Rules      []string `json:"rules,omitempty"`     // alerts only
+	Since      *uint64  `json:"since,omitempty"`
+	Epoch      string   `json:"epoch,omitempty"`
+	Snapshot   bool     `json:"snapshot,omitempty"`
+	Mode       string   `json:"mode,omitempty"`
+	IntervalMs int      `json:"interval_ms,omitempty"`
+}
+
+// Message is a message to a client. Updates carry the ID of the
+// subscription they matched and their sequence number on the channel, which
+// increases by one with every update published, matched or not. A snapshot's
+// Data is an array of the latest update of each key, and its Seq the
+// channel's sequence number it reflects.
+type Message struct {
+	Type          string          `json:"type"`
+	ID            string          `json:"id,omitempty"`
+	Channel       string          `json:"channel,omitempty"`
+	Seq           uint64          `json:"seq,omitempty"`
+	Epoch         string          `json:"epoch,omitempty"`
+	Resume        string          `json:"resume,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	Subscriptions []Subscription  `json:"subscriptions,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// Subscription is a client's interest in a channel, filtered by venue, pair
+// and, for candles, interval. Alerts are filtered by rule ID instead.
+type Subscription struct {
+	ID         string   `json:"id"`
+	Channel    string   `json:"channel"`
+	Exchanges  []string `json:"exchanges,omitempty"`
+	Pairs      []string `json:"pairs,omitempty"`
+	Intervals  []string `json:"intervals,omitempty"`
+	Rules      []string `json:"rules,omitempty"`
+	Mode       string   `json:"mode"`
+	IntervalMs int      `json:"interval_ms,omitempty"`
+
+	exchanges, pairs, intervals, rules map[string]bool
+	pending                            map[string]*pending // by key, throttled modes only
+	stop                               chan struct{}       // ends the flush loop
+}
+
+func (s *Subscription) throttled() bool {
+	return s.Mode != ModeTick
+}
+
+func (s *Subscription) matches(u update) bool {
+	if s.Channel != u.channel {
+		return false
+	}
+	if len(s.pairs) > 0 && !s.pairs[u.pair] {
+		return false
+	}
+	if len(s.intervals) > 0 && !s.intervals[u.interval] {
+		return false
+	}
+	if len(s.rules) > 0 && !s.rules[u.rule] {
+		return false
+	}
+	if len(s.exchanges) == 0 {
+		return true
+	}
+	for _, exchange := range u.exchanges {
+		if s.exchanges[exchange] {
+			return true
+		}
+	}
+	return false
+}
+
+type client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.Mutex
+	subs   map[string]*Subscription
+	nextID int
+}
+
+func newClient(h *Hub, conn *websocket.Conn) *client {
+	return &client{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, h.config.QueueSize),
+		done: make(chan struct{}),
+		subs: make(map[string]*Subscription),
+	}
+}
+
+// readLoop handles requests until the connection fails or closes.
+func (c *client) readLoop() {
+	defer c.close(false, websocket.CloseNormalClosure, "")
+
+	pongWait := 2 * c.hub.config.PingInterval.Duration
+	c.conn.SetReadLimit(maxRequestSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Websocket client %s: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.reply(Message{Type: TypeError, Error: "invalid request: " + err.Error()})
+			continue
+		}
+		c.handle(req)
+	}
+}
+
+func (c *client) handle(req Request) {
+	switch req.Op {
+	case OpSubscribe:
+		c.handleSubscribe(req)
+	case OpUnsubscribe:
+		c.mu.Lock()
+		sub, ok := c.subs[req.ID]
+		delete(c.subs, req.ID)
+		if ok {
+			close(sub.stop)
+		}
+		c.mu.Unlock()
+		if !ok {
+			c.reply(Message{Type: TypeError, ID: req.ID, Error: fmt.Sprintf("no subscription %q", req.ID)})
+			return
+		}
+		c.reply(Message{Type: TypeUnsubscribed, ID: sub.ID, Channel: sub.Channel})
+	case OpList:
+		c.reply(Message{Type: TypeSubscriptions, Subscriptions: c.subscriptions()})
+	default:
+		c.reply(Message{Type: TypeError, Error: fmt.Sprintf("unknown op %q, use %s, %s or %s", req.Op, OpSubscribe, OpUnsubscribe, OpList)})
+	}
+}
+
+// handleSubscribe adds a subscription and catches it up. The channel is
+// locked throughout so no update slips between the catch up and live updates.
+func (c *client) handleSubscribe(req Request) {
+	st, ok := c.hub.channels[req.Channel]
+	if !ok {
+		c.reply(Message{Type: TypeError, ID: req.ID, Channel: req.Channel,
+			Error: fmt.Sprintf("unknown channel %q, use one of %s", req.Channel, joinKeys(channelTopics))})
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sub, err := c.subscribe(req)
+	if err != nil {
+		c.reply(Message{Type: TypeError, ID: req.ID, Channel: req.Channel, Error: err.Error()})
+		return
+	}
+	ack := Message{Type: TypeSubscribed, ID: sub.ID, Channel: sub.Channel, Seq: st.seq, Epoch: c.hub.epoch, Subscriptions: []Subscription{*sub}}
+
+	var replay []update
+	snapshot := req.Snapshot
+	if req.Since != nil {
+		snapshot = true
+		if req.Epoch == c.hub.epoch {
+			if updates, ok := st.since(*req.Since); ok {
+				replay = matching(updates, sub)
+				// Replaying more than half the queue would likely cut the client off
+				snapshot = len(replay) > cap(c.send)/2
+			}
+		}
+		if snapshot {
+			replay = nil
+		} else {
+			ack.Resume = ResumeReplay
+		}
+	}
+	if snapshot {
+		ack.Resume = ResumeSnapshot
+	}
+
+	if !c.reply(ack) {
+		return
+	}
+	if sub.throttled() {
+		// Replayed updates are conflated like live ones
+		c.mu.Lock()
+		for _, u := range replay {
+			sub.collect(u)
+		}
+		c.mu.Unlock()
+		replay = nil
+	}
+	for _, u := range replay {
+		if !c.reply(Message{Type: TypeUpdate, ID: sub.ID, Channel: u.channel, Seq: u.seq, Data: u.data}) {
+			return
+		}
+	}
+	if snapshot {
+		items := make([]json.RawMessage, 0)
+		for _, u := range matching(st.snapshot(), sub) {
+			items = append(items, u.data)
+		}
+		data, _ := json.Marshal(items)
+		c.reply(Message{Type: TypeSnapshot, ID: sub.ID, Channel: sub.Channel, Seq: st.seq, Data: data})
+	}
+}
+
+func matching(updates []update, sub *Subscription) []update {
+	var result []update
+	for _, u := range updates {
+		if sub.matches(u) {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// newSubscription validates a subscribe request's filters and mode. The
+// caller names the subscription.
+func newSubscription(req Request) (*Subscription, error) {
+	if req.Channel == ChannelIndex && len(req.Exchanges) > 0 {
+		return nil, fmt.Errorf("index spans every venue and can't be filtered by exchange")
+	}
+	if req.Channel != ChannelCandles && len(req.Intervals) > 0 {
+		return nil, fmt.Errorf("only candles can be filtered by interval")
+	}
+	if req.Channel == ChannelAlerts && (len(req.Exchanges) > 0 || len(req.Pairs) > 0) {
+		return nil, fmt.Errorf("alerts can only be filtered by rule")
+	}
+	if req.Channel != ChannelAlerts && len(req.Rules) > 0 {
+		return nil, fmt.Errorf("only alerts can be filtered by rule")
+	}
+	switch req.Mode {
+	case "", ModeTick:
+		req.Mode, req.IntervalMs = ModeTick, 0
+	case ModeLatest, ModeAggregate:
+		interval := time.Duration(req.IntervalMs) * time.Millisecond
+		if interval < MinThrottleInterval || interval > MaxThrottleInterval {
+			return nil, fmt.Errorf("%s needs interval_ms from %d to %d", req.Mode, MinThrottleInterval.Milliseconds(), MaxThrottleInterval.Milliseconds())
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %q, use %s, %s or %s", req.Mode, ModeTick, ModeLatest, ModeAggregate)
+	}
+
+	pairs := set(req.Pairs)
+	if req.Channel == ChannelSpreads || req.Channel == ChannelIndex {
+		// Spreads and index prices are keyed by market, where pegged quotes are equivalent
+		pairs = make(map[string]bool, len(req.Pairs))
+		for _, pair := range req.Pairs {
+			pairs[models.CanonicalPair(pair)] = true
+		}
+	}
+
+	return &Subscription{
+		ID:         req.ID,
+		Channel:    req.Channel,
+		Exchanges:  req.Exchanges,
+		Pairs:      req.Pairs,
+		Intervals:  req.Intervals,
+		Rules:      req.Rules,
+		Mode:       req.Mode,
+		IntervalMs: req.IntervalMs,
+		exchanges:  set(req.Exchanges),
+		pairs:      pairs,
+		intervals:  set(req.Intervals),
+		rules:      set(req.Rules),
+		pending:    make(map[string]*pending),
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+func (c *client) subscribe(req Request) (*Subscription, error) {
+	sub, err := newSubscription(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sub.ID == "" {
+		for {
+			c.nextID++
+			sub.ID = "sub-" + strconv.Itoa(c.nextID)
+			if _, taken := c.subs[sub.ID]; !taken {
+				break
+			}
+		}
+	}
+	if _, exists := c.subs[sub.ID]; !exists && len(c.subs) >= c.hub.config.MaxSubscriptions {
+		return nil, fmt.Errorf("at most %d subscriptions per connection", c.hub.config.MaxSubscriptions)
+	}
+
+	// Subscribing with an existing ID replaces that subscription
+	if old, ok := c.subs[sub.ID]; ok {
+		close(old.stop)
+	}
+	c.subs[sub.ID] = sub
+	if sub.throttled() {
+		go c.flushLoop(sub)
+	}
+	return sub, nil
+}
+
+func (c *client) subscriptions() []Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(c.subs))
+	for _, s := range c.subs {
+		subs = append(subs, *s)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// deliver queues an update once for every tick subscription it matches and
+// returns how many were queued. Throttled subscriptions collect it for their
+// next flush.
+func (c *client) deliver(u update) int {
+	c.mu.Lock()
+	var ids []string
+	for id, sub := range c.subs {
+		if !sub.matches(u) {
+			continue
+		}
+		if sub.throttled() {
+			sub.collect(u)
+		} else {
+			ids = append(ids, id)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, id := range ids {
+		if !c.reply(Message{Type: TypeUpdate, ID: id, Channel: u.channel, Seq: u.seq, Data: u.data}) {
+			return 0
+		}
+	}
+	return len(ids)
+}
+
+// reply queues a message, disconnecting the client when its queue is full.
+// It returns false once the client is gone.
+func (c *client) reply(m Message) bool {
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("Could not encode websocket message: %v", err)
+		return true
+	}
+
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		log.Printf("Websocket client %s fell %d messages behind, disconnecting", c.conn.RemoteAddr(), cap(c.send))
+		c.close(true, websocket.ClosePolicyViolation, "slow consumer")
+		return false
+	}
+}
+
+// writeLoop sends queued messages and pings until the client is closed.
+func (c *client) writeLoop() {
+	ticker := time.NewTicker(c.hub.config.PingInterval.Duration)
+	defer ticker.Stop()
+
+	timeout := c.hub.config.WriteTimeout.Duration
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(timeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(false, websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout)); err != nil {
+				c.close(false, websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close disconnects the client once, telling it why when it can. It doesn't
+// wait on the network, as it may be called with a channel locked.
+func (c *client) close(slow bool, code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		c.hub.remove(c, slow)
+		go func() {
+			if code != websocket.CloseAbnormalClosure {
+				deadline := time.Now().Add(c.hub.config.WriteTimeout.Duration)
+				c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+			}
+			c.conn.Close()
+		}()
+	})
+}
+
+func set(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinKeys[V any](m map[string]V) string {
+	return strings.Join(sortedKeys(m), ", ")
+}
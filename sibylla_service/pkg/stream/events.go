@@ -0,0 +1,231 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryMs is the reconnection delay suggested to event stream clients.
+const retryMs = 1000
+
+// eventClient is a server-sent event stream of a single subscription.
+type eventClient struct {
+	sub  *Subscription
+	send chan update
+	done chan struct{} // closed when the client falls behind
+	once sync.Once
+}
+
+func (e *eventClient) deliver(u update) int {
+	if !e.sub.matches(u) {
+		return 0
+	}
+	select {
+	case e.send <- u:
+		return 1
+	default:
+		e.once.Do(func() { close(e.done) })
+		return 0
+	}
+}
+
+// ServeEvents streams a channel as server-sent events, e.g.
+//
+//	curl -N 'http://localhost:8080/events/trades?exchange=binance&pair=BTCUSDT,ETHUSDT'
+//
+// The channel is the last element of the path. The exchange, pair, interval
+// and rule query parameters filter it like the websocket subscription fields
+// and take comma-separated or repeated values.
+//
+// Updates are sent as "update" events whose ID is the hub epoch and the
+// update's sequence number. A client reconnecting with Last-Event-ID, or the
+// last_event_id query parameter where it can't set headers, gets the matching
+// updates it missed, or a "snapshot" event of the latest state when they are
+// no longer buffered or too many to replay. snapshot=true asks for a snapshot
+// on a fresh stream. Idle streams get a comment every heartbeat interval.
+func (h *Hub) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	channel := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	st, ok := h.channels[channel]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown channel %q, use one of %s", channel, joinKeys(channelTopics)), http.StatusNotFound)
+		return
+	}
+	if !h.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	sub, err := newSubscription(Request{
+		ID:        "events",
+		Channel:   channel,
+		Exchanges: queryList(query, "exchange"),
+		Pairs:     queryList(query, "pair"),
+		Intervals: queryList(query, "interval"),
+		Rules:     queryList(query, "rule"),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("last_event_id")
+	}
+
+	c := &eventClient{
+		sub:  sub,
+		send: make(chan update, h.config.QueueSize),
+		done: make(chan struct{}),
+	}
+
+	// Catch up and register under the channel lock, as for websocket subscriptions
+	st.mu.Lock()
+	var replay, snapshot []update
+	wantSnapshot := query.Get("snapshot") == "true"
+	if lastID != "" {
+		wantSnapshot = true
+		if epoch, seq, ok := parseEventID(lastID); ok && epoch == h.epoch {
+			if updates, ok := st.since(seq); ok {
+				replay = matching(updates, sub)
+				// Replaying more than half the queue would likely cut the client off
+				wantSnapshot = len(replay) > cap(c.send)/2
+			}
+		}
+	}
+	if wantSnapshot {
+		replay, snapshot = nil, matching(st.snapshot(), sub)
+	}
+	snapshotSeq := st.seq
+	h.add(c)
+	st.mu.Unlock()
+
+	slow := false
+	defer func() { h.remove(c, slow) }()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // keeps nginx from buffering the stream
+	if origin := r.Header.Get("Origin"); origin != "" {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	timeout := h.config.WriteTimeout.Duration
+	// write sends buffered events, bounding how long a stalled client can hold the handler
+	write := func(events func() error) bool {
+		if err := rc.SetWriteDeadline(time.Now().Add(timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+		if err := events(); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	ok = write(func() error {
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", retryMs); err != nil {
+			return err
+		}
+		if wantSnapshot {
+			data := []byte("[")
+			for i, u := range snapshot {
+				if i > 0 {
+					data = append(data, ',')
+				}
+				data = append(data, u.data...)
+			}
+			data = append(data, ']')
+			if err := writeEvent(w, h.eventID(snapshotSeq), TypeSnapshot, data); err != nil {
+				return err
+			}
+		}
+		for _, u := range replay {
+			if err := writeEvent(w, h.eventID(u.seq), TypeUpdate, u.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	interval := h.config.HeartbeatInterval.Duration
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case u := <-c.send:
+			ok = write(func() error {
+				return writeEvent(w, h.eventID(u.seq), TypeUpdate, u.data)
+			})
+			// Only idle streams need a heartbeat
+			heartbeat.Reset(interval)
+		case <-heartbeat.C:
+			ok = write(func() error {
+				_, err := io.WriteString(w, ": heartbeat\n\n")
+				return err
+			})
+		case <-c.done:
+			log.Printf("Event stream client %s fell %d messages behind, disconnecting", r.RemoteAddr, cap(c.send))
+			slow = true
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// writeEvent writes one event. Data is compact JSON, so it fits on one data line.
+func writeEvent(w io.Writer, id, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
+}
+
+// eventID identifies an update on a channel across reconnects.
+func (h *Hub) eventID(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func parseEventID(id string) (epoch string, seq uint64, ok bool) {
+	epoch, s, ok := strings.Cut(id, "-")
+	if !ok {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(s, 10, 64)
+	return epoch, seq, err == nil
+}
+
+// queryList returns a query parameter's values, split on commas.
+func queryList(query url.Values, name string) []string {
+	var values []string
+	for _, v := range query[name] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
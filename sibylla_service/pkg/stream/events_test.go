@@ -0,0 +1,194 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	exchangeconfig "sibylla_service/pkg/config"
+)
+
+// event is a server-sent event, or a comment when only that is set.
+type event struct {
+	id, name, data, comment string
+}
+
+type eventStream struct {
+	t      *testing.T
+	reader *bufio.Reader
+}
+
+// openEvents opens an event stream, which is torn down after a few seconds
+// so a missing event fails the test rather than hanging it.
+func openEvents(t *testing.T, srv *httptest.Server, path string, header http.Header) *eventStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	s := &eventStream{t: t, reader: bufio.NewReader(resp.Body)}
+	if e := s.next(); !strings.HasPrefix(e.comment, "retry") {
+		t.Fatalf("stream starts with %+v, want the retry delay", e)
+	}
+	return s
+}
+
+// next reads the next event or comment.
+func (s *eventStream) next() event {
+	s.t.Helper()
+	var e event
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return e
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.name = value
+		case "data":
+			e.data = value
+		case "", "retry":
+			e.comment = line
+		}
+	}
+}
+
+func TestEventStreamFilters(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{})
+	s := openEvents(t, srv, "/events/trades?exchange=binance&pair=BTCUSDT,ETHUSDT", nil)
+
+	publish(t, h, ChannelTrades, trade("kraken", "BTCUSD", 99))
+	publish(t, h, ChannelTrades, trade("binance", "SOLUSDT", 1))
+	publish(t, h, ChannelTrades, trade("binance", "ETHUSDT", 5))
+
+	e := s.next()
+	var tr Trade
+	if err := json.Unmarshal([]byte(e.data), &tr); err != nil {
+		t.Fatal(err)
+	}
+	if e.name != TypeUpdate || e.id != h.eventID(3) || tr.Pair != "ETHUSDT" {
+		t.Errorf("got %+v, want the ETHUSDT update", e)
+	}
+}
+
+func TestEventStreamResume(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{QueueSize: 4, ReplayBuffer: 3})
+	for i := 0; i < 5; i++ {
+		publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", float64(100+i)))
+	}
+
+	// 4 and 5 are buffered and few enough to replay
+	s := openEvents(t, srv, "/events/trades", http.Header{"Last-Event-ID": {h.eventID(3)}})
+	for _, seq := range []uint64{4, 5} {
+		if e := s.next(); e.name != TypeUpdate || e.id != h.eventID(seq) {
+			t.Errorf("got %+v, want replayed update %d", e, seq)
+		}
+	}
+
+	tests := []struct {
+		name, lastID string
+	}{
+		{"gap", h.eventID(1)},
+		{"other epoch", "restarted-4"},
+		{"large replay", h.eventID(2)},
+	}
+	for _, tt := range tests {
+		s := openEvents(t, srv, "/events/trades?last_event_id="+tt.lastID, nil)
+		e := s.next()
+		var trades []Trade
+		if err := json.Unmarshal([]byte(e.data), &trades); err != nil {
+			t.Fatal(err)
+		}
+		if e.name != TypeSnapshot || e.id != h.eventID(5) || len(trades) != 1 || trades[0].Price != 104 {
+			t.Errorf("%s: got %+v, want a snapshot at 5", tt.name, e)
+		}
+	}
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{HeartbeatInterval: exchangeconfig.Duration{Duration: 100 * time.Millisecond}})
+	s := openEvents(t, srv, "/events/index", nil)
+
+	// A busy stream gets no heartbeat
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 30; i++ {
+			publish(t, h, ChannelIndex, indexPrice("BTCUSD", float64(100+i)))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	for i := 0; i < 30; i++ {
+		if e := s.next(); e.name != TypeUpdate {
+			t.Fatalf("got %+v while updates flowed, want update %d", e, i+1)
+		}
+	}
+	<-done
+
+	if e := s.next(); e.comment != ": heartbeat" {
+		t.Errorf("idle stream got %+v, want a heartbeat", e)
+	}
+}
+
+func TestEventStreamErrors(t *testing.T) {
+	_, srv := testServer(t, exchangeconfig.StreamConfig{})
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/events/trades", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/events/orders", http.StatusNotFound},
+		{http.MethodGet, "/events/index?exchange=binance", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events/trades", nil)
+	req.Header.Set("Origin", "http://evil.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin got %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
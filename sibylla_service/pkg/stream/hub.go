@@ -0,0 +1,342 @@
+package stream
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sibylla_service/pkg/alert"
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/candles"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/index"
+	"sibylla_service/pkg/metrics"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/spread"
+)
+
+// busBuffer is the bus subscription buffer of each channel.
+const busBuffer = 4096
+
+// Channels clients can subscribe to
+const (
+	ChannelTrades  = "trades"
+	ChannelCandles = "candles"
+	ChannelSpreads = "spreads"
+	ChannelIndex   = "index"
+	ChannelAlerts  = "alerts"
+)
+
+// channelTopics maps each channel to the bus topic it streams.
+var channelTopics = map[string]string{
+	ChannelTrades:  bus.TopicTrades,
+	ChannelCandles: bus.TopicCandles,
+	ChannelSpreads: bus.TopicSpreads,
+	ChannelIndex:   bus.TopicIndex,
+	ChannelAlerts:  bus.TopicAlerts,
+}
+
+// Trade is a trade as streamed to clients.
+type Trade struct {
+	Exchange     string  `json:"exchange"`
+	Pair         string  `json:"pair"`
+	Price        float64 `json:"price"`
+	Quantity     float64 `json:"quantity"`
+	IsBuyerMaker bool    `json:"is_buyer_maker"`
+	Timestamp    int64   `json:"timestamp"`
+	ReceivedAt   int64   `json:"received_at,omitempty"`
+}
+
+// update is a bus message with what subscriptions filter on.
+type update struct {
+	channel   string
+	seq       uint64
+	key       string // what the update is the latest state of, for snapshots
+	pair      string
+	exchanges []string // venues the update belongs to, none for index
+	interval  string   // candles only
+	quantity  float64  // trades only
+	rule      string   // alerts only
+	data      json.RawMessage
+}
+
+// newUpdate describes a bus payload, false for payloads a channel doesn't stream.
+func newUpdate(channel string, payload interface{}) (update, bool) {
+	u := update{channel: channel}
+	var data interface{}
+	switch p := payload.(type) {
+	case models.Trade:
+		u.key, u.pair, u.exchanges = p.Exchange+":"+p.Pair, p.Pair, []string{p.Exchange}
+		u.quantity = p.Quantity
+		data = Trade{
+			Exchange:     p.Exchange,
+			Pair:         p.Pair,
+			Price:        p.Price,
+			Quantity:     p.Quantity,
+			IsBuyerMaker: p.IsBuyerMaker,
+			Timestamp:    p.Timestamp,
+			ReceivedAt:   p.ReceivedAt,
+		}
+	case candles.Candle:
+		u.key = p.Exchange + ":" + p.Pair + ":" + p.Interval
+		u.pair, u.exchanges, u.interval = p.Pair, []string{p.Exchange}, p.Interval
+		data = p
+	case spread.Spread:
+		u.key = p.Pair + ":" + p.ExchangeA + ":" + p.ExchangeB
+		u.pair, u.exchanges = p.Pair, []string{p.ExchangeA, p.ExchangeB}
+		data = p
+	case index.Price:
+		u.key, u.pair = p.Pair, p.Pair
+		data = p
+	case alert.Alert:
+		u.key, u.rule = p.RuleID, p.RuleID
+		data = p
+	default:
+		return u, false
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Could not encode %s update: %v", channel, err)
+		return u, false
+	}
+	u.data = raw
+	return u, true
+}
+
+// channelState numbers a channel's updates and keeps what resuming clients
+// need: the most recent updates, and the latest update per key as a snapshot.
+// Its lock is held while an update is delivered, so a subscription sees every
+// update after its replay or snapshot exactly once.
+type channelState struct {
+	mu     sync.Mutex
+	seq    uint64
+	recent []update // ring of the last updates, oldest at next once full
+	next   int
+	latest map[string]update
+}
+
+func (st *channelState) record(u update, size int) {
+	if len(st.recent) < size {
+		st.recent = append(st.recent, u)
+	} else {
+		st.recent[st.next] = u
+		st.next = (st.next + 1) % size
+	}
+	st.latest[u.key] = u
+}
+
+// since returns the buffered updates after seq in order, false when some of
+// them are no longer buffered.
+func (st *channelState) since(seq uint64) ([]update, bool) {
+	if seq >= st.seq {
+		return nil, true
+	}
+	if len(st.recent) == 0 || st.recent[st.next].seq > seq+1 {
+		return nil, false
+	}
+	ordered := append(append([]update(nil), st.recent[st.next:]...), st.recent[:st.next]...)
+	i := sort.Search(len(ordered), func(i int) bool { return ordered[i].seq > seq })
+	return ordered[i:], true
+}
+
+// snapshot returns the latest update of every key, ordered by sequence.
+func (st *channelState) snapshot() []update {
+	updates := make([]update, 0, len(st.latest))
+	for _, u := range st.latest {
+		updates = append(updates, u)
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].seq < updates[j].seq })
+	return updates
+}
+
+// receiver is a connected streaming client, over a websocket or server-sent events.
+type receiver interface {
+	// deliver queues an update for the client's matching subscriptions and
+	// returns how many it was queued for. It must not block.
+	deliver(u update) int
+}
+
+// Hub streams bus updates to websocket and server-sent event clients
+// according to their subscriptions.
+type Hub struct {
+	mu       sync.RWMutex
+	config   exchangeconfig.StreamConfig
+	bus      *bus.Bus
+	upgrader websocket.Upgrader
+	clients  map[receiver]struct{}
+	channels map[string]*channelState
+	// epoch identifies this run of the hub: sequence numbers restart with it
+	epoch string
+
+	// Counters for metrics, guarded by mu
+	connections   int64
+	slowConsumers int64
+	sent          map[string]int64 // by channel
+}
+
+func NewHub(b *bus.Bus, config exchangeconfig.StreamConfig) *Hub {
+	config = config.WithDefaults()
+	h := &Hub{
+		config:   config,
+		bus:      b,
+		clients:  make(map[receiver]struct{}),
+		channels: make(map[string]*channelState),
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		sent:     make(map[string]int64),
+	}
+	for channel := range channelTopics {
+		h.channels[channel] = &channelState{latest: make(map[string]update)}
+	}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	return h
+}
+
+// Run forwards every channel's bus topic to the clients.
+// It blocks, call it in a goroutine.
+func (h *Hub) Run() {
+	var wg sync.WaitGroup
+	for channel, topic := range channelTopics {
+		sub := h.bus.Subscribe(topic, busBuffer)
+		wg.Add(1)
+		go func(channel string, sub *bus.Subscription) {
+			defer wg.Done()
+			for msg := range sub.C {
+				if u, ok := newUpdate(channel, msg.Payload); ok {
+					h.broadcast(u)
+				}
+			}
+		}(channel, sub)
+	}
+	wg.Wait()
+}
+
+func (h *Hub) broadcast(u update) {
+	st := h.channels[u.channel]
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.seq++
+	u.seq = st.seq
+	st.record(u, h.config.ReplayBuffer)
+
+	h.mu.RLock()
+	clients := make([]receiver, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, c := range clients {
+		sent += c.deliver(u)
+	}
+	if sent > 0 {
+		h.countSent(u.channel, sent)
+	}
+}
+
+func (h *Hub) countSent(channel string, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sent[channel] += int64(n)
+}
+
+// ServeHTTP upgrades the request to a websocket and serves the client until
+// it disconnects.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered with an HTTP error
+		log.Printf("Websocket upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+
+	c := newClient(h, conn)
+	h.add(c)
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+func (h *Hub) add(r receiver) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[r] = struct{}{}
+	h.connections++
+}
+
+func (h *Hub) remove(r receiver, slow bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, r)
+	if slow {
+		h.slowConsumers++
+	}
+}
+
+// checkOrigin allows requests without an Origin header, and otherwise only
+// the configured origins or, with none configured, the service's own.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if len(h.config.AllowedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// https://*.example.com allows any subdomain over the same scheme
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.EqualFold(scheme, u.Scheme) && strings.HasSuffix(strings.ToLower(u.Host), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	log.Printf("Rejected streaming connection from origin %s", origin)
+	return false
+}
+
+// Metrics reports connected clients, messages sent and slow consumer disconnections.
+func (h *Hub) Metrics() []metrics.Family {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := metrics.Family{Name: "sibylla_stream_messages_sent_total", Help: "Updates sent to streaming clients.", Type: metrics.Counter}
+	for _, channel := range sortedKeys(h.sent) {
+		sent.Samples = append(sent.Samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "channel", Value: channel}},
+			Value:  float64(h.sent[channel]),
+		})
+	}
+	return []metrics.Family{
+		{
+			Name: "sibylla_stream_clients", Help: "Connected streaming clients.", Type: metrics.Gauge,
+			Samples: []metrics.Sample{{Value: float64(len(h.clients))}},
+		},
+		{
+			Name: "sibylla_stream_connections_total", Help: "Streaming connections accepted.", Type: metrics.Counter,
+			Samples: []metrics.Sample{{Value: float64(h.connections)}},
+		},
+		{
+			Name: "sibylla_stream_slow_consumers_total", Help: "Streaming clients disconnected for falling behind.", Type: metrics.Counter,
+			Samples: []metrics.Sample{{Value: float64(h.slowConsumers)}},
+		},
+		sent,
+	}
+}
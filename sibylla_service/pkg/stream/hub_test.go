@@ -0,0 +1,206 @@
+package stream
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/index"
+	"sibylla_service/pkg/models"
+)
+
+// testServer serves a hub's websocket and event stream endpoints.
+func testServer(t *testing.T, config exchangeconfig.StreamConfig) (*Hub, *httptest.Server) {
+	t.Helper()
+	h := NewHub(bus.New(), config)
+	mux := http.NewServeMux()
+	mux.Handle("/ws", h)
+	mux.HandleFunc("/events/", h.ServeEvents)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return h, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, req Request) {
+	t.Helper()
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m Message
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// publish broadcasts a payload as the hub's Run would.
+func publish(t *testing.T, h *Hub, channel string, payload interface{}) {
+	t.Helper()
+	u, ok := newUpdate(channel, payload)
+	if !ok {
+		t.Fatalf("%s doesn't stream %T", channel, payload)
+	}
+	h.broadcast(u)
+}
+
+func trade(exchange, pair string, price float64) models.Trade {
+	return models.Trade{Exchange: exchange, Pair: pair, Price: price, Quantity: 1}
+}
+
+func indexPrice(pair string, price float64) index.Price {
+	return index.Price{Pair: pair, Price: price, Valid: true}
+}
+
+func TestSubscriptionFilters(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{})
+	conn := dial(t, srv)
+
+	send(t, conn, Request{Op: OpSubscribe, Channel: ChannelTrades, Exchanges: []string{"binance"}, Pairs: []string{"BTCUSDT"}})
+	ack := receive(t, conn)
+	if ack.Type != TypeSubscribed || ack.ID != "sub-1" || ack.Epoch != h.epoch {
+		t.Fatalf("unexpected acknowledgement %+v", ack)
+	}
+
+	publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", 100))
+	publish(t, h, ChannelTrades, trade("kraken", "BTCUSDT", 101))
+	publish(t, h, ChannelTrades, trade("binance", "ETHUSDT", 5))
+	publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", 102))
+
+	for _, want := range []struct {
+		seq   uint64
+		price float64
+	}{{1, 100}, {4, 102}} {
+		m := receive(t, conn)
+		var tr Trade
+		if err := json.Unmarshal(m.Data, &tr); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != TypeUpdate || m.ID != "sub-1" || m.Seq != want.seq || tr.Price != want.price {
+			t.Errorf("got %s %d at %v, want update %d at %v", m.Type, m.Seq, tr.Price, want.seq, want.price)
+		}
+	}
+}
+
+func TestSubscribeListUnsubscribe(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{MaxSubscriptions: 2})
+	conn := dial(t, srv)
+
+	send(t, conn, Request{Op: OpSubscribe, ID: "btc", Channel: ChannelTrades, Pairs: []string{"BTCUSDT"}})
+	send(t, conn, Request{Op: OpSubscribe, Channel: ChannelIndex, Pairs: []string{"BTCUSDT"}})
+	send(t, conn, Request{Op: OpSubscribe, Channel: ChannelSpreads})
+	for _, want := range []string{TypeSubscribed, TypeSubscribed, TypeError} {
+		if m := receive(t, conn); m.Type != want {
+			t.Errorf("got %+v, want %s", m, want)
+		}
+	}
+
+	// Resubscribing with an ID replaces the subscription, even at the limit
+	send(t, conn, Request{Op: OpSubscribe, ID: "btc", Channel: ChannelTrades, Pairs: []string{"BTCUSD"}})
+	if m := receive(t, conn); m.Type != TypeSubscribed || m.ID != "btc" {
+		t.Fatalf("replacing got %+v", m)
+	}
+
+	send(t, conn, Request{Op: OpList})
+	m := receive(t, conn)
+	if m.Type != TypeSubscriptions || len(m.Subscriptions) != 2 {
+		t.Fatalf("list got %+v", m)
+	}
+	if s := m.Subscriptions[0]; s.ID != "btc" || len(s.Pairs) != 1 || s.Pairs[0] != "BTCUSD" || s.Mode != ModeTick {
+		t.Errorf("unexpected subscription %+v", s)
+	}
+
+	send(t, conn, Request{Op: OpUnsubscribe, ID: "btc"})
+	if m := receive(t, conn); m.Type != TypeUnsubscribed || m.ID != "btc" {
+		t.Errorf("unsubscribe got %+v", m)
+	}
+	send(t, conn, Request{Op: OpUnsubscribe, ID: "btc"})
+	if m := receive(t, conn); m.Type != TypeError {
+		t.Errorf("unsubscribing twice got %+v", m)
+	}
+
+	// The unsubscribed trades no longer arrive, the index still does
+	publish(t, h, ChannelTrades, trade("kraken", "BTCUSD", 100))
+	publish(t, h, ChannelIndex, indexPrice("BTCUSD", 100))
+	if m := receive(t, conn); m.Channel != ChannelIndex {
+		t.Errorf("got %+v after unsubscribing, want the index update", m)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	_, srv := testServer(t, exchangeconfig.StreamConfig{})
+	conn := dial(t, srv)
+
+	for _, req := range []Request{
+		{Op: "watch"},
+		{Op: OpSubscribe, Channel: "orders"},
+		{Op: OpSubscribe, Channel: ChannelIndex, Exchanges: []string{"binance"}},
+		{Op: OpSubscribe, Channel: ChannelTrades, Intervals: []string{"1m"}},
+		{Op: OpSubscribe, Channel: ChannelAlerts, Pairs: []string{"BTCUSDT"}},
+		{Op: OpSubscribe, Channel: ChannelTrades, Rules: []string{"btc-100k"}},
+		{Op: OpSubscribe, Channel: ChannelTrades, Mode: "sometimes"},
+		{Op: OpSubscribe, Channel: ChannelTrades, Mode: ModeLatest, IntervalMs: 10},
+	} {
+		send(t, conn, req)
+		if m := receive(t, conn); m.Type != TypeError || m.Error == "" {
+			t.Errorf("%+v got %+v, want an error", req, m)
+		}
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, conn); m.Type != TypeError {
+		t.Errorf("malformed request got %+v, want an error", m)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{nil, "", true},
+		{nil, "http://example.com", true}, // the request's own host
+		{nil, "http://evil.com", false},
+		{[]string{"https://dash.example.com"}, "https://dash.example.com", true},
+		{[]string{"https://dash.example.com"}, "http://dash.example.com", false},
+		{[]string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{[]string{"https://*.example.com"}, "https://example.com", false},
+		{[]string{"https://*.example.com"}, "http://a.example.com", false},
+		{[]string{"*"}, "http://evil.com", true},
+		{[]string{"*"}, "not a url", false},
+	}
+	for _, tt := range tests {
+		h := NewHub(bus.New(), exchangeconfig.StreamConfig{AllowedOrigins: tt.allowed})
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := h.checkOrigin(r); got != tt.want {
+			t.Errorf("origin %q with %v allowed: got %v, want %v", tt.origin, tt.allowed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+
+	exchangeconfig "sibylla_service/pkg/config"
+)
+
+func TestChannelStateSince(t *testing.T) {
+	st := &channelState{latest: make(map[string]update)}
+	for i := 0; i < 7; i++ {
+		st.seq++
+		st.record(update{seq: st.seq, key: []string{"a", "b"}[i%2]}, 4)
+	}
+
+	// The ring holds 4 to 7
+	tests := []struct {
+		since uint64
+		want  []uint64
+		ok    bool
+	}{
+		{7, nil, true},
+		{9, nil, true},
+		{5, []uint64{6, 7}, true},
+		{3, []uint64{4, 5, 6, 7}, true},
+		{2, nil, false},
+	}
+	for _, tt := range tests {
+		updates, ok := st.since(tt.since)
+		var got []uint64
+		for _, u := range updates {
+			got = append(got, u.seq)
+		}
+		if ok != tt.ok || len(got) != len(tt.want) {
+			t.Errorf("since(%d) = %v, %v, want %v, %v", tt.since, got, ok, tt.want, tt.ok)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("since(%d) = %v, want %v", tt.since, got, tt.want)
+				break
+			}
+		}
+	}
+
+	snapshot := st.snapshot()
+	if len(snapshot) != 2 || snapshot[0].seq != 6 || snapshot[1].seq != 7 {
+		t.Errorf("snapshot = %+v, want the latest of a and b", snapshot)
+	}
+}
+
+func TestResumeReplaysMissedUpdates(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{})
+	for _, price := range []float64{100, 101, 102} {
+		publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", price))
+	}
+	publish(t, h, ChannelTrades, trade("kraken", "BTCUSD", 99))
+
+	conn := dial(t, srv)
+	since := uint64(1)
+	send(t, conn, Request{Op: OpSubscribe, Channel: ChannelTrades, Exchanges: []string{"binance"}, Since: &since, Epoch: h.epoch})
+	ack := receive(t, conn)
+	if ack.Type != TypeSubscribed || ack.Resume != ResumeReplay || ack.Seq != 4 {
+		t.Fatalf("unexpected acknowledgement %+v", ack)
+	}
+	for _, seq := range []uint64{2, 3} {
+		if m := receive(t, conn); m.Type != TypeUpdate || m.Seq != seq {
+			t.Errorf("got %+v, want replayed update %d", m, seq)
+		}
+	}
+
+	// Live updates follow the replay without a gap
+	publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", 103))
+	if m := receive(t, conn); m.Type != TypeUpdate || m.Seq != 5 {
+		t.Errorf("got %+v, want live update 5", m)
+	}
+}
+
+func TestResumeFallsBackToSnapshot(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{QueueSize: 4, ReplayBuffer: 3})
+	for i := 0; i < 5; i++ {
+		publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", float64(100+i)))
+	}
+	publish(t, h, ChannelTrades, trade("kraken", "BTCUSD", 99))
+	publish(t, h, ChannelTrades, trade("binance", "ETHUSDT", 5))
+
+	latest := func(m Message) map[string]float64 {
+		var trades []Trade
+		if err := json.Unmarshal(m.Data, &trades); err != nil {
+			t.Fatal(err)
+		}
+		prices := make(map[string]float64)
+		for _, tr := range trades {
+			prices[tr.Exchange+":"+tr.Pair] = tr.Price
+		}
+		return prices
+	}
+
+	since := func(seq uint64) *uint64 { return &seq }
+	tests := []struct {
+		name string
+		req  Request
+	}{
+		// Update 2 is no longer buffered
+		{"gap", Request{Since: since(1), Epoch: h.epoch}},
+		{"other epoch", Request{Since: since(6), Epoch: "restarted"}},
+		// 5 to 7 are buffered, but more than half the queue
+		{"large replay", Request{Since: since(4), Epoch: h.epoch}},
+		{"fresh", Request{Snapshot: true}},
+	}
+	for _, tt := range tests {
+		conn := dial(t, srv)
+		tt.req.Op, tt.req.Channel = OpSubscribe, ChannelTrades
+		send(t, conn, tt.req)
+
+		if ack := receive(t, conn); ack.Type != TypeSubscribed || ack.Resume != ResumeSnapshot {
+			t.Errorf("%s: unexpected acknowledgement %+v", tt.name, ack)
+			continue
+		}
+		m := receive(t, conn)
+		if m.Type != TypeSnapshot || m.Seq != 7 {
+			t.Errorf("%s: got %+v, want a snapshot at 7", tt.name, m)
+			continue
+		}
+		prices := latest(m)
+		if len(prices) != 3 || prices["binance:BTCUSDT"] != 104 || prices["kraken:BTCUSD"] != 99 {
+			t.Errorf("%s: snapshot has %v", tt.name, prices)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Subscription modes
+const (
+	ModeTick      = "tick"      // every update as it happens, the default
+	ModeLatest    = "latest"    // the latest update of each key once per interval
+	ModeAggregate = "aggregate" // an Aggregate of each key's updates once per interval
+)
+
+// Bounds of a throttled subscription's interval
+const (
+	MinThrottleInterval = 50 * time.Millisecond
+	MaxThrottleInterval = time.Minute
+)
+
+// Aggregate coalesces the updates of one key over an interval. Volume sums
+// trade quantities and is only set on the trades channel.
+type Aggregate struct {
+	Key      string          `json:"key"`
+	Count    int             `json:"count"`
+	Volume   float64         `json:"volume,omitempty"`
+	FirstSeq uint64          `json:"first_seq"`
+	Last     json.RawMessage `json:"last"`
+}
+
+// pending is what a throttled subscription has collected for a key since its
+// last flush.
+type pending struct {
+	last     update
+	count    int
+	volume   float64
+	firstSeq uint64
+}
+
+// collect adds an update to a throttled subscription. The client's lock must be held.
+func (s *Subscription) collect(u update) {
+	p := s.pending[u.key]
+	if p == nil {
+		p = &pending{firstSeq: u.seq}
+		s.pending[u.key] = p
+	}
+	p.last = u
+	p.count++
+	p.volume += u.quantity
+}
+
+// flushLoop sends a throttled subscription's collected updates every interval
+// until it is unsubscribed or the client goes.
+func (c *client) flushLoop(s *Subscription) {
+	ticker := time.NewTicker(time.Duration(s.IntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.flush(s) {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// flush sends one message per key collected since the last flush, in the
+// order their latest updates were published. It returns false once the
+// client is gone.
+func (c *client) flush(s *Subscription) bool {
+	c.mu.Lock()
+	collected := make([]*pending, 0, len(s.pending))
+	for _, p := range s.pending {
+		collected = append(collected, p)
+	}
+	s.pending = make(map[string]*pending)
+	c.mu.Unlock()
+
+	sort.Slice(collected, func(i, j int) bool { return collected[i].last.seq < collected[j].last.seq })
+	for _, p := range collected {
+		m := Message{Type: TypeUpdate, ID: s.ID, Channel: s.Channel, Seq: p.last.seq, Data: p.last.data}
+		if s.Mode == ModeAggregate {
+			data, _ := json.Marshal(Aggregate{
+				Key:      p.last.key,
+				Count:    p.count,
+				Volume:   p.volume,
+				FirstSeq: p.firstSeq,
+				Last:     p.last.data,
+			})
+			m.Type, m.Data = TypeAggregate, data
+		}
+		if !c.reply(m) {
+			return false
+		}
+	}
+	if len(collected) > 0 {
+		c.hub.countSent(s.Channel, len(collected))
+	}
+	return true
+}
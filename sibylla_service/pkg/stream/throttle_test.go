@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/models"
+)
+
+// testClient is a client without a connection, whose queued messages the test reads.
+func testClient(t *testing.T) *client {
+	t.Helper()
+	h := NewHub(bus.New(), exchangeconfig.StreamConfig{})
+	return &client{
+		hub:  h,
+		send: make(chan []byte, 16),
+		done: make(chan struct{}),
+		subs: make(map[string]*Subscription),
+	}
+}
+
+func queued(t *testing.T, c *client) []Message {
+	t.Helper()
+	var messages []Message
+	for {
+		select {
+		case data := <-c.send:
+			var m Message
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, m)
+		default:
+			return messages
+		}
+	}
+}
+
+// collectTrades numbers trades as a channel would and collects them.
+func collectTrades(t *testing.T, sub *Subscription, trades ...models.Trade) {
+	t.Helper()
+	for i, tr := range trades {
+		u, ok := newUpdate(ChannelTrades, tr)
+		if !ok {
+			t.Fatal("trade not streamed")
+		}
+		u.seq = uint64(i + 1)
+		sub.collect(u)
+	}
+}
+
+func TestFlushLatest(t *testing.T) {
+	c := testClient(t)
+	sub, err := newSubscription(Request{ID: "s", Channel: ChannelTrades, Mode: ModeLatest, IntervalMs: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectTrades(t, sub,
+		trade("binance", "BTCUSDT", 100),
+		trade("kraken", "BTCUSD", 99),
+		trade("binance", "BTCUSDT", 101),
+	)
+
+	if !c.flush(sub) {
+		t.Fatal("flush reported the client gone")
+	}
+	messages := queued(t, c)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want one per key", len(messages))
+	}
+	// Ordered by each key's latest update
+	want := []struct {
+		seq   uint64
+		price float64
+	}{{2, 99}, {3, 101}}
+	for i, m := range messages {
+		var tr Trade
+		if err := json.Unmarshal(m.Data, &tr); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != TypeUpdate || m.ID != "s" || m.Seq != want[i].seq || tr.Price != want[i].price {
+			t.Errorf("message %d is %s %d at %v, want update %d at %v", i, m.Type, m.Seq, tr.Price, want[i].seq, want[i].price)
+		}
+	}
+
+	// Nothing collected since, nothing sent
+	c.flush(sub)
+	if messages := queued(t, c); len(messages) != 0 {
+		t.Errorf("empty flush sent %+v", messages)
+	}
+}
+
+func TestFlushAggregate(t *testing.T) {
+	c := testClient(t)
+	sub, err := newSubscription(Request{ID: "s", Channel: ChannelTrades, Mode: ModeAggregate, IntervalMs: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectTrades(t, sub,
+		trade("binance", "BTCUSDT", 100),
+		trade("binance", "BTCUSDT", 102),
+		trade("binance", "BTCUSDT", 101),
+	)
+
+	c.flush(sub)
+	messages := queued(t, c)
+	if len(messages) != 1 || messages[0].Type != TypeAggregate || messages[0].Seq != 3 {
+		t.Fatalf("got %+v, want one aggregate", messages)
+	}
+	var agg Aggregate
+	if err := json.Unmarshal(messages[0].Data, &agg); err != nil {
+		t.Fatal(err)
+	}
+	var last Trade
+	if err := json.Unmarshal(agg.Last, &last); err != nil {
+		t.Fatal(err)
+	}
+	if agg.Key != "binance:BTCUSDT" || agg.Count != 3 || agg.Volume != 3 || agg.FirstSeq != 1 || last.Price != 101 {
+		t.Errorf("unexpected aggregate %+v of %+v", agg, last)
+	}
+}
+
+func TestThrottledSubscription(t *testing.T) {
+	h, srv := testServer(t, exchangeconfig.StreamConfig{})
+	conn := dial(t, srv)
+
+	send(t, conn, Request{Op: OpSubscribe, Channel: ChannelTrades, Mode: ModeAggregate, IntervalMs: 50})
+	if m := receive(t, conn); m.Type != TypeSubscribed || m.Subscriptions[0].Mode != ModeAggregate {
+		t.Fatalf("unexpected acknowledgement %+v", m)
+	}
+	for i := 0; i < 20; i++ {
+		publish(t, h, ChannelTrades, trade("binance", "BTCUSDT", float64(100+i)))
+	}
+
+	// However the trades fell across flushes, every one is counted once
+	count := 0
+	for count < 20 {
+		m := receive(t, conn)
+		var agg Aggregate
+		if err := json.Unmarshal(m.Data, &agg); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != TypeAggregate || agg.FirstSeq != uint64(count+1) {
+			t.Fatalf("got %s from %d after %d trades", m.Type, agg.FirstSeq, count)
+		}
+		count += agg.Count
+	}
+	if count != 20 {
+		t.Errorf("aggregated %d trades, want 20", count)
+	}
+}
@@ -0,0 +1,152 @@
+package volatility
+
+import (
+	"math"
+	"sort"
+
+	"sibylla_service/pkg/candles"
+)
+
+// ReturnStats describe the distribution of log returns between consecutive
+// candles. Kurtosis is excess kurtosis, zero for a normal distribution.
+type ReturnStats struct {
+	Count    int     `json:"count"`
+	Mean     float64 `json:"mean"`
+	StdDev   float64 `json:"stddev"`
+	Skewness float64 `json:"skewness"`
+	Kurtosis float64 `json:"kurtosis"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	P1       float64 `json:"p1"`
+	P5       float64 `json:"p5"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+}
+
+// logReturns returns ln(close / previous close) for every pair of candles that
+// are exactly one interval apart. Candles either side of a gap with no trades
+// are not compared, since the return would span several periods.
+func logReturns(cs []candles.Candle, intervalMs int64) []float64 {
+	var returns []float64
+	for i := 1; i < len(cs); i++ {
+		prev, c := cs[i-1], cs[i]
+		if c.OpenTime-prev.OpenTime != intervalMs || prev.Close <= 0 || c.Close <= 0 {
+			continue
+		}
+		returns = append(returns, math.Log(c.Close/prev.Close))
+	}
+	return returns
+}
+
+// closeToClose is the sample variance of the returns.
+func closeToClose(returns []float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	mean := mean(returns)
+	sum := 0.0
+	for _, r := range returns {
+		sum += (r - mean) * (r - mean)
+	}
+	return sum / float64(len(returns)-1)
+}
+
+// parkinson is the per-period variance from each candle's high-low range.
+func parkinson(cs []candles.Candle) float64 {
+	sum, n := 0.0, 0
+	for _, c := range cs {
+		if c.Low <= 0 || c.High <= 0 {
+			continue
+		}
+		hl := math.Log(c.High / c.Low)
+		sum += hl * hl
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / (4 * math.Ln2 * float64(n))
+}
+
+// garmanKlass is the per-period variance from each candle's open, high, low and close.
+func garmanKlass(cs []candles.Candle) float64 {
+	sum, n := 0.0, 0
+	for _, c := range cs {
+		if c.Low <= 0 || c.High <= 0 || c.Open <= 0 || c.Close <= 0 {
+			continue
+		}
+		hl := math.Log(c.High / c.Low)
+		co := math.Log(c.Close / c.Open)
+		sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Max(sum/float64(n), 0)
+}
+
+// ewma is the exponentially weighted variance of the returns, oldest first,
+// seeded with the first squared return.
+func ewma(returns []float64, lambda float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	variance := returns[0] * returns[0]
+	for _, r := range returns[1:] {
+		variance = lambda*variance + (1-lambda)*r*r
+	}
+	return variance
+}
+
+func returnStats(returns []float64) ReturnStats {
+	stats := ReturnStats{Count: len(returns)}
+	if len(returns) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[len(sorted)-1]
+	stats.P1 = percentile(sorted, 0.01)
+	stats.P5 = percentile(sorted, 0.05)
+	stats.P50 = percentile(sorted, 0.50)
+	stats.P95 = percentile(sorted, 0.95)
+	stats.P99 = percentile(sorted, 0.99)
+
+	stats.Mean = mean(returns)
+	var m2, m3, m4 float64
+	for _, r := range returns {
+		d := r - stats.Mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	n := float64(len(returns))
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if len(returns) > 1 {
+		stats.StdDev = math.Sqrt(m2 * n / (n - 1))
+	}
+	if m2 > 0 {
+		stats.Skewness = m3 / math.Pow(m2, 1.5)
+		stats.Kurtosis = m4/(m2*m2) - 3
+	}
+	return stats
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,73 @@
+package volatility
+
+import (
+	"math"
+	"testing"
+
+	"sibylla_service/pkg/candles"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestLogReturnsSkipGaps(t *testing.T) {
+	cs := []candles.Candle{
+		{OpenTime: 0, Close: 100},
+		{OpenTime: 60000, Close: 110},
+		{OpenTime: 180000, Close: 121}, // a minute without trades before it
+		{OpenTime: 240000, Close: 121},
+	}
+	returns := logReturns(cs, 60000)
+	if len(returns) != 2 || !near(returns[0], math.Log(1.1)) || returns[1] != 0 {
+		t.Errorf("logReturns = %v, want [ln 1.1, 0]", returns)
+	}
+}
+
+func TestEstimators(t *testing.T) {
+	returns := []float64{0.01, -0.01, 0.02, -0.02}
+	// mean 0, squares sum to 0.001 over n-1 = 3
+	if v := closeToClose(returns); !near(v, 0.001/3) {
+		t.Errorf("closeToClose = %v", v)
+	}
+	if v := closeToClose(returns[:1]); v != 0 {
+		t.Errorf("closeToClose of one return = %v, want 0", v)
+	}
+
+	cs := []candles.Candle{{Open: 100, High: 110, Low: 100, Close: 110}, {Open: 100, High: 100, Low: 100, Close: 100}}
+	hl := math.Log(1.1)
+	if v := parkinson(cs); !near(v, hl*hl/(4*math.Ln2*2)) {
+		t.Errorf("parkinson = %v", v)
+	}
+	if v := garmanKlass(cs); !near(v, (0.5*hl*hl-(2*math.Ln2-1)*hl*hl)/2) {
+		t.Errorf("garmanKlass = %v", v)
+	}
+	if v := parkinson([]candles.Candle{{}}); v != 0 {
+		t.Errorf("parkinson of an empty candle = %v, want 0", v)
+	}
+
+	// seeded with 0.01², then 0.9*0.0001 + 0.1*0.0004
+	if v := ewma([]float64{0.01, 0.02}, 0.9); !near(v, 0.00013) {
+		t.Errorf("ewma = %v, want 0.00013", v)
+	}
+}
+
+func TestReturnStats(t *testing.T) {
+	stats := returnStats([]float64{1, 2, 3, 4, 5})
+	if stats.Count != 5 || stats.Mean != 3 || stats.Min != 1 || stats.Max != 5 || stats.P50 != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if !near(stats.StdDev, math.Sqrt(2.5)) || stats.Skewness != 0 {
+		t.Errorf("stats = %+v, want stddev √2.5 and no skew", stats)
+	}
+	// population kurtosis of a uniform 1..5 is 1.7
+	if !near(stats.Kurtosis, 1.7-3) {
+		t.Errorf("excess kurtosis = %v, want -1.3", stats.Kurtosis)
+	}
+	if !near(stats.P95, 4.8) {
+		t.Errorf("p95 = %v, want 4.8", stats.P95)
+	}
+	if stats := returnStats(nil); stats.Count != 0 || stats.StdDev != 0 {
+		t.Errorf("empty stats = %+v", stats)
+	}
+}
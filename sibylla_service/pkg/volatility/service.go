@@ -0,0 +1,266 @@
+package volatility
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/average"
+	"sibylla_service/pkg/bus"
+	"sibylla_service/pkg/candles"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/redisclient"
+)
+
+// Estimates are the realized volatility of an instrument over one window.
+// Volatilities are annualized standard deviations of log returns, so 0.6 is
+// 60% a year.
+type Estimates struct {
+	Exchange     string      `json:"exchange"`
+	Pair         string      `json:"pair"`
+	Interval     string      `json:"interval"`
+	Window       string      `json:"window"`
+	Candles      int         `json:"candles"`
+	Coverage     float64     `json:"coverage"` // share of the window the candles span, below 1 until all of it is held
+	CloseToClose float64     `json:"close_to_close"`
+	Parkinson    float64     `json:"parkinson"`
+	GarmanKlass  float64     `json:"garman_klass"`
+	EWMA         float64     `json:"ewma"`
+	Returns      ReturnStats `json:"returns"`
+	Timestamp    int64       `json:"timestamp"`
+}
+
+type series struct {
+	candles []candles.Candle // closed, by open time
+	since   int64            // open time of the oldest candle seen or loaded
+}
+
+// upsert adds a closed candle, replacing an earlier version when a late trade amended it.
+func (s *series) upsert(c candles.Candle) {
+	i := sort.Search(len(s.candles), func(i int) bool { return s.candles[i].OpenTime >= c.OpenTime })
+	if i < len(s.candles) && s.candles[i].OpenTime == c.OpenTime {
+		s.candles[i] = c
+		return
+	}
+	s.candles = append(s.candles, candles.Candle{})
+	copy(s.candles[i+1:], s.candles[i:])
+	s.candles[i] = c
+	if s.since == 0 || c.OpenTime < s.since {
+		s.since = c.OpenTime
+	}
+}
+
+// trim drops candles that closed before the cutoff.
+func (s *series) trim(cutoffMs int64) {
+	n := 0
+	for n < len(s.candles) && s.candles[n].CloseTime <= cutoffMs {
+		n++
+	}
+	if n > 0 {
+		s.candles = append(s.candles[:0], s.candles[n:]...)
+	}
+}
+
+// Service estimates realized volatility and return statistics per exchange and
+// pair from the closed candles the candle builder publishes.
+type Service struct {
+	mu          sync.Mutex
+	config      exchangeconfig.VolatilityConfig
+	interval    candles.Interval
+	windows     []time.Duration // ascending
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	series      map[string]*series // by exchange:pair
+}
+
+func NewService(redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.VolatilityConfig) (*Service, error) {
+	config = config.WithDefaults()
+	interval, err := candles.ParseInterval(config.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []time.Duration
+	for _, w := range config.Windows {
+		if w.Duration >= 2*interval.Duration {
+			windows = append(windows, w.Duration)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+
+	return &Service{
+		config:      config,
+		interval:    interval,
+		windows:     windows,
+		redisClient: redisClient,
+		bus:         b,
+		series:      make(map[string]*series),
+	}, nil
+}
+
+// Run feeds closed candles from the bus into the estimators. It blocks, call it in a goroutine.
+func (s *Service) Run() {
+	if s.bus == nil {
+		return
+	}
+	sub := s.bus.Subscribe(bus.TopicCandles, 4096)
+	for msg := range sub.C {
+		if c, ok := msg.Payload.(candles.Candle); ok {
+			s.OnCandle(c)
+		}
+	}
+}
+
+// OnCandle adds a closed candle of the configured interval. The first candle of
+// an exchange and pair backfills the longest window from the store.
+func (s *Service) OnCandle(c candles.Candle) {
+	if !c.Closed || c.Interval != s.interval.Name || len(s.windows) == 0 {
+		return
+	}
+	key := c.Exchange + ":" + c.Pair
+
+	s.mu.Lock()
+	_, known := s.series[key]
+	s.mu.Unlock()
+
+	var history []candles.Candle
+	if !known {
+		history = s.load(c.Exchange, c.Pair)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[key]
+	if !ok {
+		sr = &series{}
+		for _, h := range history {
+			sr.upsert(h)
+		}
+		s.series[key] = sr
+	}
+	sr.upsert(c)
+	sr.trim(c.CloseTime - s.longest().Milliseconds())
+}
+
+func (s *Service) longest() time.Duration {
+	return s.windows[len(s.windows)-1]
+}
+
+// load reads the stored candles covering the longest window. The store keeps
+// only as many candles as the candle builder's MaxStored, so a longer window
+// fills up as candles arrive, as its estimates' Coverage shows.
+func (s *Service) load(exchange, pair string) []candles.Candle {
+	if s.redisClient == nil {
+		return nil
+	}
+	count := int64(s.longest() / s.interval.Duration)
+	stored, err := s.redisClient.GetSortedSet(candles.Key(exchange, pair, s.interval.Name), count)
+	if err != nil {
+		return nil
+	}
+
+	history := make([]candles.Candle, 0, len(stored))
+	for _, raw := range stored {
+		var c candles.Candle
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			log.Printf("Failed to unmarshal candle for %s %s: %v", exchange, pair, err)
+			continue
+		}
+		history = append(history, c)
+	}
+	return history
+}
+
+// Estimate returns the estimates for an exchange and pair over one of the
+// configured windows, ending now.
+func (s *Service) Estimate(exchange, pair string, window time.Duration) (Estimates, bool) {
+	tracked := false
+	for _, w := range s.windows {
+		tracked = tracked || w == window
+	}
+	if !tracked {
+		return Estimates{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[exchange+":"+pair]
+	if !ok {
+		return Estimates{}, false
+	}
+	return s.estimate(exchange, pair, sr, window, time.Now()), true
+}
+
+// All returns the estimates for an exchange and pair over every configured window.
+func (s *Service) All(exchange, pair string) []Estimates {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[exchange+":"+pair]
+	if !ok {
+		return nil
+	}
+	estimates := make([]Estimates, 0, len(s.windows))
+	for _, w := range s.windows {
+		estimates = append(estimates, s.estimate(exchange, pair, sr, w, now))
+	}
+	return estimates
+}
+
+// Exchanges returns the exchanges with candles for a pair, sorted.
+func (s *Service) Exchanges(pair string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exchanges []string
+	for _, sr := range s.series {
+		if len(sr.candles) > 0 && sr.candles[0].Pair == pair {
+			exchanges = append(exchanges, sr.candles[0].Exchange)
+		}
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
+
+func (s *Service) estimate(exchange, pair string, sr *series, window time.Duration, now time.Time) Estimates {
+	cutoff := now.Add(-window).UnixMilli()
+	start := sort.Search(len(sr.candles), func(i int) bool { return sr.candles[i].OpenTime >= cutoff })
+	cs := sr.candles[start:]
+
+	var coverage float64
+	if len(cs) > 0 {
+		from := sr.since
+		if from < cutoff {
+			from = cutoff
+		}
+		coverage = math.Min(1, float64(now.UnixMilli()-from)/float64(window.Milliseconds()))
+	}
+
+	returns := logReturns(cs, s.interval.Duration.Milliseconds())
+	periodsPerYear := s.config.TradingDays * float64(24*time.Hour) / float64(s.interval.Duration)
+	annualize := func(variance float64) float64 {
+		return math.Sqrt(variance * periodsPerYear)
+	}
+
+	return Estimates{
+		Exchange:     exchange,
+		Pair:         pair,
+		Interval:     s.interval.Name,
+		Window:       average.WindowName(window),
+		Candles:      len(cs),
+		Coverage:     coverage,
+		CloseToClose: annualize(closeToClose(returns)),
+		Parkinson:    annualize(parkinson(cs)),
+		GarmanKlass:  annualize(garmanKlass(cs)),
+		EWMA:         annualize(ewma(returns, s.config.EWMALambda)),
+		Returns:      returnStats(returns),
+		Timestamp:    now.UnixMilli(),
+	}
+}
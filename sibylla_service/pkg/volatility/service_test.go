@@ -0,0 +1,114 @@
+package volatility
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"sibylla_service/pkg/candles"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/redisclient"
+)
+
+func TestServiceWindows(t *testing.T) {
+	s, err := NewService(nil, nil, exchangeconfig.VolatilityConfig{
+		Interval: "1h",
+		Windows:  []exchangeconfig.Duration{{Duration: 24 * time.Hour}, {Duration: time.Hour}, {Duration: 4 * time.Hour}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1h can't hold a return of 1h candles
+	if len(s.windows) != 2 || s.windows[0] != 4*time.Hour || s.windows[1] != 24*time.Hour {
+		t.Errorf("windows = %v, want [4h 24h]", s.windows)
+	}
+
+	if _, err := NewService(nil, nil, exchangeconfig.VolatilityConfig{Interval: "7x"}); err == nil {
+		t.Error("accepted an invalid interval")
+	}
+}
+
+func TestServiceEstimate(t *testing.T) {
+	s, err := NewService(nil, nil, exchangeconfig.VolatilityConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Truncate(time.Minute).Add(-10 * time.Minute).UnixMilli()
+	closes := []float64{100, 101, 100, 101, 100}
+	for i, price := range closes {
+		open := start + int64(i)*60000
+		s.OnCandle(candles.Candle{
+			Exchange: "binance", Pair: "BTCUSDT", Interval: "1m",
+			OpenTime: open, CloseTime: open + 59999,
+			Open: price, High: price, Low: price, Close: price, Closed: true,
+		})
+	}
+	// Open candles and other intervals are ignored
+	s.OnCandle(candles.Candle{Exchange: "binance", Pair: "BTCUSDT", Interval: "1m", OpenTime: start + 5*60000, Close: 200})
+	s.OnCandle(candles.Candle{Exchange: "binance", Pair: "BTCUSDT", Interval: "5m", OpenTime: start, Close: 200, Closed: true})
+
+	e, ok := s.Estimate("binance", "BTCUSDT", time.Hour)
+	if !ok {
+		t.Fatal("no estimate over 1h")
+	}
+	if e.Candles != len(closes) || e.Returns.Count != len(closes)-1 || e.Window != "1h" {
+		t.Errorf("estimate = %+v, want 5 candles and 4 returns", e)
+	}
+	up, down := math.Log(1.01), math.Log(1/1.01)
+	mean := (up + down) / 2
+	variance := 2 * ((up-mean)*(up-mean) + (down-mean)*(down-mean)) / 3
+	want := math.Sqrt(variance * 365 * 24 * 60)
+	if math.Abs(e.CloseToClose-want) > 1e-9 {
+		t.Errorf("close to close = %v, want %v", e.CloseToClose, want)
+	}
+	if e.Parkinson != 0 || e.GarmanKlass != 0 {
+		t.Errorf("range estimators = %v, %v, want 0 for flat candles", e.Parkinson, e.GarmanKlass)
+	}
+
+	if _, ok := s.Estimate("binance", "BTCUSDT", 2*time.Hour); ok {
+		t.Error("got an estimate over a window that isn't tracked")
+	}
+	if all := s.All("binance", "BTCUSDT"); len(all) != len(exchangeconfig.DefaultVolatilityWindows) {
+		t.Errorf("got %d estimates from All", len(all))
+	}
+	if exchanges := s.Exchanges("BTCUSDT"); len(exchanges) != 1 || exchanges[0] != "binance" {
+		t.Errorf("Exchanges = %v", exchanges)
+	}
+}
+
+func TestServiceCoverage(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	s, err := NewService(redisClient, nil, exchangeconfig.VolatilityConfig{
+		Windows: []exchangeconfig.Duration{{Duration: time.Hour}, {Duration: 4 * time.Hour}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The store only holds the last two hours, as a short candle retention would
+	now := time.Now().Truncate(time.Minute)
+	candle := func(open int64) candles.Candle {
+		return candles.Candle{
+			Exchange: "binance", Pair: "BTCUSDT", Interval: "1m",
+			OpenTime: open, CloseTime: open + 59999,
+			Open: 100, High: 100, Low: 100, Close: 100, Closed: true,
+		}
+	}
+	for open := now.Add(-2 * time.Hour).UnixMilli(); open < now.Add(-time.Minute).UnixMilli(); open += 60000 {
+		c := candle(open)
+		if err := redisClient.ReplaceInSortedSet(candles.Key("binance", "BTCUSDT", "1m"), float64(open), c, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.OnCandle(candle(now.Add(-time.Minute).UnixMilli()))
+
+	if e, _ := s.Estimate("binance", "BTCUSDT", time.Hour); e.Coverage != 1 {
+		t.Errorf("1h coverage = %v, want 1", e.Coverage)
+	}
+	if e, _ := s.Estimate("binance", "BTCUSDT", 4*time.Hour); e.Coverage < 0.49 || e.Coverage > 0.51 || e.Candles != 120 {
+		t.Errorf("4h estimate spans %d candles, coverage %v, want 120 and about half", e.Candles, e.Coverage)
+	}
+}
@@ -0,0 +1,298 @@
+package whale
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// StoreKey is the stream holding every event; each instrument also has its own, see Key.
+const StoreKey = "whales:events"
+
+// minSamples is how many trades a percentile threshold needs before it applies.
+const minSamples = 100
+
+// Event kinds
+const (
+	KindTrade   = "trade"   // a single large trade
+	KindCluster = "cluster" // same-side trades in a short window that add up to a large order
+)
+
+// Sides of the aggressor
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// Context describes the market around an event.
+type Context struct {
+	// VenueVolume and VenueTrades are the venue's activity over the context window
+	VenueVolume float64 `json:"venue_volume"`
+	VenueTrades int     `json:"venue_trades"`
+	// Venues holds the last price of the market on the other exchanges by
+	// exchange:pair, in the pair each quotes it in (BTCUSD for BTCUSDT)
+	Venues map[string]float64 `json:"venues,omitempty"`
+}
+
+// Event is a large trade or cluster. Price is the trade price, or the
+// volume-weighted price of a cluster, and ImpactBps is how far it moved the
+// venue's price from PrePrice, the last price before it.
+type Event struct {
+	ID             string  `json:"id"`
+	Kind           string  `json:"kind"`
+	Exchange       string  `json:"exchange"`
+	Pair           string  `json:"pair"`
+	Side           string  `json:"side"`
+	Price          float64 `json:"price"`
+	Quantity       float64 `json:"quantity"`
+	Notional       float64 `json:"notional"`
+	Trades         int     `json:"trades"`
+	Threshold      float64 `json:"threshold"`
+	PrePrice       float64 `json:"pre_price"`
+	ImpactBps      float64 `json:"impact_bps"`
+	FirstTimestamp int64   `json:"first_timestamp"` // exchange time of the first trade, Unix ms
+	Timestamp      int64   `json:"timestamp"`       // exchange time of the last trade, Unix ms
+	Context        Context `json:"context"`
+}
+
+// Implement the encoding.BinaryMarshaler interface
+func (e Event) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Key is the store key holding the events of one instrument.
+func Key(exchange, pair string) string {
+	return "whales:" + exchange + ":" + pair
+}
+
+type clusterTrade struct {
+	at       time.Time
+	price    float64
+	quantity float64
+	prePrice float64
+}
+
+// cluster is the run of recent same-side trades on one venue.
+type cluster struct {
+	trades     []clusterTrade
+	notional   float64
+	quietUntil time.Time // no new cluster event before this, so one sliced order is reported once
+}
+
+func (c *cluster) add(t clusterTrade, window time.Duration) {
+	c.trades = append(c.trades, t)
+	c.notional += t.price * t.quantity
+
+	cutoff := t.at.Add(-window)
+	n := 0
+	for n < len(c.trades) && c.trades[n].at.Before(cutoff) {
+		c.notional -= c.trades[n].price * c.trades[n].quantity
+		n++
+	}
+	if n > 0 {
+		c.trades = append(c.trades[:0], c.trades[n:]...)
+	}
+}
+
+type instrument struct {
+	lastPrice  float64
+	notionals  []float64 // ring of recent trade notionals
+	next       int
+	seen       int
+	percentile float64 // cached percentile of notionals
+	volume     *market.RollingSum
+	clusters   map[string]*cluster // by side
+}
+
+// Detector flags trades and clusters of same-side trades whose notional
+// exceeds a static or percentile-based threshold per instrument.
+type Detector struct {
+	mu          sync.Mutex
+	config      exchangeconfig.WhaleConfig
+	tracker     *market.Tracker
+	redisClient *redisclient.RedisClient
+	bus         *bus.Bus
+	instruments map[string]*instrument // by exchange:pair
+}
+
+func NewDetector(tracker *market.Tracker, redisClient *redisclient.RedisClient, b *bus.Bus, config exchangeconfig.WhaleConfig) *Detector {
+	return &Detector{
+		config:      config.WithDefaults(),
+		tracker:     tracker,
+		redisClient: redisClient,
+		bus:         b,
+		instruments: make(map[string]*instrument),
+	}
+}
+
+// OnTrade checks a trade on its own and as part of a same-side cluster.
+func (d *Detector) OnTrade(t models.Trade) {
+	if t.Price <= 0 || t.Quantity <= 0 {
+		return
+	}
+	now := time.Now()
+	at := time.UnixMilli(t.Timestamp)
+	notional := t.Price * t.Quantity
+	side := SideBuy
+	if t.IsBuyerMaker {
+		side = SideSell
+	}
+
+	d.mu.Lock()
+	key := t.Exchange + ":" + t.Pair
+	inst, ok := d.instruments[key]
+	if !ok {
+		inst = &instrument{
+			notionals: make([]float64, d.config.Samples),
+			volume:    market.NewRollingSum(d.config.ContextWindow.Duration),
+			clusters:  make(map[string]*cluster),
+		}
+		d.instruments[key] = inst
+	}
+
+	// The threshold is taken before this trade joins the samples, so a large
+	// trade can't raise the bar it is measured against
+	threshold, enabled := d.threshold(inst, d.config.For(t.Exchange, t.Pair))
+	prePrice := inst.lastPrice
+	if prePrice == 0 {
+		prePrice = t.Price
+	}
+	inst.lastPrice = t.Price
+	inst.volume.Add(now, t.Quantity)
+	d.sample(inst, notional)
+
+	var events []Event
+	if enabled && notional > threshold {
+		events = append(events, Event{
+			Kind:           KindTrade,
+			Price:          t.Price,
+			Quantity:       t.Quantity,
+			Notional:       notional,
+			Trades:         1,
+			PrePrice:       prePrice,
+			FirstTimestamp: t.Timestamp,
+		})
+	}
+
+	// A trade reported on its own doesn't count towards a cluster as well
+	c := inst.clusters[side]
+	if c == nil {
+		c = &cluster{}
+		inst.clusters[side] = c
+	}
+	if len(events) == 0 {
+		c.add(clusterTrade{at: at, price: t.Price, quantity: t.Quantity, prePrice: prePrice}, d.config.ClusterWindow.Duration)
+	}
+	if len(events) == 0 && enabled && len(c.trades) >= d.config.ClusterMinTrades && c.notional > threshold && !at.Before(c.quietUntil) {
+		var quantity float64
+		for _, ct := range c.trades {
+			quantity += ct.quantity
+		}
+		events = append(events, Event{
+			Kind:           KindCluster,
+			Price:          c.notional / quantity,
+			Quantity:       quantity,
+			Notional:       c.notional,
+			Trades:         len(c.trades),
+			PrePrice:       c.trades[0].prePrice,
+			FirstTimestamp: c.trades[0].at.UnixMilli(),
+		})
+		c.quietUntil = at.Add(d.config.ClusterWindow.Duration)
+	}
+
+	context := Context{
+		VenueVolume: inst.volume.Sum(now),
+		VenueTrades: inst.volume.Len(now),
+	}
+	d.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	for _, q := range d.tracker.Market(t.Pair) {
+		if q.Exchange == t.Exchange {
+			continue
+		}
+		if context.Venues == nil {
+			context.Venues = make(map[string]float64)
+		}
+		context.Venues[q.Exchange+":"+q.Pair] = q.Price
+	}
+
+	for _, e := range events {
+		e.ID = fmt.Sprintf("%s:%s:%s:%d", e.Kind, key, side, t.Timestamp)
+		e.Exchange, e.Pair, e.Side = t.Exchange, t.Pair, side
+		e.Threshold = threshold
+		e.Timestamp = t.Timestamp
+		e.Context = context
+		// Impact runs from the price before the first trade to the last trade
+		e.ImpactBps = (t.Price - e.PrePrice) / e.PrePrice * 10000
+		d.emit(e)
+	}
+}
+
+// threshold resolves the notional threshold of an instrument. It is disabled
+// when neither a static floor nor a warmed-up percentile is available.
+func (d *Detector) threshold(inst *instrument, config exchangeconfig.WhaleThreshold) (float64, bool) {
+	threshold := config.Notional
+	if config.Percentile > 0 && inst.seen >= minSamples && inst.percentile > threshold {
+		threshold = inst.percentile
+	}
+	if config.Percentile > 0 && inst.seen%minSamples == 0 {
+		inst.percentile = percentile(inst.recent(), config.Percentile)
+	}
+	return threshold, threshold > 0
+}
+
+func (d *Detector) sample(inst *instrument, notional float64) {
+	inst.notionals[inst.next] = notional
+	inst.next = (inst.next + 1) % len(inst.notionals)
+	inst.seen++
+}
+
+func (inst *instrument) recent() []float64 {
+	if inst.seen < len(inst.notionals) {
+		return inst.notionals[:inst.seen]
+	}
+	return inst.notionals
+}
+
+// percentile returns the p-th percentile (0-100) of values by nearest rank.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(p / 100 * float64(len(sorted)))
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func (d *Detector) emit(e Event) {
+	log.Printf("Whale %s %s %s on %s: %.2f at %.2f (%.0f notional, %.1f bps impact)",
+		e.Kind, e.Side, e.Pair, e.Exchange, e.Quantity, e.Price, e.Notional, e.ImpactBps)
+
+	if d.bus != nil {
+		d.bus.Publish(bus.TopicWhales, e)
+	}
+	if d.redisClient == nil {
+		return
+	}
+	for _, key := range []string{StoreKey, Key(e.Exchange, e.Pair)} {
+		if err := d.redisClient.AppendToStream(key, e, d.config.MaxStored, 0); err != nil {
+			log.Printf("Could not store whale event %s: %v", e.ID, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package whale
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"sibylla_service/pkg/bus"
+	exchangeconfig "sibylla_service/pkg/config"
+	"sibylla_service/pkg/market"
+	"sibylla_service/pkg/models"
+	"sibylla_service/pkg/redisclient"
+)
+
+// drain returns the events published so far.
+func drain(sub *bus.Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case msg := <-sub.C:
+			events = append(events, msg.Payload.(Event))
+		default:
+			return events
+		}
+	}
+}
+
+func TestLargeTrade(t *testing.T) {
+	redisClient := redisclient.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	b := bus.New()
+	sub := b.Subscribe(bus.TopicWhales, 16)
+	tracker := market.NewTracker()
+	d := NewDetector(tracker, redisClient, b, exchangeconfig.WhaleConfig{Default: exchangeconfig.WhaleThreshold{Notional: 1000}})
+
+	tracker.Update(models.Trade{Exchange: "kraken", Pair: "BTCUSD", Price: 100.5})
+	d.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: 5, Timestamp: 1000})
+	d.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 101, Quantity: 20, Timestamp: 2000, IsBuyerMaker: true})
+
+	events := drain(sub)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %+v", len(events), events)
+	}
+	e := events[0]
+	if e.Kind != KindTrade || e.Side != SideSell || e.Notional != 2020 || e.Threshold != 1000 || e.PrePrice != 100 {
+		t.Errorf("event = %+v", e)
+	}
+	if math.Abs(e.ImpactBps-100) > 1e-9 {
+		t.Errorf("impact = %v bps, want 100", e.ImpactBps)
+	}
+	if e.Context.VenueTrades != 2 || e.Context.VenueVolume != 25 || e.Context.Venues["kraken:BTCUSD"] != 100.5 {
+		t.Errorf("context = %+v", e.Context)
+	}
+
+	for _, key := range []string{StoreKey, Key("binance", "BTCUSDT")} {
+		if stored, err := redisClient.GetStream(key, 10); err != nil || len(stored) != 1 {
+			t.Errorf("got %d events in %s (%v), want 1", len(stored), key, err)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	b := bus.New()
+	sub := b.Subscribe(bus.TopicWhales, 16)
+	d := NewDetector(market.NewTracker(), nil, b, exchangeconfig.WhaleConfig{Default: exchangeconfig.WhaleThreshold{Notional: 1000}})
+	buy := func(price float64, timestamp int64) {
+		d.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: price, Quantity: 4, Timestamp: timestamp})
+	}
+
+	buy(100, 1000)
+	buy(100, 1500)
+	if events := drain(sub); len(events) != 0 {
+		t.Fatalf("got events before the cluster is large enough: %+v", events)
+	}
+	buy(102, 2000)
+	events := drain(sub)
+	if len(events) != 1 || events[0].Kind != KindCluster || events[0].Trades != 3 || events[0].FirstTimestamp != 1000 {
+		t.Fatalf("events = %+v, want one cluster of 3 trades", events)
+	}
+	if math.Abs(events[0].Notional-1208) > 1e-9 || math.Abs(events[0].ImpactBps-200) > 1e-9 {
+		t.Errorf("cluster = %+v, want 1208 notional and 200 bps impact", events[0])
+	}
+
+	// The same sliced order isn't reported again within the cluster window
+	buy(102, 2500)
+	if events := drain(sub); len(events) != 0 {
+		t.Errorf("cluster reported twice: %+v", events)
+	}
+}
+
+func TestLargeTradeIsNotAlsoACluster(t *testing.T) {
+	b := bus.New()
+	sub := b.Subscribe(bus.TopicWhales, 16)
+	d := NewDetector(market.NewTracker(), nil, b, exchangeconfig.WhaleConfig{Default: exchangeconfig.WhaleThreshold{Notional: 1000}})
+	buy := func(quantity float64, timestamp int64) {
+		d.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 100, Quantity: quantity, Timestamp: timestamp})
+	}
+
+	buy(1, 1000)
+	buy(20, 1500)
+	if events := drain(sub); len(events) != 1 || events[0].Kind != KindTrade {
+		t.Fatalf("events = %+v, want the large trade alone", events)
+	}
+	// Without the large trade the run adds up to 300, no cluster
+	buy(1, 2000)
+	buy(1, 2500)
+	if events := drain(sub); len(events) != 0 {
+		t.Errorf("large trade counted again in a cluster: %+v", events)
+	}
+}
+
+func TestPercentileThreshold(t *testing.T) {
+	b := bus.New()
+	sub := b.Subscribe(bus.TopicWhales, 16)
+	d := NewDetector(market.NewTracker(), nil, b, exchangeconfig.WhaleConfig{Default: exchangeconfig.WhaleThreshold{Percentile: 99}})
+	trade := func(quantity float64, i int64) {
+		// Far enough apart that no trades cluster
+		d.OnTrade(models.Trade{Exchange: "binance", Pair: "BTCUSDT", Price: 10, Quantity: quantity, Timestamp: i * 10000})
+	}
+
+	// No threshold until enough trades have been seen
+	var i int64
+	for ; i < minSamples; i++ {
+		quantity := 1.0
+		if i == 50 {
+			quantity = 100
+		}
+		trade(quantity, i)
+	}
+	if events := drain(sub); len(events) != 0 {
+		t.Fatalf("flagged trades before the percentile warmed up: %+v", events)
+	}
+
+	// The 99th percentile is the earlier 100 lot
+	trade(1, i)
+	i++
+	trade(200, i)
+	events := drain(sub)
+	if len(events) != 1 || events[0].Notional != 2000 || events[0].Threshold != 1000 {
+		t.Errorf("events = %+v, want the 200 lot over the 99th percentile", events)
+	}
+	trade(50, i+1)
+	if events := drain(sub); len(events) != 0 {
+		t.Errorf("flagged a trade under the 99th percentile: %+v", events)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3}
+	if p := percentile(values, 50); p != 3 {
+		t.Errorf("p50 = %v, want 3", p)
+	}
+	if p := percentile(values, 100); p != 5 {
+		t.Errorf("p100 = %v, want 5", p)
+	}
+	if p := percentile(nil, 50); p != 0 {
+		t.Errorf("p50 of nothing = %v, want 0", p)
+	}
+}